@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+var errAssetNotFound = errors.New("Asset not found")
+
+var assetExtensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true,
+}
+
+// BuildAssetList maps the short name of every image under assetDir, its path
+// relative to the folder without the extension, to the file path
+func BuildAssetList(assetDir string) map[string]string {
+	assets := map[string]string{}
+
+	err := filepath.WalkDir(assetDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !assetExtensions[strings.ToLower(filepath.Ext(path))] {
+			return err
+		}
+
+		relative, err := filepath.Rel(assetDir, path)
+		if err != nil {
+			return err
+		}
+
+		name := filepath.ToSlash(strings.TrimSuffix(relative, filepath.Ext(relative)))
+		assets[name] = path
+		return nil
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		panic(err)
+	}
+
+	return assets
+}
+
+// resolveAsset replaces a short asset name with its file path. Like fonts,
+// images are limited to the library, so any other value is rejected,
+// including paths outside the asset folder. The file path of an asset is
+// accepted as well so a request can be prepared twice.
+func resolveAsset(path *string, assets map[string]string) error {
+	if *path == "" {
+		return nil
+	}
+
+	if assetPath, ok := assets[*path]; ok {
+		*path = assetPath
+		return nil
+	}
+
+	if slices.Contains(slices.Collect(maps.Values(assets)), filepath.Clean(*path)) {
+		*path = filepath.Clean(*path)
+		return nil
+	}
+
+	return errAssetNotFound
+}
+
+// ResolveAssets swaps every image reference in the request for the file path
+// of its asset
+func ResolveAssets(request *ImgRequest, assets map[string]string) error {
+	if err := resolveAsset(&request.BgImgPath, assets); err != nil {
+		return err
+	}
+
+	for i := range request.Images {
+		if err := resolveAsset(&request.Images[i].Path, assets); err != nil {
+			return err
+		}
+
+		if err := resolveAsset(&request.Images[i].MaskPath, assets); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"image"
+)
+
+type BatchRequest struct {
+	Requests []ImgRequest `json:"requests" binding:"required"`
+}
+
+type BatchResult struct {
+	ContentType string `json:"contentType"`
+	Data        string `json:"data"` // base64 encoded image
+}
+
+type batchError struct {
+	status int
+	index  int
+	err    error
+}
+
+func (e *batchError) Error() string {
+	return e.err.Error()
+}
+
+// GenerateBatch renders the requests in order. Each request can place any
+// earlier result through PlacedImage.BatchIndex without the client sending
+// the bytes back.
+func GenerateBatch(batch BatchRequest, prepare func(*ImgRequest) error) ([]BatchResult, *batchError) {
+	results := []BatchResult{}
+	rendered := []image.Image{}
+
+	for i, request := range batch.Requests {
+		request.BatchResults = rendered
+
+		if err := prepare(&request); err != nil {
+			return nil, &batchError{400, i, err}
+		}
+
+		img, data, err := tryComposeAndEncode(request)
+		if err != nil {
+			return nil, &batchError{500, i, fmt.Errorf("Failed to generate image: %w", err)}
+		}
+
+		contentType, _ := ContentType(request.Format)
+		results = append(results, BatchResult{contentType, base64.StdEncoding.EncodeToString(data)})
+		rendered = append(rendered, img)
+	}
+
+	return results, nil
+}
+
+func tryComposeAndEncode(request ImgRequest) (img image.Image, data []byte, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("%v", recovered)
+		}
+	}()
+
+	img = ComposeImage(request)
+	return img, EncodeRequest(img, request).Bytes(), nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+)
+
+func TestBatchComposesEarlierResult(t *testing.T) {
+	server := newTestServer(t, Config{})
+	first, missing := 0, 1
+
+	frame := ImgRequest{
+		WidthPx:    40,
+		HeightPx:   40,
+		BgColor:    red,
+		Rectangles: []Rectangle{{Position: Position{10, 10}, WidthPx: 20, HeightPx: 20, Color: blue}},
+	}
+	collage := ImgRequest{
+		WidthPx:  100,
+		HeightPx: 100,
+		BgColor:  white,
+		Images:   []PlacedImage{{BatchIndex: &first, Position: Position{30, 30}}},
+	}
+
+	response := postJSON(t, server.URL+"/generate/batch", BatchRequest{Requests: []ImgRequest{frame, collage}})
+	body := readBody(t, response)
+	if response.StatusCode != 200 {
+		t.Fatalf("status %d: %s", response.StatusCode, body)
+	}
+
+	var batch struct {
+		Images []BatchResult `json:"images"`
+	}
+	if err := json.Unmarshal(body, &batch); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(batch.Images) != 2 {
+		t.Fatalf("%d results, want 2", len(batch.Images))
+	}
+
+	decoded := [2][]byte{}
+	for i, result := range batch.Images {
+		data, err := base64.StdEncoding.DecodeString(result.Data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded[i] = data
+	}
+
+	frameImg, collageImg := decodeImage(t, decoded[0]), decodeImage(t, decoded[1])
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 40; x++ {
+			if got, want := pixel(collageImg, 30+x, 30+y), pixel(frameImg, x, y); got != want {
+				t.Fatalf("collage pixel (%d, %d) is %v, want the frame's %v", 30+x, 30+y, got, want)
+			}
+		}
+	}
+
+	if got := pixel(collageImg, 10, 10); got != nrgba(white) {
+		t.Errorf("collage background is %v, want %v", got, white)
+	}
+
+	// Only earlier results can be referenced
+	collage.Images[0].BatchIndex = &missing
+	response = postJSON(t, server.URL+"/generate/batch", BatchRequest{Requests: []ImgRequest{frame, collage}})
+	if body := readBody(t, response); response.StatusCode != 400 {
+		t.Errorf("status %d for a later index, want 400: %s", response.StatusCode, body)
+	}
+}
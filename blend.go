@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"image"
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+type BlendMode string
+
+const (
+	Normal   BlendMode = "normal"
+	Multiply BlendMode = "multiply"
+	Screen   BlendMode = "screen"
+	Overlay  BlendMode = "overlay"
+	Darken   BlendMode = "darken"
+	Lighten  BlendMode = "lighten"
+)
+
+// Blend functions on non-premultiplied channels, backdrop first
+var blendFuncs = map[BlendMode]func(cb, cs float64) float64{
+	Multiply: func(cb, cs float64) float64 { return cb * cs },
+	Screen:   func(cb, cs float64) float64 { return cb + cs - cb*cs },
+	Overlay: func(cb, cs float64) float64 {
+		if cb <= 0.5 {
+			return 2 * cb * cs
+		}
+		return 1 - 2*(1-cb)*(1-cs)
+	},
+	Darken:  math.Min,
+	Lighten: math.Max,
+}
+
+func (mode BlendMode) Validate() error {
+	if _, ok := blendFuncs[mode]; !ok && mode != "" && mode != Normal {
+		return errors.New("blendMode must be normal, multiply, screen, overlay, darken or lighten")
+	}
+
+	return nil
+}
+
+// DrawBlended runs draw on a scratch layer and composites it onto dc with
+// mode, since gg itself only draws source-over
+func DrawBlended(dc *gg.Context, mode BlendMode, draw func(dc *gg.Context)) {
+	blend, ok := blendFuncs[mode]
+	if !ok {
+		draw(dc)
+		return
+	}
+
+	scale := deviceScale(dc)
+
+	layer := gg.NewContext(dc.Width(), dc.Height())
+	layer.Scale(scale, scale)
+	draw(layer)
+
+	blendInto(dc.Image().(*image.RGBA), layer.Image().(*image.RGBA), blend)
+}
+
+// blendInto composites src over dst using the W3C separable blend formula on
+// premultiplied pixels: co = cs(1 - ab) + cb(1 - as) + as ab B(cb, cs)
+func blendInto(dst, src *image.RGBA, blend func(cb, cs float64) float64) {
+	for i := 0; i < len(src.Pix); i += 4 {
+		as := float64(src.Pix[i+3]) / 255
+		if as == 0 {
+			continue
+		}
+
+		ab := float64(dst.Pix[i+3]) / 255
+
+		for c := 0; c < 3; c++ {
+			csPremultiplied := float64(src.Pix[i+c]) / 255
+			cbPremultiplied := float64(dst.Pix[i+c]) / 255
+
+			cs := csPremultiplied / as
+			cb := 0.0
+			if ab > 0 {
+				cb = cbPremultiplied / ab
+			}
+
+			co := csPremultiplied*(1-ab) + cbPremultiplied*(1-as) + as*ab*blend(cb, cs)
+			dst.Pix[i+c] = uint8(math.Round(math.Min(1, co) * 255))
+		}
+
+		dst.Pix[i+3] = uint8(math.Round((as + ab*(1-as)) * 255))
+	}
+}
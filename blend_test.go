@@ -0,0 +1,60 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestBlendModes(t *testing.T) {
+	gray := Color{128, 128, 128, 255}
+
+	tests := []struct {
+		mode BlendMode
+		want Color
+	}{
+		{Normal, gray},
+		{Multiply, Color{128, 0, 0, 255}},
+		{Screen, Color{255, 128, 128, 255}},
+		{Overlay, red},
+		{Darken, Color{128, 0, 0, 255}},
+		{Lighten, Color{255, 128, 128, 255}},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.mode), func(t *testing.T) {
+			request := ImgRequest{
+				WidthPx:  40,
+				HeightPx: 40,
+				BgColor:  red,
+				Circles:  []Circle{{Center: Position{20, 20}, RadiusPx: 15, FillColor: gray, BlendMode: test.mode}},
+			}
+			img := renderRequest(t, request, nil)
+
+			if got := pixel(img, 20, 20); !closeTo(got, nrgba(test.want), 1) {
+				t.Errorf("center is %v, want %v", got, test.want)
+			}
+
+			// Outside the circle the backdrop is untouched
+			if got := pixel(img, 1, 1); got != nrgba(red) {
+				t.Errorf("corner is %v, want %v", got, red)
+			}
+		})
+	}
+}
+
+func TestBlendModePlacedImage(t *testing.T) {
+	_, assets := testAssets(t, map[string]image.Image{"gray": solidImage(20, 20, Color{128, 128, 128, 255})})
+
+	request := ImgRequest{
+		WidthPx:  40,
+		HeightPx: 40,
+		BgColor:  red,
+		Images:   []PlacedImage{{Path: "gray", Position: Position{10, 10}, WidthPx: 20, HeightPx: 20, BlendMode: Multiply}},
+	}
+	img := renderWithAssets(t, request, nil, assets)
+
+	if got := pixel(img, 20, 20); !closeTo(got, color.NRGBA{128, 0, 0, 255}, 1) {
+		t.Errorf("multiplied image is %v, want darker red", got)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"container/list"
+	"image"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/fogleman/gg"
+)
+
+const defaultImageCacheSize = 32
+
+type cachedImage struct {
+	path    string
+	modTime time.Time
+	img     image.Image
+}
+
+// ImageCache is an LRU of decoded images keyed by path. Entries are dropped
+// when the file modification time changes. Cached images are shared between
+// requests and must not be modified.
+type ImageCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+func NewImageCache(capacity int) *ImageCache {
+	return &ImageCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  map[string]*list.Element{},
+	}
+}
+
+// Load decodes the image at path, or returns the cached one while the file is
+// unchanged. A nil cache always decodes.
+func (cache *ImageCache) Load(path string) (image.Image, error) {
+	if cache == nil {
+		return gg.LoadImage(path)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.mu.Lock()
+	if element, ok := cache.entries[path]; ok {
+		entry := element.Value.(*cachedImage)
+		if entry.modTime.Equal(info.ModTime()) {
+			cache.order.MoveToFront(element)
+			cache.mu.Unlock()
+			return entry.img, nil
+		}
+
+		cache.order.Remove(element)
+		delete(cache.entries, path)
+	}
+	cache.mu.Unlock()
+
+	// Decode outside the lock so slow files don't block other requests
+	img, err := gg.LoadImage(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if cache.capacity <= 0 {
+		return img, nil
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if element, ok := cache.entries[path]; ok {
+		cache.order.Remove(element)
+	}
+
+	cache.entries[path] = cache.order.PushFront(&cachedImage{path, info.ModTime(), img})
+
+	for cache.order.Len() > cache.capacity {
+		oldest := cache.order.Back()
+		cache.order.Remove(oldest)
+		delete(cache.entries, oldest.Value.(*cachedImage).path)
+	}
+
+	return img, nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestImageCacheModifiedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bg.png")
+	writeTestImage(t, path, solidImage(8, 8, red))
+
+	cache := NewImageCache(4)
+	first, err := cache.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := cache.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if again != first {
+		t.Error("unchanged file was decoded again")
+	}
+
+	// Push the mtime forward so the rewrite is visible on coarse clocks
+	writeTestImage(t, path, solidImage(8, 8, blue))
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	modified, err := cache.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := pixel(modified, 0, 0); got != nrgba(blue) {
+		t.Errorf("modified file is %v, want %v", got, blue)
+	}
+}
+
+func TestImageCacheEvicts(t *testing.T) {
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "a.png"), filepath.Join(dir, "b.png")}
+	for _, path := range paths {
+		writeTestImage(t, path, solidImage(8, 8, red))
+	}
+
+	cache := NewImageCache(1)
+	for _, path := range paths {
+		if _, err := cache.Load(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(cache.entries) != 1 || cache.order.Len() != 1 {
+		t.Errorf("cache holds %d entries, want 1", len(cache.entries))
+	}
+
+	if _, ok := cache.entries[paths[1]]; !ok {
+		t.Error("most recent image was evicted")
+	}
+}
+
+func BenchmarkImageCache(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "bg.png")
+	file, err := os.Create(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := EncodeImage(file, solidImage(1200, 630, red), PNG, 0); err != nil {
+		b.Fatal(err)
+	}
+	file.Close()
+
+	for _, bench := range []struct {
+		name     string
+		capacity int
+	}{{"cached", defaultImageCacheSize}, {"uncached", 0}} {
+		b.Run(bench.name, func(b *testing.B) {
+			cache := NewImageCache(bench.capacity)
+			for i := 0; i < b.N; i++ {
+				if _, err := cache.Load(path); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
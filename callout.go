@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+type CalloutEdge string
+
+const (
+	TopEdge    CalloutEdge = "top"
+	BottomEdge CalloutEdge = "bottom"
+	LeftEdge   CalloutEdge = "left"
+	RightEdge  CalloutEdge = "right"
+)
+
+// Callout is a speech bubble: a rounded box with a triangular pointer sticking
+// out of one edge. PointerOffset places the pointer along that edge as a
+// fraction of its length from the left or top end, centered when omitted.
+// Text is wrapped and centered in the box.
+type Callout struct {
+	Position        Position    `json:"position"`
+	WidthPx         float64     `json:"widthPx"`
+	HeightPx        float64     `json:"heightPx"` // the box, without the pointer
+	CornerRadiusPx  float64     `json:"cornerRadiusPx" default:"12"`
+	PointerEdge     CalloutEdge `json:"pointerEdge" default:"bottom"`
+	PointerOffset   *float64    `json:"pointerOffset"`
+	PointerWidthPx  float64     `json:"pointerWidthPx" default:"20"`
+	PointerLengthPx float64     `json:"pointerLengthPx" default:"16"`
+	FillColor       Color       `json:"fillColor"`
+	StrokeColor     Color       `json:"strokeColor"`
+	StrokeWidthPx   float64     `json:"strokeWidthPx"`
+	Text            string      `json:"text"`
+	Font            string      `json:"font"` // needed with text
+	SizePx          float64     `json:"sizePx"`
+	TextColor       Color       `json:"textColor"`
+	PaddingPx       float64     `json:"paddingPx" default:"12"` // keeps text off the box edges
+	Shortcodes      bool        `json:"shortcodes"`             // expand :heart: style emoji shortcodes
+	Visibility
+}
+
+func (callout *Callout) applyDefaults() {
+	if callout.CornerRadiusPx == 0 {
+		callout.CornerRadiusPx = 12
+	}
+
+	if callout.PointerEdge == "" {
+		callout.PointerEdge = BottomEdge
+	}
+
+	if callout.PointerWidthPx == 0 {
+		callout.PointerWidthPx = 20
+	}
+
+	if callout.PointerLengthPx == 0 {
+		callout.PointerLengthPx = 16
+	}
+
+	if callout.PaddingPx == 0 {
+		callout.PaddingPx = 12
+	}
+}
+
+func (callout Callout) Validate() error {
+	switch callout.PointerEdge {
+	case "", TopEdge, BottomEdge, LeftEdge, RightEdge:
+	default:
+		return errors.New("pointerEdge must be top, bottom, left or right")
+	}
+
+	if callout.WidthPx <= 0 || callout.HeightPx <= 0 {
+		return errors.New("callouts need widthPx and heightPx")
+	}
+
+	if offset := callout.pointerOffset(); offset < 0 || offset > 1 {
+		return errors.New("pointerOffset must be between 0 and 1")
+	}
+
+	return nil
+}
+
+func (callout Callout) pointerOffset() float64 {
+	if callout.PointerOffset != nil {
+		return *callout.PointerOffset
+	}
+
+	return 0.5
+}
+
+// calloutPath traces the box and pointer as one outline, clockwise from the
+// top left corner, so the stroke runs around the pointer instead of across it
+func calloutPath(dc *gg.Context, callout Callout) {
+	x, y := callout.Position.X, callout.Position.Y
+	width, height := callout.WidthPx, callout.HeightPx
+	radius := math.Min(callout.CornerRadiusPx, math.Min(width, height)/2)
+
+	// pointer adds the pointer to the edge running from (x0, y0) to (x1, y1)
+	// when it is the pointer edge. The outward normal of a clockwise edge is
+	// its direction turned a quarter to the left.
+	pointer := func(edge CalloutEdge, x0, y0, x1, y1 float64) {
+		if edge != callout.PointerEdge {
+			return
+		}
+
+		length := math.Hypot(x1-x0, y1-y0)
+		dx, dy := (x1-x0)/length, (y1-y0)/length
+
+		// Keep the base clear of the rounded corners
+		half := math.Min(callout.PointerWidthPx/2, length/2)
+		along := callout.pointerOffset() * length
+		if edge == BottomEdge || edge == LeftEdge {
+			along = length - along
+		}
+		along = math.Max(half, math.Min(length-half, along))
+
+		baseX, baseY := x0+dx*along, y0+dy*along
+		dc.LineTo(baseX-dx*half, baseY-dy*half)
+		dc.LineTo(baseX+dy*callout.PointerLengthPx, baseY-dx*callout.PointerLengthPx)
+		dc.LineTo(baseX+dx*half, baseY+dy*half)
+	}
+
+	dc.NewSubPath()
+	dc.MoveTo(x+radius, y)
+	pointer(TopEdge, x+radius, y, x+width-radius, y)
+	dc.DrawArc(x+width-radius, y+radius, radius, -math.Pi/2, 0)
+	pointer(RightEdge, x+width, y+radius, x+width, y+height-radius)
+	dc.DrawArc(x+width-radius, y+height-radius, radius, 0, math.Pi/2)
+	pointer(BottomEdge, x+width-radius, y+height, x+radius, y+height)
+	dc.DrawArc(x+radius, y+height-radius, radius, math.Pi/2, math.Pi)
+	pointer(LeftEdge, x, y+height-radius, x, y+radius)
+	dc.DrawArc(x+radius, y+radius, radius, math.Pi, 3*math.Pi/2)
+	dc.ClosePath()
+}
+
+func DrawCallout(dc *gg.Context, callout Callout) {
+	callout.applyDefaults()
+
+	calloutPath(dc, callout)
+	fillAndStroke(dc, callout.FillColor, callout.StrokeColor, callout.StrokeWidthPx)
+
+	if callout.Text == "" {
+		return
+	}
+
+	text := StyledText{Text: callout.Text, Font: callout.Font, SizePx: pixels(callout.SizePx), Color: callout.TextColor}
+	centerX := callout.Position.X + callout.WidthPx/2
+	centerY := callout.Position.Y + callout.HeightPx/2
+
+	DrawText(dc, text, func(dc *gg.Context, scale float64) {
+		dc.DrawStringWrapped(text.Text, centerX*scale, centerY*scale, 0.5, 0.5, (callout.WidthPx-2*callout.PaddingPx)*scale, 1.3, gg.AlignCenter)
+	})
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"image"
+	"testing"
+)
+
+func TestCalloutPointer(t *testing.T) {
+	// A 100x60 box at (40, 40) with a 16px pointer in the middle of an edge
+	tests := []struct {
+		edge    CalloutEdge
+		pointer image.Point // inside the pointer, near the tip
+		beside  image.Point // just as far out, away from the pointer
+	}{
+		{BottomEdge, image.Pt(90, 110), image.Pt(60, 110)},
+		{TopEdge, image.Pt(90, 30), image.Pt(60, 30)},
+		{LeftEdge, image.Pt(30, 70), image.Pt(30, 50)},
+		{RightEdge, image.Pt(150, 70), image.Pt(150, 50)},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.edge), func(t *testing.T) {
+			callout := Callout{Position: Position{40, 40}, WidthPx: 100, HeightPx: 60, PointerEdge: test.edge, FillColor: red}
+			img := renderRequest(t, ImgRequest{WidthPx: 180, HeightPx: 140, BgColor: white, Callouts: []Callout{callout}}, nil)
+
+			if got := pixel(img, 90, 70); got != nrgba(red) {
+				t.Errorf("box center is %v, want %v", got, red)
+			}
+
+			if got := pixel(img, test.pointer.X, test.pointer.Y); got != nrgba(red) {
+				t.Errorf("pointer pixel %v is %v, want %v", test.pointer, got, red)
+			}
+
+			if got := pixel(img, test.beside.X, test.beside.Y); got != nrgba(white) {
+				t.Errorf("pixel %v beside the pointer is %v, want the background", test.beside, got)
+			}
+		})
+	}
+}
+
+func TestCalloutPointerOffsetZero(t *testing.T) {
+	// An offset of 0 is the left end of the bottom edge, not the middle
+	offset := 0.0
+	callout := Callout{Position: Position{40, 40}, WidthPx: 100, HeightPx: 60, PointerOffset: &offset, FillColor: red}
+	img := renderRequest(t, ImgRequest{WidthPx: 180, HeightPx: 140, BgColor: white, Callouts: []Callout{callout}}, nil)
+
+	if got := pixel(img, 62, 110); got != nrgba(red) {
+		t.Errorf("pointer pixel (62, 110) is %v, want %v", got, red)
+	}
+
+	if got := pixel(img, 90, 110); got != nrgba(white) {
+		t.Errorf("middle of the edge is %v, want the background", got)
+	}
+}
+
+func TestCalloutTextAndStroke(t *testing.T) {
+	fonts := newTestFonts(t)
+	offset := 0.2
+	callout := Callout{
+		Position:      Position{40, 40},
+		WidthPx:       100,
+		HeightPx:      60,
+		PointerOffset: &offset,
+		FillColor:     white,
+		StrokeColor:   blue,
+		StrokeWidthPx: 4,
+		Text:          "Hi",
+		Font:          fonts.regular,
+		SizePx:        24,
+		TextColor:     black,
+	}
+	img := renderRequest(t, ImgRequest{WidthPx: 180, HeightPx: 140, BgColor: white, Callouts: []Callout{callout}}, fonts.faces())
+
+	// The stroke runs along the box edges and around the pointer, which sits
+	// a fifth of the way along the bottom edge instead of in the middle
+	for _, point := range []image.Point{{90, 40}, {40, 70}, {120, 100}, {61, 106}, {67, 114}} {
+		if got := pixel(img, point.X, point.Y); !closeTo(got, nrgba(blue), 8) {
+			t.Errorf("outline pixel %v is %v, want %v", point, got, blue)
+		}
+	}
+
+	for _, point := range []image.Point{{67, 104}, {90, 110}} {
+		if got := pixel(img, point.X, point.Y); got != nrgba(white) {
+			t.Errorf("pixel %v is %v, want %v", point, got, white)
+		}
+	}
+
+	text := inkIn(img, image.Rect(50, 50, 130, 90))
+	if text.Empty() {
+		t.Fatal("no text in the box")
+	}
+
+	if center := (text.Min.X + text.Max.X) / 2; center < 86 || center > 94 {
+		t.Errorf("text centered at x=%d, want 90", center)
+	}
+
+	if err := (Callout{WidthPx: 10, HeightPx: 10, PointerEdge: "middle"}).Validate(); err == nil {
+		t.Error("unknown pointer edge was accepted")
+	}
+
+	outside := 1.5
+	if err := (Callout{WidthPx: 10, HeightPx: 10, PointerOffset: &outside}).Validate(); err == nil {
+		t.Error("pointer offset above 1 was accepted")
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/fogleman/gg"
+)
+
+type Bar struct {
+	Label string  `json:"label"`
+	Value float64 `json:"value"`
+	Color Color   `json:"color"`
+}
+
+// BarChart scales its bars to the largest value, or to MaxValue when set.
+// Labels and values are drawn with Font at SizePx and take room below and
+// above the bars.
+type BarChart struct {
+	Position   Position `json:"position"`
+	WidthPx    float64  `json:"widthPx"`
+	HeightPx   float64  `json:"heightPx"`
+	Bars       []Bar    `json:"bars"`
+	MaxValue   float64  `json:"maxValue"`
+	GapPx      float64  `json:"gapPx" default:"8"`
+	Font       string   `json:"font"` // needed for labels and values
+	SizePx     float64  `json:"sizePx"`
+	LabelColor Color    `json:"labelColor"`
+	ShowValues bool     `json:"showValues"`
+	ShowAxis   bool     `json:"showAxis"`
+	AxisColor  Color    `json:"axisColor"` // LabelColor when omitted
+	Visibility
+}
+
+func (chart BarChart) hasLabels() bool {
+	for _, bar := range chart.Bars {
+		if bar.Label != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func DrawBarChart(dc *gg.Context, chart BarChart) {
+	if len(chart.Bars) == 0 {
+		return
+	}
+
+	gap := chart.GapPx
+	if gap == 0 {
+		gap = 8
+	}
+
+	maxValue := chart.MaxValue
+	if maxValue <= 0 {
+		for _, bar := range chart.Bars {
+			maxValue = math.Max(maxValue, bar.Value)
+		}
+	}
+
+	lineHeight := chart.SizePx * 1.4
+	top, bottom := chart.Position.Y, chart.Position.Y+chart.HeightPx
+
+	if chart.ShowValues {
+		top += lineHeight
+	}
+
+	if chart.hasLabels() {
+		bottom -= lineHeight
+	}
+
+	barWidth := (chart.WidthPx - gap*float64(len(chart.Bars)-1)) / float64(len(chart.Bars))
+	labels := []StyledText{}
+
+	for i, bar := range chart.Bars {
+		x := chart.Position.X + float64(i)*(barWidth+gap)
+
+		height := 0.0
+		if maxValue > 0 {
+			height = (bottom - top) * math.Max(0, math.Min(1, bar.Value/maxValue))
+		}
+
+		dc.DrawRectangle(x, bottom-height, barWidth, height)
+		dc.SetColor(bar.Color.ToRGBA())
+		dc.Fill()
+
+		label := StyledText{Font: chart.Font, SizePx: pixels(chart.SizePx), Color: chart.LabelColor}
+
+		if bar.Label != "" {
+			label.Text, label.Position = bar.Label, textPosition(Position{x + barWidth/2, bottom + lineHeight/2})
+			labels = append(labels, label)
+		}
+
+		if chart.ShowValues {
+			label.Text = strconv.FormatFloat(bar.Value, 'f', -1, 64)
+			label.Position = textPosition(Position{x + barWidth/2, bottom - height - lineHeight/2})
+			labels = append(labels, label)
+		}
+	}
+
+	if chart.ShowAxis {
+		axisColor := chart.AxisColor
+		if axisColor == (Color{}) {
+			axisColor = chart.LabelColor
+		}
+
+		dc.SetColor(axisColor.ToRGBA())
+		dc.SetLineWidth(1)
+		dc.DrawLine(chart.Position.X, top, chart.Position.X, bottom)
+		dc.DrawLine(chart.Position.X, bottom, chart.Position.X+chart.WidthPx, bottom)
+		dc.Stroke()
+	}
+
+	// Labels are centered on their position
+	for _, label := range labels {
+		DrawText(dc, label, func(dc *gg.Context, scale float64) {
+			dc.DrawStringAnchored(label.Text, label.Position.X.Value*scale, label.Position.Y.Value*scale, 0.5, 0.35)
+		})
+	}
+}
+
+// Sparkline stretches its values over the box, the smallest at the bottom
+// edge and the largest at the top. FillColor fills the area below the line.
+type Sparkline struct {
+	Position    Position  `json:"position"`
+	WidthPx     float64   `json:"widthPx"`
+	HeightPx    float64   `json:"heightPx"`
+	Values      []float64 `json:"values"`
+	Color       Color     `json:"color"`
+	LineWidthPx float64   `json:"lineWidthPx" default:"2"`
+	FillColor   Color     `json:"fillColor"`
+	Visibility
+}
+
+// points maps the values onto the box. A flat series sits in the middle.
+func (sparkline Sparkline) points() []gg.Point {
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, value := range sparkline.Values {
+		low, high = math.Min(low, value), math.Max(high, value)
+	}
+
+	points := []gg.Point{}
+	for i, value := range sparkline.Values {
+		x := sparkline.Position.X
+		if len(sparkline.Values) > 1 {
+			x += sparkline.WidthPx * float64(i) / float64(len(sparkline.Values)-1)
+		}
+
+		normalized := 0.5
+		if high > low {
+			normalized = (value - low) / (high - low)
+		}
+
+		points = append(points, gg.Point{X: x, Y: sparkline.Position.Y + sparkline.HeightPx*(1-normalized)})
+	}
+
+	return points
+}
+
+func DrawSparkline(dc *gg.Context, sparkline Sparkline) {
+	if len(sparkline.Values) == 0 {
+		return
+	}
+
+	points := sparkline.points()
+	bottom := sparkline.Position.Y + sparkline.HeightPx
+
+	if sparkline.FillColor != (Color{}) {
+		dc.MoveTo(points[0].X, bottom)
+		for _, point := range points {
+			dc.LineTo(point.X, point.Y)
+		}
+		dc.LineTo(points[len(points)-1].X, bottom)
+		dc.ClosePath()
+
+		dc.SetColor(sparkline.FillColor.ToRGBA())
+		dc.Fill()
+	}
+
+	width := sparkline.LineWidthPx
+	if width == 0 {
+		width = 2
+	}
+
+	dc.NewSubPath()
+	for _, point := range points {
+		dc.LineTo(point.X, point.Y)
+	}
+
+	dc.SetLineJoinRound()
+	dc.SetLineCapRound()
+	dc.SetColor(sparkline.Color.ToRGBA())
+	dc.SetLineWidth(width)
+	dc.Stroke()
+}
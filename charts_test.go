@@ -0,0 +1,87 @@
+package main
+
+import (
+	"image"
+	"testing"
+)
+
+// columnHeight counts the pixels of column x in img that are exactly c
+func columnHeight(img image.Image, x int, c Color) int {
+	count := 0
+	for y := 0; y < img.Bounds().Dy(); y++ {
+		if pixel(img, x, y) == nrgba(c) {
+			count++
+		}
+	}
+
+	return count
+}
+
+func TestBarChartProportions(t *testing.T) {
+	// Three 60px bars with 10px gaps in a 100px tall chart
+	chart := BarChart{
+		Position: Position{10, 10},
+		WidthPx:  200,
+		HeightPx: 100,
+		GapPx:    10,
+		Bars:     []Bar{{Value: 1, Color: red}, {Value: 2, Color: green}, {Value: 4, Color: blue}},
+	}
+
+	tests := []struct {
+		name     string
+		maxValue float64
+		heights  [3]int
+	}{
+		{"largest value", 0, [3]int{25, 50, 100}},
+		{"max value", 8, [3]int{12, 25, 50}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chart.MaxValue = test.maxValue
+			img := renderRequest(t, ImgRequest{WidthPx: 220, HeightPx: 120, BgColor: white, BarCharts: []BarChart{chart}}, nil)
+
+			for i, bar := range chart.Bars {
+				x := 10 + i*70 + 30
+				if got := columnHeight(img, x, bar.Color); got < test.heights[i] || got > test.heights[i]+1 {
+					t.Errorf("bar %d is %dpx tall, want %dpx", i, got, test.heights[i])
+				}
+			}
+
+			// Bars stand on the bottom of the chart
+			if got := pixel(img, 40, 109); got != nrgba(red) {
+				t.Errorf("bottom of the first bar is %v, want %v", got, red)
+			}
+		})
+	}
+}
+
+func TestSparklinePoints(t *testing.T) {
+	sparkline := Sparkline{Position: Position{10, 20}, WidthPx: 100, HeightPx: 50, Values: []float64{3, 7, 1, 9}, Color: red, LineWidthPx: 4}
+
+	points := sparkline.points()
+	if len(points) != 4 {
+		t.Fatalf("%d points, want 4", len(points))
+	}
+
+	// The first value is a quarter of the way up, the last is the maximum
+	first, last := points[0], points[3]
+	if first.X != 10 || first.Y != 20+50*0.75 {
+		t.Errorf("first point %v, want (10, 57.5)", first)
+	}
+
+	if last.X != 110 || last.Y != 20 {
+		t.Errorf("last point %v, want the top-right corner (110, 20)", last)
+	}
+
+	if low := points[2]; low.Y != 70 {
+		t.Errorf("lowest point %v, want it on the bottom edge", low)
+	}
+
+	img := renderRequest(t, ImgRequest{WidthPx: 120, HeightPx: 80, BgColor: white, Sparklines: []Sparkline{sparkline}}, nil)
+	for _, point := range [][2]int{{10, 57}, {110, 20}} {
+		if got := pixel(img, point[0], point[1]); !closeTo(got, nrgba(red), 8) {
+			t.Errorf("pixel %v is %v, want the line", point, got)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+)
+
+// RunCLI renders one ImgRequest read from in, using the same fonts, assets
+// and defaults as the server. The image goes to outputPath, or to out when
+// the path is empty.
+func RunCLI(config Config, in io.Reader, out io.Writer, outputPath string) error {
+	var request ImgRequest
+	if err := json.NewDecoder(in).Decode(&request); err != nil {
+		return err
+	}
+
+	if err := SanitizeText(&request, config.TextSanitization); err != nil {
+		return err
+	}
+
+	fontFaces := BuildFontFaceList(config.FontDir)
+	request.ScriptFonts = LoadScriptFonts(config.ScriptFonts, fontFaces)
+
+	if err := PrepareRequest(&request, fontFaces, BuildAssetList(config.AssetDir), config.Defaults); err != nil {
+		return err
+	}
+
+	image, err := TryGenerateImage(request)
+	if err != nil {
+		return err
+	}
+
+	if outputPath != "" {
+		return os.WriteFile(outputPath, image.Bytes(), 0o644)
+	}
+
+	_, err = out.Write(image.Bytes())
+	return err
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunCLI(t *testing.T) {
+	fonts := newTestFonts(t)
+	config := Config{FontDir: fonts.dir, Defaults: testDefaults}
+
+	request, err := json.Marshal(map[string]any{
+		"widthPx":         80,
+		"heightPx":        40,
+		"bgColor":         red,
+		"format":          "png",
+		"singleLineTexts": []map[string]any{{"text": "Hi", "font": fonts.regular, "position": map[string]any{"x": 5, "y": 30}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		input      string
+		outputPath string
+		ok         bool
+	}{
+		{"stdout", string(request), "", true},
+		{"output file", string(request), filepath.Join(t.TempDir(), "card.png"), true},
+		{"invalid json", "{", "", false},
+		{"invalid request", `{"widthPx": -1, "heightPx": 10}`, "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := new(bytes.Buffer)
+			err := RunCLI(config, strings.NewReader(test.input), out, test.outputPath)
+			if (err == nil) != test.ok {
+				t.Fatalf("RunCLI() error %v, want ok %v", err, test.ok)
+			}
+
+			if !test.ok {
+				return
+			}
+
+			data := out.Bytes()
+			if test.outputPath != "" {
+				if out.Len() > 0 {
+					t.Errorf("%d bytes on stdout with an output file", out.Len())
+				}
+
+				if data, err = os.ReadFile(test.outputPath); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			img := decodeImage(t, data)
+			if got := img.Bounds().Size(); got.X != 80 || got.Y != 40 {
+				t.Errorf("image is %v, want 80x40", got)
+			}
+
+			if got := pixel(img, 70, 5); got != nrgba(red) {
+				t.Errorf("background is %v, want %v", got, red)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ParseHexColor parses "#rgb", "#rrggbb" or "#rrggbbaa", with or without the
+// leading hash.
+func ParseHexColor(value string) (Color, error) {
+	hex := strings.TrimPrefix(value, "#")
+
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+
+	if len(hex) != 8 {
+		return Color{}, fmt.Errorf("invalid hex color %q", value)
+	}
+
+	rgba, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return Color{}, fmt.Errorf("invalid hex color %q", value)
+	}
+
+	return Color{
+		R: uint8(rgba >> 24),
+		G: uint8(rgba >> 16),
+		B: uint8(rgba >> 8),
+		A: uint8(rgba),
+	}, nil
+}
+
+// LoadDefaultBgColor reads DEFAULT_BG_COLOR, falling back to white. Setting it
+// to "none" disables the default so requests without a background fail.
+func LoadDefaultBgColor() *Color {
+	value := os.Getenv("DEFAULT_BG_COLOR")
+
+	if value == "none" {
+		return nil
+	}
+
+	if value == "" {
+		return &Color{255, 255, 255, 255}
+	}
+
+	bgColor, err := ParseHexColor(value)
+	if err != nil {
+		panic(err)
+	}
+
+	return &bgColor
+}
+
+func (c Color) ToRGBA() color.RGBA {
+	return color.RGBA{c.R, c.G, c.B, c.A}
+}
+
+// Hex formats the color as "#rrggbb", adding the alpha byte when it isn't
+// opaque
+func (c Color) Hex() string {
+	if c.A == 255 {
+		return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+	}
+
+	return fmt.Sprintf("#%02x%02x%02x%02x", c.R, c.G, c.B, c.A)
+}
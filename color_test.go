@@ -0,0 +1,60 @@
+package main
+
+import "testing"
+
+func TestParseHexColor(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Color
+		ok    bool
+	}{
+		{"#112233", Color{0x11, 0x22, 0x33, 255}, true},
+		{"112233", Color{0x11, 0x22, 0x33, 255}, true},
+		{"#abc", Color{0xaa, 0xbb, 0xcc, 255}, true},
+		{"#11223380", Color{0x11, 0x22, 0x33, 0x80}, true},
+		{"#12345", Color{}, false},
+		{"#gggggg", Color{}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, err := ParseHexColor(test.value)
+			if (err == nil) != test.ok || got != test.want {
+				t.Errorf("ParseHexColor(%q) = %v, %v, want %v", test.value, got, err, test.want)
+			}
+		})
+	}
+}
+
+func TestBgColorHex(t *testing.T) {
+	server := newTestServer(t, Config{})
+
+	tests := []struct {
+		name   string
+		body   map[string]any
+		status int
+		want   Color
+	}{
+		{"hex", map[string]any{"widthPx": 10, "heightPx": 10, "bgColorHex": "#112233"}, 200, Color{0x11, 0x22, 0x33, 255}},
+		{"hex wins over bgColor", map[string]any{"widthPx": 10, "heightPx": 10, "bgColor": red, "bgColorHex": "#112233"}, 200, Color{0x11, 0x22, 0x33, 255}},
+		{"invalid", map[string]any{"widthPx": 10, "heightPx": 10, "bgColorHex": "#11223"}, 400, Color{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := postJSON(t, server.URL+"/generate", test.body)
+			body := readBody(t, response)
+			if response.StatusCode != test.status {
+				t.Fatalf("status %d, want %d: %s", response.StatusCode, test.status, body)
+			}
+
+			if test.status != 200 {
+				return
+			}
+
+			if got := pixel(decodeImage(t, body), 5, 5); got != nrgba(test.want) {
+				t.Errorf("canvas is %v, want %v", got, test.want)
+			}
+		})
+	}
+}
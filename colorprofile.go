@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"math"
+	"unicode/utf16"
+)
+
+type ColorProfile string
+
+const (
+	SRGB           ColorProfile = "srgb"
+	DisplayP3      ColorProfile = "display-p3"
+	NoColorProfile ColorProfile = "none"
+)
+
+// Primaries are the D50 adapted XYZ columns of each profile, as found in the
+// reference sRGB and Display P3 profiles. Both share the sRGB transfer curve.
+var colorProfilePrimaries = map[ColorProfile]struct {
+	description string
+	red         [3]float64
+	green       [3]float64
+	blue        [3]float64
+}{
+	SRGB: {
+		"sRGB",
+		[3]float64{0.4360747, 0.2225045, 0.0139322},
+		[3]float64{0.3850649, 0.7168786, 0.0971045},
+		[3]float64{0.1430804, 0.0606169, 0.7141733},
+	},
+	DisplayP3: {
+		"Display P3",
+		[3]float64{0.5151215, 0.2411957, -0.0010567},
+		[3]float64{0.2919769, 0.6922454, 0.0418839},
+		[3]float64{0.1571045, 0.0665588, 0.7840728},
+	},
+}
+
+func (profile ColorProfile) Validate(format ImageFormat) error {
+	switch profile {
+	case "", NoColorProfile:
+		return nil
+	case SRGB, DisplayP3:
+		if format != JPEG && format != PNG {
+			return errors.New("colorProfile is only supported for jpeg and png")
+		}
+
+		return nil
+	}
+
+	return errors.New("colorProfile must be srgb, display-p3 or none")
+}
+
+// EmbedColorProfile tags JPEG and PNG output with an ICC profile, sRGB unless
+// another is asked for. Other formats are returned untouched.
+func EmbedColorProfile(data []byte, format ImageFormat, profile ColorProfile) ([]byte, error) {
+	if profile == NoColorProfile || (format != JPEG && format != PNG) {
+		return data, nil
+	}
+
+	if profile == "" {
+		profile = SRGB
+	}
+
+	icc := buildICCProfile(profile)
+
+	if format == JPEG {
+		// A single APP2 segment numbered 1 of 1, the profile is far below 64K
+		payload := append([]byte("ICC_PROFILE\x00\x01\x01"), icc...)
+		return insertJPEGSegment(data, 0xe2, payload)
+	}
+
+	compressed := new(bytes.Buffer)
+	writer := zlib.NewWriter(compressed)
+	writer.Write(icc)
+	writer.Close()
+
+	name := colorProfilePrimaries[profile].description
+	payload := append([]byte(name+"\x00\x00"), compressed.Bytes()...)
+	return insertPNGChunk(data, "iCCP", payload)
+}
+
+// buildICCProfile writes a minimal ICC v4 display profile: primaries, white
+// point, adaptation matrix and a parametric sRGB curve shared by all channels
+func buildICCProfile(profile ColorProfile) []byte {
+	primaries := colorProfilePrimaries[profile]
+
+	xyz := func(values [3]float64) []byte {
+		return appendS15Fixed16([]byte("XYZ \x00\x00\x00\x00"), values[:]...)
+	}
+
+	// Function type 3 with the sRGB constants
+	curve := []byte("para\x00\x00\x00\x00\x00\x03\x00\x00")
+	curve = appendS15Fixed16(curve, 2.4, 1/1.055, 0.055/1.055, 1/12.92, 0.04045)
+
+	// Bradford adaptation from D65 to D50
+	chad := appendS15Fixed16([]byte("sf32\x00\x00\x00\x00"),
+		1.0478112, 0.0228866, -0.0501270,
+		0.0295424, 0.9904844, -0.0170491,
+		-0.0092345, 0.0150436, 0.7521316,
+	)
+
+	tags := []struct {
+		signature string
+		data      []byte
+	}{
+		{"desc", multiLocalizedText(primaries.description)},
+		{"cprt", multiLocalizedText("No copyright, use freely")},
+		{"wtpt", xyz([3]float64{0.9642, 1, 0.8249})},
+		{"chad", chad},
+		{"rXYZ", xyz(primaries.red)},
+		{"gXYZ", xyz(primaries.green)},
+		{"bXYZ", xyz(primaries.blue)},
+		{"rTRC", curve},
+		{"gTRC", curve},
+		{"bTRC", curve},
+	}
+
+	table := binary.BigEndian.AppendUint32(nil, uint32(len(tags)))
+	body := []byte{}
+	dataStart := 128 + 4 + 12*len(tags)
+
+	for _, tag := range tags {
+		table = append(table, tag.signature...)
+		table = binary.BigEndian.AppendUint32(table, uint32(dataStart+len(body)))
+		table = binary.BigEndian.AppendUint32(table, uint32(len(tag.data)))
+
+		// Tag data starts on a 4 byte boundary
+		body = append(body, tag.data...)
+		for len(body)%4 != 0 {
+			body = append(body, 0)
+		}
+	}
+
+	header := make([]byte, 128)
+	binary.BigEndian.PutUint32(header[0:], uint32(dataStart+len(body)))
+	binary.BigEndian.PutUint32(header[8:], 0x04300000)
+	copy(header[12:], "mntrRGB XYZ ")
+	// A fixed creation date keeps the output byte for byte reproducible
+	for i, value := range []uint16{2024, 1, 1, 0, 0, 0} {
+		binary.BigEndian.PutUint16(header[24+2*i:], value)
+	}
+	copy(header[36:], "acsp")
+	copy(header[68:], appendS15Fixed16(nil, 0.9642, 1, 0.8249))
+
+	profileData := append(header, table...)
+	return append(profileData, body...)
+}
+
+// multiLocalizedText is an mluc tag holding a single en-US string
+func multiLocalizedText(text string) []byte {
+	encoded := utf16.Encode([]rune(text))
+
+	data := []byte("mluc\x00\x00\x00\x00")
+	data = binary.BigEndian.AppendUint32(data, 1)
+	data = binary.BigEndian.AppendUint32(data, 12)
+	data = append(data, "enUS"...)
+	data = binary.BigEndian.AppendUint32(data, uint32(2*len(encoded)))
+	data = binary.BigEndian.AppendUint32(data, 28)
+	for _, unit := range encoded {
+		data = binary.BigEndian.AppendUint16(data, unit)
+	}
+
+	return data
+}
+
+func appendS15Fixed16(data []byte, values ...float64) []byte {
+	for _, value := range values {
+		data = binary.BigEndian.AppendUint32(data, uint32(int32(math.Round(value*65536))))
+	}
+
+	return data
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+	"testing"
+	"unicode/utf16"
+)
+
+// iccProfile returns the ICC profile embedded in a JPEG APP2 segment or PNG
+// iCCP chunk, nil when there is none
+func iccProfile(t *testing.T, data []byte, format ImageFormat) []byte {
+	t.Helper()
+
+	if format == JPEG {
+		for offset := 2; offset+4 <= len(data) && data[offset] == 0xff; {
+			marker := data[offset+1]
+			if marker == 0xda {
+				break
+			}
+
+			length := int(binary.BigEndian.Uint16(data[offset+2:]))
+			segment := data[offset+4 : offset+2+length]
+			if marker == 0xe2 && bytes.HasPrefix(segment, []byte("ICC_PROFILE\x00")) {
+				return segment[14:]
+			}
+			offset += 2 + length
+		}
+
+		return nil
+	}
+
+	for offset := 8; offset+12 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[offset:]))
+		if string(data[offset+4:offset+8]) == "iCCP" {
+			// Profile name, separator and compression method come first
+			_, compressed, _ := bytes.Cut(data[offset+8:offset+8+length], []byte{0})
+			reader, err := zlib.NewReader(bytes.NewReader(compressed[1:]))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			profile, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			return profile
+		}
+		offset += 12 + length
+	}
+
+	return nil
+}
+
+// utf16BE encodes text the way ICC mluc descriptions are stored
+func utf16BE(text string) []byte {
+	encoded := []byte{}
+	for _, unit := range utf16.Encode([]rune(text)) {
+		encoded = binary.BigEndian.AppendUint16(encoded, unit)
+	}
+
+	return encoded
+}
+
+func TestEmbedColorProfile(t *testing.T) {
+	tests := []struct {
+		format      ImageFormat
+		profile     ColorProfile
+		description string
+	}{
+		{PNG, "", "sRGB"},
+		{PNG, DisplayP3, "Display P3"},
+		{PNG, NoColorProfile, ""},
+		{JPEG, SRGB, "sRGB"},
+		{JPEG, DisplayP3, "Display P3"},
+		{JPEG, NoColorProfile, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.format)+" "+string(test.profile), func(t *testing.T) {
+			request := ImgRequest{WidthPx: 20, HeightPx: 20, BgColor: red, Format: test.format, ColorProfile: test.profile}
+			data := generateRequest(t, request, nil)
+
+			if got := pixel(decodeImage(t, data), 10, 10); !closeTo(got, nrgba(red), 4) {
+				t.Errorf("tagged image is %v, want %v", got, red)
+			}
+
+			profile := iccProfile(t, data, test.format)
+			if test.description == "" {
+				if profile != nil {
+					t.Error("profile embedded with colorProfile none")
+				}
+				return
+			}
+
+			if len(profile) < 128 || int(binary.BigEndian.Uint32(profile)) != len(profile) || string(profile[36:40]) != "acsp" {
+				t.Fatalf("embedded %d bytes, want a whole ICC profile", len(profile))
+			}
+
+			if !bytes.Contains(profile, utf16BE(test.description)) {
+				t.Errorf("profile is not described as %q", test.description)
+			}
+		})
+	}
+}
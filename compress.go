@@ -0,0 +1,94 @@
+package main
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// acceptedEncoding picks gzip or deflate from an Accept-Encoding header,
+// preferring gzip
+func acceptedEncoding(header string) string {
+	accepted := map[string]bool{}
+
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.ReplaceAll(strings.TrimSpace(params), " ", "") == "q=0" {
+			continue
+		}
+
+		accepted[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+
+	for _, encoding := range []string{"gzip", "deflate"} {
+		if accepted[encoding] {
+			return encoding
+		}
+	}
+
+	return ""
+}
+
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding   string // empty when the client accepts neither
+	compressor io.WriteCloser
+	decided    bool
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	// Decide on the first write, once the handler has set the content type
+	if !w.decided {
+		w.decided = true
+
+		// Only JSON depends on Accept-Encoding, so only JSON varies on it
+		if strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			switch w.encoding {
+			case "gzip":
+				w.compressor = gzip.NewWriter(w.ResponseWriter)
+			case "deflate":
+				// HTTP deflate is the zlib format, not raw deflate
+				w.compressor = zlib.NewWriter(w.ResponseWriter)
+			}
+
+			if w.compressor != nil {
+				w.Header().Set("Content-Encoding", w.encoding)
+				w.Header().Del("Content-Length")
+			}
+		}
+	}
+
+	if w.compressor == nil {
+		return w.ResponseWriter.Write(data)
+	}
+
+	return w.compressor.Write(data)
+}
+
+func (w *compressWriter) WriteString(data string) (int, error) {
+	return w.Write([]byte(data))
+}
+
+// Compress gzip or deflate encodes JSON responses, such as batch results with
+// base64 images, for clients that accept it. Image bytes are already
+// compressed and pass through untouched.
+func Compress() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		encoding := acceptedEncoding(c.GetHeader("Accept-Encoding"))
+
+		writer := &compressWriter{ResponseWriter: c.Writer, encoding: encoding}
+		c.Writer = writer
+		defer func() {
+			if writer.compressor != nil {
+				writer.compressor.Close()
+			}
+		}()
+
+		c.Next()
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io"
+	"testing"
+)
+
+func TestAcceptedEncoding(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"deflate, gzip;q=0.5", "gzip"},
+		{"gzip;q=0, deflate", "deflate"},
+		{"br", ""},
+	}
+
+	for _, test := range tests {
+		if got := acceptedEncoding(test.header); got != test.want {
+			t.Errorf("acceptedEncoding(%q) = %q, want %q", test.header, got, test.want)
+		}
+	}
+}
+
+func TestCompressJSON(t *testing.T) {
+	server := newTestServer(t, Config{})
+	batch := BatchRequest{Requests: []ImgRequest{{WidthPx: 200, HeightPx: 100, BgColor: red, Format: PNG}}}
+
+	tests := []struct {
+		name     string
+		accept   string
+		encoding string
+		decode   func(io.Reader) (io.Reader, error)
+	}{
+		{"gzip", "gzip", "gzip", func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }},
+		{"deflate is zlib", "deflate", "deflate", func(r io.Reader) (io.Reader, error) { return zlib.NewReader(r) }},
+		{"identity", "", "", func(r io.Reader) (io.Reader, error) { return r, nil }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := postJSON(t, server.URL+"/generate/batch", batch, "Accept-Encoding", test.accept)
+			body := readBody(t, response)
+			if response.StatusCode != 200 {
+				t.Fatalf("status %d: %s", response.StatusCode, body)
+			}
+
+			if got := response.Header.Get("Content-Encoding"); got != test.encoding {
+				t.Errorf("Content-Encoding %q, want %q", got, test.encoding)
+			}
+
+			// The JSON representation depends on the header even when sent as is
+			if got := response.Header.Get("Vary"); got != "Accept-Encoding" {
+				t.Errorf("Vary %q, want Accept-Encoding", got)
+			}
+
+			reader, err := test.decode(bytes.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var results struct {
+				Images []BatchResult `json:"images"`
+			}
+			if err := json.NewDecoder(reader).Decode(&results); err != nil {
+				t.Fatalf("body is not valid JSON after decoding: %v", err)
+			}
+
+			if len(results.Images) != 1 || results.Images[0].ContentType != "image/png" {
+				t.Errorf("results %+v, want one png", results.Images)
+			}
+		})
+	}
+
+	// Image bytes pass through untouched and don't vary on the header
+	response := postJSON(t, server.URL+"/generate", batch.Requests[0], "Accept-Encoding", "gzip")
+	body := readBody(t, response)
+	if got := response.Header.Get("Content-Encoding"); got != "" {
+		t.Errorf("image Content-Encoding %q, want none", got)
+	}
+
+	if got := response.Header.Get("Vary"); got != "" {
+		t.Errorf("image Vary %q, want none", got)
+	}
+
+	decodeImage(t, body)
+}
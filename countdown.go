@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fogleman/gg"
+)
+
+// Countdown draws each value zero padded to Digits in its own rounded box, in
+// the flip clock style of event banners, with the matching label centered
+// below the box. Separator is drawn in the gap between boxes.
+type Countdown struct {
+	Position       Position `json:"position"`
+	Values         []int    `json:"values"`
+	Labels         []string `json:"labels"` // optional, one per value
+	Digits         int      `json:"digits" default:"2"`
+	BoxWidthPx     float64  `json:"boxWidthPx"`
+	BoxHeightPx    float64  `json:"boxHeightPx"`
+	GapPx          float64  `json:"gapPx" default:"16"`
+	CornerRadiusPx float64  `json:"cornerRadiusPx" default:"8"`
+	BoxColor       Color    `json:"boxColor"`
+	SplitColor     Color    `json:"splitColor"` // line across the middle of each box, none when omitted
+	Separator      string   `json:"separator"`  // such as ":"
+	Font           string   `json:"font"`
+	SizePx         float64  `json:"sizePx"`
+	Color          Color    `json:"color"`
+	LabelSizePx    float64  `json:"labelSizePx"` // a third of SizePx when omitted
+	LabelColor     Color    `json:"labelColor"`  // Color when omitted
+	Visibility
+}
+
+func (countdown Countdown) Validate() error {
+	if len(countdown.Values) == 0 {
+		return errors.New("countdown needs at least one value")
+	}
+
+	if len(countdown.Labels) > 0 && len(countdown.Labels) != len(countdown.Values) {
+		return errors.New("countdown needs one label per value")
+	}
+
+	// Values are zero padded to this width, so it must stay small. 0 means 2.
+	if countdown.Digits < 0 || countdown.Digits > 9 {
+		return errors.New("countdown digits must be between 0 and 9")
+	}
+
+	if countdown.BoxWidthPx <= 0 || countdown.BoxHeightPx <= 0 {
+		return errors.New("countdown needs boxWidthPx and boxHeightPx")
+	}
+
+	for _, value := range countdown.Values {
+		if value < 0 {
+			return errors.New("countdown values can't be negative")
+		}
+	}
+
+	return nil
+}
+
+func DrawCountdown(dc *gg.Context, countdown Countdown) {
+	digits := countdown.Digits
+	if digits == 0 {
+		digits = 2
+	}
+
+	gap := countdown.GapPx
+	if gap == 0 {
+		gap = 16
+	}
+
+	radius := countdown.CornerRadiusPx
+	if radius == 0 {
+		radius = 8
+	}
+
+	labelSize := countdown.LabelSizePx
+	if labelSize == 0 {
+		labelSize = countdown.SizePx / 3
+	}
+
+	labelColor := countdown.LabelColor
+	if labelColor == (Color{}) {
+		labelColor = countdown.Color
+	}
+
+	width, height := countdown.BoxWidthPx, countdown.BoxHeightPx
+	texts := []StyledText{}
+
+	for i, value := range countdown.Values {
+		x := countdown.Position.X + float64(i)*(width+gap)
+		y := countdown.Position.Y
+
+		dc.DrawRoundedRectangle(x, y, width, height, radius)
+		dc.SetColor(countdown.BoxColor.ToRGBA())
+		dc.Fill()
+
+		if countdown.SplitColor != (Color{}) {
+			dc.SetColor(countdown.SplitColor.ToRGBA())
+			dc.SetLineWidth(1)
+			dc.DrawLine(x, y+height/2, x+width, y+height/2)
+			dc.Stroke()
+		}
+
+		texts = append(texts, StyledText{
+			Text:     fmt.Sprintf("%0*d", digits, value),
+			Font:     countdown.Font,
+			SizePx:   pixels(countdown.SizePx),
+			Color:    countdown.Color,
+			Position: textPosition(Position{x + width/2, y + height/2}),
+		})
+
+		if i > 0 && countdown.Separator != "" {
+			texts = append(texts, StyledText{
+				Text:     countdown.Separator,
+				Font:     countdown.Font,
+				SizePx:   pixels(countdown.SizePx),
+				Color:    countdown.Color,
+				Position: textPosition(Position{x - gap/2, y + height/2}),
+			})
+		}
+
+		if len(countdown.Labels) > 0 && countdown.Labels[i] != "" {
+			texts = append(texts, StyledText{
+				Text:     countdown.Labels[i],
+				Font:     countdown.Font,
+				SizePx:   pixels(labelSize),
+				Color:    labelColor,
+				Position: textPosition(Position{x + width/2, y + height + labelSize}),
+			})
+		}
+	}
+
+	// Digits, separators and labels are centered on their position
+	for _, text := range texts {
+		DrawText(dc, text, func(dc *gg.Context, scale float64) {
+			dc.DrawStringAnchored(text.Text, text.Position.X.Value*scale, text.Position.Y.Value*scale, 0.5, 0.35)
+		})
+	}
+}
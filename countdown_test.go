@@ -0,0 +1,118 @@
+package main
+
+import (
+	"image"
+	"testing"
+)
+
+func TestCountdownBoxes(t *testing.T) {
+	fonts := newTestFonts(t)
+	navy := Color{20, 30, 80, 255}
+	grey := Color{128, 128, 128, 255}
+
+	countdown := func(position Position, values ...int) Countdown {
+		return Countdown{
+			Position:    position,
+			Values:      values,
+			BoxWidthPx:  60,
+			BoxHeightPx: 50,
+			GapPx:       20,
+			BoxColor:    navy,
+			Font:        fonts.regular,
+			SizePx:      28,
+			Color:       white,
+		}
+	}
+
+	render := func(countdown Countdown) image.Image {
+		return renderRequest(t, ImgRequest{WidthPx: 260, HeightPx: 90, BgColor: grey, Countdowns: []Countdown{countdown}}, fonts.faces())
+	}
+
+	full := countdown(Position{10, 10}, 2, 15, 30)
+	full.Separator = ":"
+	full.Labels = []string{"hours", "minutes", "seconds"}
+	img := render(full)
+
+	tests := []struct {
+		text  string
+		value int
+		x     float64
+	}{
+		{"02", 2, 10},
+		{"15", 15, 90},
+		{"30", 30, 170},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			box := image.Rect(int(test.x), 10, int(test.x)+60, 60)
+
+			// Inside the rounded corners the box is filled
+			for _, point := range []image.Point{{box.Min.X + 4, box.Min.Y + 4}, {box.Max.X - 5, box.Max.Y - 5}} {
+				if got := pixel(img, point.X, point.Y); got != nrgba(navy) {
+					t.Errorf("box pixel %v is %v, want %v", point, got, navy)
+				}
+			}
+
+			// The digits match a countdown of just this value in the same place
+			single, other := render(countdown(Position{test.x, 10}, test.value)), render(countdown(Position{test.x, 10}, test.value+1))
+			same, differs := true, false
+			for y := box.Min.Y; y < box.Max.Y; y++ {
+				for x := box.Min.X; x < box.Max.X; x++ {
+					same = same && pixel(img, x, y) == pixel(single, x, y)
+					differs = differs || pixel(img, x, y) != pixel(other, x, y)
+				}
+			}
+
+			if !same {
+				t.Errorf("box does not show %s", test.text)
+			}
+
+			if !differs {
+				t.Errorf("box looks the same as %d", test.value+1)
+			}
+
+			// Labels sit below the boxes
+			if label := inkIn(img, image.Rect(box.Min.X, box.Max.Y+1, box.Max.X, 90)); label.Empty() {
+				t.Error("no label below the box")
+			}
+		})
+	}
+
+	// Separators are drawn in the gaps, which are otherwise the background
+	for _, gap := range []image.Rectangle{image.Rect(70, 10, 90, 60), image.Rect(150, 10, 170, 60)} {
+		if ink := inkIn(img, gap); ink.Empty() || ink.Dx() > 10 {
+			t.Errorf("gap %v has ink %v, want a separator", gap, ink)
+		}
+	}
+}
+
+func TestCountdownValidate(t *testing.T) {
+	valid := Countdown{Values: []int{1}, BoxWidthPx: 10, BoxHeightPx: 10}
+
+	tests := []struct {
+		name   string
+		change func(*Countdown)
+		ok     bool
+	}{
+		{"valid", func(*Countdown) {}, true},
+		{"nine digits", func(countdown *Countdown) { countdown.Digits = 9 }, true},
+		{"ten digits", func(countdown *Countdown) { countdown.Digits = 10 }, false},
+		{"huge digits", func(countdown *Countdown) { countdown.Digits = 1 << 30 }, false},
+		{"negative digits", func(countdown *Countdown) { countdown.Digits = -1 }, false},
+		{"negative value", func(countdown *Countdown) { countdown.Values = []int{-1} }, false},
+		{"label count", func(countdown *Countdown) { countdown.Labels = []string{"a", "b"} }, false},
+		{"no box size", func(countdown *Countdown) { countdown.BoxWidthPx = 0 }, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			countdown := valid
+			test.change(&countdown)
+
+			if err := countdown.Validate(); (err == nil) != test.ok {
+				t.Errorf("Validate() = %v, want ok %v", err, test.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"os"
+	"strconv"
+)
+
+// Defaults are server-wide values filled into requests that leave them out
+type Defaults struct {
+	BgColor    *Color // nil disables the default background
+	TextColor  Color
+	TextSizePx float64
+	Format     ImageFormat
+	Quality    int // 0 leaves it to the encoder
+}
+
+// LoadDefaults reads the DEFAULT_* environment variables. Text falls back to
+// black at 24px and the format to jpeg.
+func LoadDefaults() Defaults {
+	defaults := Defaults{
+		BgColor:    LoadDefaultBgColor(),
+		TextColor:  Color{0, 0, 0, 255},
+		TextSizePx: 24,
+		Format:     JPEG,
+	}
+
+	if value := os.Getenv("DEFAULT_FORMAT"); value != "" {
+		if _, ok := ContentType(ImageFormat(value)); !ok {
+			panic("DEFAULT_FORMAT is not a supported format")
+		}
+
+		defaults.Format = ImageFormat(value)
+	}
+
+	if value := os.Getenv("DEFAULT_QUALITY"); value != "" {
+		quality, err := strconv.Atoi(value)
+		if err != nil || quality < 1 || quality > 100 {
+			panic("DEFAULT_QUALITY must be between 1 and 100")
+		}
+
+		defaults.Quality = quality
+	}
+
+	if value := os.Getenv("DEFAULT_TEXT_COLOR"); value != "" {
+		textColor, err := ParseHexColor(value)
+		if err != nil {
+			panic(err)
+		}
+
+		defaults.TextColor = textColor
+	}
+
+	if value := os.Getenv("DEFAULT_TEXT_SIZE"); value != "" {
+		size, err := strconv.ParseFloat(value, 64)
+		if err != nil || size <= 0 {
+			panic("DEFAULT_TEXT_SIZE must be a positive number")
+		}
+
+		defaults.TextSizePx = size
+	}
+
+	return defaults
+}
+
+func (defaults Defaults) applyText(color *Color, sizePx *float64) {
+	if *color == (Color{}) {
+		*color = defaults.TextColor
+	}
+
+	if *sizePx == 0 {
+		*sizePx = defaults.TextSizePx
+	}
+}
+
+// Apply fills the defaults into every element of the request that omits them
+func (defaults Defaults) Apply(request *ImgRequest) {
+	if !request.Transparent && request.BgImgPath == "" && request.BgImgBase64 == "" && request.BgGradient == nil && request.BgCheckerboard == nil && request.BgColor == (Color{}) && defaults.BgColor != nil {
+		request.BgColor = *defaults.BgColor
+	}
+
+	if request.Quality == 0 {
+		request.Quality = defaults.Quality
+	}
+
+	for i := range request.SingleLineTexts {
+		text := &request.SingleLineTexts[i]
+		defaults.applyText(&text.Color, &text.SizePx.Value)
+	}
+
+	for i := range request.TextStacks {
+		for j := range request.TextStacks[i].Items {
+			item := &request.TextStacks[i].Items[j]
+			defaults.applyText(&item.Color, &item.SizePx.Value)
+		}
+	}
+
+	for i := range request.MultiLineTexts {
+		text := &request.MultiLineTexts[i]
+		defaults.applyText(&text.Color, &text.SizePx.Value)
+	}
+
+	for i := range request.BarCharts {
+		chart := &request.BarCharts[i]
+		defaults.applyText(&chart.LabelColor, &chart.SizePx)
+	}
+
+	for i := range request.Callouts {
+		callout := &request.Callouts[i]
+		defaults.applyText(&callout.TextColor, &callout.SizePx)
+	}
+
+	for i := range request.Countdowns {
+		countdown := &request.Countdowns[i]
+		defaults.applyText(&countdown.Color, &countdown.SizePx)
+	}
+
+	for i := range request.Markdowns {
+		markdown := &request.Markdowns[i]
+		defaults.applyText(&markdown.Color, &markdown.SizePx)
+	}
+
+	for i := range request.RichTexts {
+		for j := range request.RichTexts[i].Spans {
+			span := &request.RichTexts[i].Spans[j]
+			defaults.applyText(&span.Color, &span.SizePx)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"image/color"
+	"maps"
+	"testing"
+)
+
+func TestDefaultBackground(t *testing.T) {
+	tests := []struct {
+		name   string
+		env    string
+		status int
+		want   color.NRGBA
+	}{
+		{"white when unset", "", 200, nrgba(white)},
+		{"configured color", "#112233", 200, color.NRGBA{0x11, 0x22, 0x33, 255}},
+		{"none disables the default", "none", 400, color.NRGBA{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("DEFAULT_BG_COLOR", test.env)
+			server := newTestServer(t, Config{Defaults: LoadDefaults()})
+
+			response := postJSON(t, server.URL+"/generate", map[string]any{"widthPx": 20, "heightPx": 10, "format": "png"})
+			body := readBody(t, response)
+			if response.StatusCode != test.status {
+				t.Fatalf("status %d, want %d: %s", response.StatusCode, test.status, body)
+			}
+
+			if test.status != 200 {
+				return
+			}
+
+			if got := pixel(decodeImage(t, body), 10, 5); got != test.want {
+				t.Errorf("canvas is %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDefaultText(t *testing.T) {
+	fonts := newTestFonts(t)
+
+	tests := []struct {
+		name        string
+		color, size string
+		want        Color
+		wantSizePx  float64
+	}{
+		{"black 24px when unset", "", "", black, 24},
+		{"configured", "#ff0000", "40", red, 40},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("DEFAULT_TEXT_COLOR", test.color)
+			t.Setenv("DEFAULT_TEXT_SIZE", test.size)
+			t.Setenv("DEFAULT_FORMAT", "png")
+			server := newTestServer(t, Config{FontDir: fonts.dir, Defaults: LoadDefaults()})
+
+			text := StyledText{Text: "Hi", Font: fonts.regular, Position: textPosition(Position{10, 50})}
+			response := postJSON(t, server.URL+"/generate", ImgRequest{WidthPx: 100, HeightPx: 60, SingleLineTexts: []StyledText{text}})
+			body := readBody(t, response)
+			if response.StatusCode != 200 {
+				t.Fatalf("status %d: %s", response.StatusCode, body)
+			}
+
+			// Identical to spelling the defaults out
+			text.Color, text.SizePx = test.want, pixels(test.wantSizePx)
+			explicit := renderRequest(t, ImgRequest{WidthPx: 100, HeightPx: 60, SingleLineTexts: []StyledText{text}}, fonts.faces())
+
+			img := decodeImage(t, body)
+			if colorsOf(img)[nrgba(test.want)] == 0 {
+				t.Fatalf("no %v text pixels", test.want)
+			}
+
+			for y := 0; y < 60; y++ {
+				for x := 0; x < 100; x++ {
+					if got, want := pixel(img, x, y), pixel(explicit, x, y); got != want {
+						t.Fatalf("pixel (%d, %d) is %v, want %v", x, y, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDefaultQualityAndFormat(t *testing.T) {
+	t.Setenv("DEFAULT_QUALITY", "30")
+	t.Setenv("DEFAULT_FORMAT", "jpeg")
+	server := newTestServer(t, Config{Defaults: LoadDefaults()})
+
+	// A gradient so the quality shows in the encoded bytes
+	request := func(extra map[string]any) map[string]any {
+		body := map[string]any{
+			"widthPx":    64,
+			"heightPx":   64,
+			"bgGradient": map[string]any{"start": map[string]any{"x": 0, "y": 0}, "end": map[string]any{"x": 64, "y": 64}, "stops": []map[string]any{{"offset": 0, "color": red}, {"offset": 1, "color": blue}}},
+		}
+		maps.Copy(body, extra)
+		return body
+	}
+
+	generate := func(extra map[string]any) (string, []byte) {
+		response := postJSON(t, server.URL+"/generate", request(extra))
+		body := readBody(t, response)
+		if response.StatusCode != 200 {
+			t.Fatalf("status %d: %s", response.StatusCode, body)
+		}
+
+		return response.Header.Get("Content-Type"), body
+	}
+
+	contentType, omitted := generate(nil)
+	if contentType != "image/jpeg" {
+		t.Errorf("Content-Type is %q, want the default image/jpeg", contentType)
+	}
+
+	tests := []struct {
+		name  string
+		extra map[string]any
+		same  bool
+	}{
+		{"default quality", map[string]any{"quality": 30}, true},
+		{"request quality overrides", map[string]any{"quality": 95}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, data := generate(test.extra); bytes.Equal(data, omitted) != test.same {
+				t.Errorf("quality %v matching the omitted quality is %v, want %v", test.extra["quality"], !test.same, test.same)
+			}
+		})
+	}
+
+	if contentType, _ := generate(map[string]any{"format": "png"}); contentType != "image/png" {
+		t.Errorf("Content-Type is %q, want the requested image/png", contentType)
+	}
+}
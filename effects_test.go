@@ -0,0 +1,109 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// noiseImage alternates black and white pixels, the worst case for local
+// variance
+func noiseImage(width, height int) *image.NRGBA {
+	img := solidImage(width, height, white)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if (x+y)%2 == 0 {
+				img.SetNRGBA(x, y, nrgba(black))
+			}
+		}
+	}
+
+	return img
+}
+
+// variance of the green channel of img inside rect
+func variance(img image.Image, rect image.Rectangle) float64 {
+	var sum, squares float64
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			v := float64(pixel(img, x, y).G)
+			sum += v
+			squares += v * v
+		}
+	}
+
+	count := float64(rect.Dx() * rect.Dy())
+	mean := sum / count
+	return squares/count - mean*mean
+}
+
+func TestBlurRegion(t *testing.T) {
+	original := noiseImage(60, 40)
+	region := image.Rect(10, 10, 30, 30)
+	blurred := ApplyBlurRegions(original, []BlurRegion{{Position: Position{10, 10}, WidthPx: 20, HeightPx: 20, Radius: 2}})
+
+	inside := region.Inset(3)
+	if before, after := variance(original, inside), variance(blurred, inside); after > before/10 {
+		t.Errorf("variance inside the region went from %.0f to %.0f, want it mostly gone", before, after)
+	}
+
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 60; x++ {
+			if image.Pt(x, y).In(region) {
+				continue
+			}
+
+			if got, want := pixel(blurred, x, y), pixel(original, x, y); got != want {
+				t.Fatalf("pixel (%d, %d) outside the region is %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+// averageColor is the rounded mean of every channel of img inside rect
+func averageColor(img image.Image, rect image.Rectangle) color.NRGBA {
+	var sum [4]int
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			c := pixel(img, x, y)
+			sum[0], sum[1], sum[2], sum[3] = sum[0]+int(c.R), sum[1]+int(c.G), sum[2]+int(c.B), sum[3]+int(c.A)
+		}
+	}
+
+	count := rect.Dx() * rect.Dy()
+	round := func(total int) uint8 { return uint8((total + count/2) / count) }
+	return color.NRGBA{round(sum[0]), round(sum[1]), round(sum[2]), round(sum[3])}
+}
+
+func TestPixelateRegion(t *testing.T) {
+	// Every pixel differs, so each block average is distinct
+	original := image.NewNRGBA(image.Rect(0, 0, 40, 30))
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 40; x++ {
+			original.SetNRGBA(x, y, color.NRGBA{uint8(x * 6), uint8(y * 8), uint8((x + y) * 3), 255})
+		}
+	}
+
+	// 25px wide, so the last column of blocks is clipped to 5px
+	pixelated := ApplyPixelateRegions(original, []PixelateRegion{{Position: Position{5, 5}, WidthPx: 25, HeightPx: 20, BlockSizePx: 10}})
+
+	blocks := []image.Rectangle{
+		image.Rect(5, 5, 15, 15), image.Rect(15, 5, 25, 15), image.Rect(25, 5, 30, 15),
+		image.Rect(5, 15, 15, 25), image.Rect(15, 15, 25, 25), image.Rect(25, 15, 30, 25),
+	}
+
+	for _, block := range blocks {
+		want := averageColor(original, block)
+		for y := block.Min.Y; y < block.Max.Y; y++ {
+			for x := block.Min.X; x < block.Max.X; x++ {
+				if got := pixel(pixelated, x, y); got != want {
+					t.Fatalf("pixel (%d, %d) of block %v is %v, want the average %v", x, y, block, got, want)
+				}
+			}
+		}
+	}
+
+	if got, want := pixel(pixelated, 35, 28), pixel(original, 35, 28); got != want {
+		t.Errorf("pixel outside the region is %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+)
+
+type ImageFormat string
+
+const (
+	JPEG ImageFormat = "jpeg"
+	PNG  ImageFormat = "png"
+	TIFF ImageFormat = "tiff"
+	BMP  ImageFormat = "bmp"
+	AVIF ImageFormat = "avif"
+	ICO  ImageFormat = "ico"
+)
+
+var contentTypes = map[ImageFormat]string{
+	JPEG: "image/jpeg",
+	PNG:  "image/png",
+	TIFF: "image/tiff",
+	BMP:  "image/bmp",
+	AVIF: "image/avif",
+	ICO:  "image/x-icon",
+}
+
+var errAVIFUnavailable = errors.New("avif encoder not available in this build, rebuild with -tags avif")
+
+func ContentType(format ImageFormat) (string, bool) {
+	contentType, ok := contentTypes[format]
+	return contentType, ok
+}
+
+// SupportsAlpha reports whether format keeps transparency
+func SupportsAlpha(format ImageFormat) bool {
+	return format != JPEG
+}
+
+func EncodeImage(w io.Writer, img image.Image, format ImageFormat, quality int) error {
+	switch format {
+	case JPEG:
+		if quality == 0 {
+			quality = jpeg.DefaultQuality
+		}
+
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+	case PNG:
+		return png.Encode(w, img)
+	case TIFF:
+		// Deflate keeps the output lossless while staying reasonably small
+		return tiff.Encode(w, img, &tiff.Options{Compression: tiff.Deflate, Predictor: true})
+	case BMP:
+		return bmp.Encode(w, img)
+	case AVIF:
+		return encodeAVIF(w, img, quality)
+	case ICO:
+		return encodeICO(w, img)
+	}
+
+	return fmt.Errorf("unsupported format %q", format)
+}
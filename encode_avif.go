@@ -0,0 +1,20 @@
+//go:build avif
+
+package main
+
+import (
+	"image"
+	"io"
+
+	"github.com/gen2brain/avif"
+)
+
+const avifAvailable = true
+
+func encodeAVIF(w io.Writer, img image.Image, quality int) error {
+	if quality == 0 {
+		quality = avif.DefaultQuality
+	}
+
+	return avif.Encode(w, img, avif.Options{Quality: quality, QualityAlpha: quality, Speed: avif.DefaultSpeed})
+}
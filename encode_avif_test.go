@@ -0,0 +1,32 @@
+//go:build avif
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gen2brain/avif"
+)
+
+func TestAVIFRoundTrip(t *testing.T) {
+	img := renderRequest(t, ImgRequest{WidthPx: 48, HeightPx: 32, BgColor: red}, nil)
+
+	buff := new(bytes.Buffer)
+	if err := EncodeImage(buff, img, AVIF, 90); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := avif.Decode(bytes.NewReader(buff.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if size := decoded.Bounds().Size(); size.X != 48 || size.Y != 32 {
+		t.Fatalf("size %v, want 48x32", size)
+	}
+
+	if got := pixel(decoded, 24, 16); !closeTo(got, nrgba(red), 16) {
+		t.Errorf("center is %v, want red", got)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !avif
+
+package main
+
+import (
+	"image"
+	"io"
+)
+
+const avifAvailable = false
+
+func encodeAVIF(w io.Writer, img image.Image, quality int) error {
+	return errAVIFUnavailable
+}
@@ -0,0 +1,16 @@
+//go:build !avif
+
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAVIFUnavailable(t *testing.T) {
+	request := ImgRequest{WidthPx: 48, HeightPx: 32, BgColor: red, Format: AVIF}
+
+	if err := PrepareRequest(&request, nil, nil, testDefaults); !errors.Is(err, errAVIFUnavailable) {
+		t.Fatalf("got %v, want %v", err, errAVIFUnavailable)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestEncodeLosslessFormats(t *testing.T) {
+	request := ImgRequest{
+		WidthPx:  64,
+		HeightPx: 32,
+		BgGradient: &Gradient{
+			Start: Position{0, 0},
+			End:   Position{64, 0},
+			Stops: []ColorStop{{0, red}, {1, blue}},
+		},
+	}
+	img := renderRequest(t, request, nil)
+
+	tests := []struct {
+		name   string
+		format ImageFormat
+	}{
+		{"tiff", TIFF},
+		{"bmp", BMP},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buff := new(bytes.Buffer)
+			if err := EncodeImage(buff, img, test.format, 0); err != nil {
+				t.Fatal(err)
+			}
+
+			decoded := decodeImage(t, buff.Bytes())
+			if decoded.Bounds().Size() != img.Bounds().Size() {
+				t.Fatalf("size %v, want %v", decoded.Bounds().Size(), img.Bounds().Size())
+			}
+
+			for y := 0; y < 32; y++ {
+				for x := 0; x < 64; x++ {
+					if got, want := pixel(decoded, x, y), pixel(img, x, y); got != want {
+						t.Fatalf("pixel (%d, %d) is %v, want %v", x, y, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestTransparentBackground(t *testing.T) {
+	request := ImgRequest{
+		WidthPx:     40,
+		HeightPx:    40,
+		Transparent: true,
+		Circles:     []Circle{{Center: Position{20, 20}, RadiusPx: 10, FillColor: red}},
+	}
+	img := decodeImage(t, generateRequest(t, request, nil))
+
+	for _, corner := range [][2]int{{0, 0}, {39, 0}, {0, 39}, {39, 39}} {
+		if got := pixel(img, corner[0], corner[1]); got.A != 0 {
+			t.Errorf("corner %v has alpha %d, want 0", corner, got.A)
+		}
+	}
+
+	if got := pixel(img, 20, 20); got != nrgba(red) {
+		t.Errorf("center is %v, want %v", got, red)
+	}
+
+	request.Format = JPEG
+	if err := PrepareRequest(&request, nil, nil, testDefaults); err == nil {
+		t.Error("transparent JPEG was accepted")
+	}
+}
+
+func TestEncodeICO(t *testing.T) {
+	request := ImgRequest{WidthPx: 64, HeightPx: 64, BgColor: red, Format: ICO}
+	data := generateRequest(t, request, nil)
+
+	var header struct{ Reserved, Type, Count uint16 }
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, &header); err != nil {
+		t.Fatal(err)
+	}
+
+	if header.Type != 1 || int(header.Count) != len(icoSizes) {
+		t.Fatalf("type %d with %d images, want an icon with %d", header.Type, header.Count, len(icoSizes))
+	}
+
+	for i, want := range []int{16, 32, 48} {
+		entry := data[6+16*i:]
+		size := binary.LittleEndian.Uint32(entry[8:])
+		offset := binary.LittleEndian.Uint32(entry[12:])
+
+		if width, height := int(entry[0]), int(entry[1]); width != want || height != want {
+			t.Errorf("entry %d is %dx%d, want %dx%d", i, width, height, want, want)
+		}
+
+		img := decodeImage(t, data[offset:offset+size])
+		if got := img.Bounds().Size(); got.X != want || got.Y != want {
+			t.Errorf("image %d is %v, want %dx%d", i, got, want, want)
+		}
+
+		if got := pixel(img, want/2, want/2); got != nrgba(red) {
+			t.Errorf("image %d is %v, want %v", i, got, red)
+		}
+	}
+
+	request.HeightPx = 32
+	if err := PrepareRequest(&request, nil, nil, testDefaults); err == nil {
+		t.Error("non-square icon was accepted")
+	}
+}
+
+func TestBackgroundNone(t *testing.T) {
+	server := newTestServer(t, Config{})
+
+	tests := []struct {
+		name   string
+		body   map[string]any
+		status int
+	}{
+		{"png", map[string]any{"widthPx": 20, "heightPx": 20, "background": "none", "format": "png"}, 200},
+		{"jpeg has no alpha", map[string]any{"widthPx": 20, "heightPx": 20, "background": "none", "format": "jpeg"}, 400},
+		{"other values", map[string]any{"widthPx": 20, "heightPx": 20, "background": "red", "format": "png"}, 400},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := postJSON(t, server.URL+"/generate", test.body)
+			body := readBody(t, response)
+			if response.StatusCode != test.status {
+				t.Fatalf("status %d, want %d: %s", response.StatusCode, test.status, body)
+			}
+
+			if test.status != 200 {
+				return
+			}
+
+			// The server default background is white, and skipped
+			img := decodeImage(t, body)
+			for _, point := range [][2]int{{0, 0}, {10, 10}, {19, 19}} {
+				if got := pixel(img, point[0], point[1]); got.A != 0 {
+					t.Errorf("pixel %v is %v, want transparent", point, got)
+				}
+			}
+		})
+	}
+}
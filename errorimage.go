@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+
+	"github.com/fogleman/gg"
+	"golang.org/x/image/font/basicfont"
+)
+
+const maxErrorImageSidePx = 2048
+
+// TryGenerateImage turns render panics into errors so the handler can decide
+// how to report them
+func TryGenerateImage(request ImgRequest) (buff *bytes.Buffer, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("%v", recovered)
+		}
+	}()
+
+	return GenerateImage(request), nil
+}
+
+// RenderErrorImage draws message in white on a red canvas. It uses the
+// built-in bitmap face so it works even when no fonts are installed.
+func RenderErrorImage(widthPx, heightPx int, message string) image.Image {
+	if widthPx <= 0 || widthPx > maxErrorImageSidePx {
+		widthPx = 400
+	}
+
+	if heightPx <= 0 || heightPx > maxErrorImageSidePx {
+		heightPx = 200
+	}
+
+	dc := gg.NewContext(widthPx, heightPx)
+	dc.SetRGB255(200, 30, 30)
+	dc.Clear()
+
+	dc.SetFontFace(basicfont.Face7x13)
+	dc.SetRGB255(255, 255, 255)
+	dc.DrawStringWrapped(message, float64(widthPx)/2, float64(heightPx)/2, 0.5, 0.5, float64(widthPx)-20, 1.5, gg.AlignCenter)
+
+	return dc.Image()
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+type FilterType string
+
+const (
+	Sharpen    FilterType = "sharpen"
+	Saturation FilterType = "saturation"
+	Hue        FilterType = "hue"
+	Invert     FilterType = "invert"
+)
+
+// Filter post-processes the final image, after any thumbnail resize, so
+// pixel sizes are output pixels. Amount is the strength of the unsharp mask,
+// the saturation multiplier where 0 is grayscale, or the hue rotation in
+// degrees.
+type Filter struct {
+	Type     FilterType `json:"type"`
+	Amount   *float64   `json:"amount"`               // 1 for sharpen and saturation, 0 for hue
+	RadiusPx float64    `json:"radiusPx" default:"1"` // sharpen only, gaussian sigma of the mask
+}
+
+func (filter Filter) Validate() error {
+	if filter.Type != Sharpen && filter.Type != Saturation && filter.Type != Hue && filter.Type != Invert {
+		return errors.New("filter type must be sharpen, saturation, hue or invert")
+	}
+
+	if filter.Type != Hue && filter.Amount != nil && *filter.Amount < 0 {
+		return errors.New("filter amount must be positive")
+	}
+
+	if filter.RadiusPx < 0 {
+		return errors.New("filter radiusPx must be positive")
+	}
+
+	return nil
+}
+
+func (filter Filter) amount() float64 {
+	if filter.Amount != nil {
+		return *filter.Amount
+	}
+
+	if filter.Type == Hue {
+		return 0
+	}
+
+	return 1
+}
+
+// ApplyFilters runs the filters over img in order
+func ApplyFilters(img image.Image, filters []Filter) image.Image {
+	for _, filter := range filters {
+		switch filter.Type {
+		case Sharpen:
+			img = sharpen(img, filter)
+		case Saturation, Hue:
+			img = adjustHSL(img, filter)
+		case Invert:
+			img = invert(img)
+		}
+	}
+
+	return img
+}
+
+// sharpen scales the difference imaging.Sharpen makes by the amount, where 1
+// is its plain unsharp mask
+func sharpen(img image.Image, filter Filter) image.Image {
+	amount, radius := filter.amount(), filter.RadiusPx
+	if radius == 0 {
+		radius = 1
+	}
+
+	sharpened := imaging.Sharpen(img, radius)
+	if amount == 1 {
+		return sharpened
+	}
+
+	original := imaging.Clone(img)
+	bounds := original.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			from := original.NRGBAAt(x, y)
+			to := sharpened.NRGBAAt(x, y)
+
+			original.SetNRGBA(x, y, color.NRGBA{
+				extrapolate8(from.R, to.R, amount),
+				extrapolate8(from.G, to.G, amount),
+				extrapolate8(from.B, to.B, amount),
+				extrapolate8(from.A, to.A, amount),
+			})
+		}
+	}
+
+	return original
+}
+
+// extrapolate8 is lerp8 allowed past b, clamped to the byte range
+func extrapolate8(a, b uint8, f float64) uint8 {
+	return clamp8(float64(a) + (float64(b)-float64(a))*f)
+}
+
+// invert replaces every color with its complement, keeping alpha
+func invert(img image.Image) image.Image {
+	result := imaging.Clone(img)
+	for i := 0; i < len(result.Pix); i += 4 {
+		result.Pix[i] = 255 - result.Pix[i]
+		result.Pix[i+1] = 255 - result.Pix[i+1]
+		result.Pix[i+2] = 255 - result.Pix[i+2]
+	}
+
+	return result
+}
+
+// adjustHSL scales the saturation or rotates the hue of every pixel, keeping
+// its lightness and alpha
+func adjustHSL(img image.Image, filter Filter) image.Image {
+	amount := filter.amount()
+	result := imaging.Clone(img)
+	bounds := result.Bounds()
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			pixel := result.NRGBAAt(x, y)
+			h, s, l := rgbToHSL(pixel.R, pixel.G, pixel.B)
+
+			if filter.Type == Saturation {
+				s = math.Min(1, s*amount)
+			} else {
+				h = math.Mod(h+amount/360, 1)
+				if h < 0 {
+					h++
+				}
+			}
+
+			pixel.R, pixel.G, pixel.B = hslToRGB(h, s, l)
+			result.SetNRGBA(x, y, pixel)
+		}
+	}
+
+	return result
+}
+
+// rgbToHSL returns hue, saturation and lightness all within 0..1
+func rgbToHSL(r8, g8, b8 uint8) (float64, float64, float64) {
+	r, g, b := float64(r8)/255, float64(g8)/255, float64(b8)/255
+	high, low := max(r, g, b), min(r, g, b)
+	l := (high + low) / 2
+
+	if high == low {
+		return 0, 0, l
+	}
+
+	d := high - low
+	s := d / (2 - high - low)
+	if l <= 0.5 {
+		s = d / (high + low)
+	}
+
+	var h float64
+	switch high {
+	case r:
+		h = (g - b) / d
+		if g < b {
+			h += 6
+		}
+	case g:
+		h = (b-r)/d + 2
+	default:
+		h = (r-g)/d + 4
+	}
+
+	return h / 6, s, l
+}
+
+func hslToRGB(h, s, l float64) (uint8, uint8, uint8) {
+	if s == 0 {
+		gray := clamp8(l * 255)
+		return gray, gray, gray
+	}
+
+	q := l + s - l*s
+	if l < 0.5 {
+		q = l * (1 + s)
+	}
+	p := 2*l - q
+
+	return clamp8(hueToRGB(p, q, h+1.0/3) * 255), clamp8(hueToRGB(p, q, h) * 255), clamp8(hueToRGB(p, q, h-1.0/3) * 255)
+}
+
+func hueToRGB(p, q, t float64) float64 {
+	t = math.Mod(t+1, 1)
+
+	switch {
+	case t < 1.0/6:
+		return p + (q-p)*6*t
+	case t < 1.0/2:
+		return q
+	case t < 2.0/3:
+		return p + (q-p)*(2.0/3-t)*6
+	}
+
+	return p
+}
+
+func clamp8(value float64) uint8 {
+	return uint8(math.Max(0, math.Min(255, math.Round(value))))
+}
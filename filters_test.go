@@ -0,0 +1,137 @@
+package main
+
+import (
+	"image"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+// edgeContrast is the difference in red between two pixels either side of the
+// vertical edge in the middle of img
+func edgeContrast(img image.Image) int {
+	middle := img.Bounds().Dx() / 2
+	return int(pixel(img, middle+1, 10).R) - int(pixel(img, middle-2, 10).R)
+}
+
+func TestSharpenFilter(t *testing.T) {
+	soft := imaging.Blur(splitImage(40, 20, black, red), 2)
+	before := edgeContrast(soft)
+
+	tests := []struct {
+		name     string
+		amount   float64
+		radiusPx float64
+	}{
+		{"weak", 0.5, 1},
+		{"plain", 1, 1},
+		{"strong", 2, 1},
+		{"wide", 1, 3},
+	}
+
+	last := before
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			amount := test.amount
+			sharpened := ApplyFilters(soft, []Filter{{Type: Sharpen, Amount: &amount, RadiusPx: test.radiusPx}})
+
+			got := edgeContrast(sharpened)
+			if got <= before {
+				t.Errorf("amount %v radius %v: edge contrast %d, want more than %d", test.amount, test.radiusPx, got, before)
+			}
+
+			// A stronger mask of the same radius sharpens more
+			if test.radiusPx == 1 {
+				if got <= last {
+					t.Errorf("amount %v: edge contrast %d, want more than %d of the weaker mask", test.amount, got, last)
+				}
+				last = got
+			}
+		})
+	}
+}
+
+func TestFiltersAfterThumbnail(t *testing.T) {
+	_, assets := testAssets(t, map[string]image.Image{"soft": imaging.Blur(splitImage(80, 40, black, red), 4)})
+
+	render := func(filters []Filter) image.Image {
+		request := ImgRequest{WidthPx: 80, HeightPx: 40, BgImgPath: "soft", ThumbnailWidthPx: 40, Format: PNG, Filters: filters}
+		if err := PrepareRequest(&request, nil, assets, testDefaults); err != nil {
+			t.Fatal(err)
+		}
+
+		buff, err := TryGenerateImage(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return decodeImage(t, buff.Bytes())
+	}
+
+	plain, sharpened := render(nil), render([]Filter{{Type: Sharpen}})
+	if got := sharpened.Bounds().Dx(); got != 40 {
+		t.Fatalf("image is %dpx wide, want the 40px thumbnail", got)
+	}
+
+	if got, want := edgeContrast(sharpened), edgeContrast(plain); got <= want {
+		t.Errorf("edge contrast %d, want more than %d without sharpening", got, want)
+	}
+}
+
+func TestSaturationAndHue(t *testing.T) {
+	orange := Color{255, 128, 0, 255}
+	translucent := Color{0, 128, 255, 128}
+	colors := []Color{red, green, blue, orange, translucent}
+
+	source := image.NewNRGBA(image.Rect(0, 0, len(colors), 1))
+	for x, c := range colors {
+		source.SetNRGBA(x, 0, nrgba(c))
+	}
+
+	amount := func(value float64) *float64 { return &value }
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []Color
+	}{
+		{"grayscale", Filter{Type: Saturation, Amount: amount(0)}, []Color{{128, 128, 128, 255}, {128, 128, 128, 255}, {128, 128, 128, 255}, {128, 128, 128, 255}, {128, 128, 128, 128}}},
+		{"saturation 1 keeps colors", Filter{Type: Saturation}, colors},
+		{"hue 120", Filter{Type: Hue, Amount: amount(120)}, []Color{green, blue, red, {0, 255, 128, 255}, {255, 0, 128, 128}}},
+		{"hue -120", Filter{Type: Hue, Amount: amount(-120)}, []Color{blue, red, green, {128, 0, 255, 255}, {128, 255, 0, 128}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			img := ApplyFilters(source, []Filter{test.filter})
+
+			for x, want := range test.want {
+				if got := pixel(img, x, 0); !closeTo(got, nrgba(want), 1) {
+					t.Errorf("%v became %v, want %v", colors[x], got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestInvertFilter(t *testing.T) {
+	tests := []struct {
+		name string
+		from Color
+		want Color
+	}{
+		{"white", white, black},
+		{"black", black, white},
+		{"known color", Color{0x12, 0x34, 0x56, 255}, Color{0xed, 0xcb, 0xa9, 255}},
+		{"alpha is kept", Color{200, 100, 0, 128}, Color{55, 155, 255, 128}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			img := ApplyFilters(solidImage(2, 2, test.from), []Filter{{Type: Invert}})
+			if got := pixel(img, 1, 1); got != nrgba(test.want) {
+				t.Errorf("%v inverted to %v, want %v", test.from, got, test.want)
+			}
+		})
+	}
+}
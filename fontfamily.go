@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"slices"
+)
+
+// FontFamily maps the styles used by markup and markdown to registered font
+// files. Text elements then name the family instead of a path.
+type FontFamily struct {
+	Regular    string `json:"regular" binding:"required"`
+	Bold       string `json:"bold"`
+	Italic     string `json:"italic"`
+	BoldItalic string `json:"boldItalic"`
+}
+
+// variant picks the file for a style, falling back towards regular when a
+// role is missing
+func (family FontFamily) variant(bold, italic bool) string {
+	candidates := []string{family.Regular}
+	switch {
+	case bold && italic:
+		candidates = []string{family.BoldItalic, family.Bold, family.Regular}
+	case bold:
+		candidates = []string{family.Bold, family.Regular}
+	case italic:
+		candidates = []string{family.Italic, family.Regular}
+	}
+
+	for _, path := range candidates {
+		if path != "" {
+			return path
+		}
+	}
+
+	return family.Regular
+}
+
+func (family FontFamily) Validate(fontFaces []string) error {
+	if family.Regular == "" {
+		return errors.New("font families need a regular font")
+	}
+
+	for _, path := range []string{family.Regular, family.Bold, family.Italic, family.BoldItalic} {
+		if path != "" && !slices.Contains(fontFaces, path) {
+			return errFontNotFound
+		}
+	}
+
+	return nil
+}
+
+// styledFontVariant uses the family of a text element, or path for fonts
+// outside it such as script fallbacks
+func styledFontVariant(family *FontFamily, path string, bold, italic bool) string {
+	if family != nil {
+		return family.variant(bold, italic)
+	}
+
+	return path
+}
+
+// ResolveFontFamilies replaces family names in text fonts with the regular
+// file, keeping the family on the element for its bold and italic runs. Fonts
+// named by file get their RegisteredFamily. Rich text spans, chart labels,
+// callouts and countdowns only take the regular file.
+func ResolveFontFamilies(request *ImgRequest, fontFaces []string) {
+	resolve := func(font *string) *FontFamily {
+		family, ok := request.FontFamilies[*font]
+		if !ok {
+			return RegisteredFamily(*font, fontFaces)
+		}
+
+		*font = family.Regular
+		return &family
+	}
+
+	for i := range request.SingleLineTexts {
+		text := &request.SingleLineTexts[i]
+		text.family = resolve(&text.Font)
+	}
+
+	for i := range request.MultiLineTexts {
+		text := &request.MultiLineTexts[i]
+		text.family = resolve(&text.Font)
+	}
+
+	for i := range request.TextStacks {
+		for j := range request.TextStacks[i].Items {
+			item := &request.TextStacks[i].Items[j]
+			item.family = resolve(&item.Font)
+		}
+	}
+
+	for i := range request.Markdowns {
+		markdown := &request.Markdowns[i]
+		markdown.family = resolve(&markdown.Font)
+	}
+
+	for i := range request.RichTexts {
+		for j := range request.RichTexts[i].Spans {
+			resolve(&request.RichTexts[i].Spans[j].Font)
+		}
+	}
+
+	for i := range request.BarCharts {
+		resolve(&request.BarCharts[i].Font)
+	}
+
+	for i := range request.Callouts {
+		resolve(&request.Callouts[i].Font)
+	}
+
+	for i := range request.Countdowns {
+		resolve(&request.Countdowns[i].Font)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFontFamilyBold(t *testing.T) {
+	fonts := newTestFonts(t)
+
+	// Mono as the bold role, so the mapped file is easy to tell apart from
+	// Go-Bold.ttf found by the file name convention
+	families := map[string]FontFamily{
+		"Brand":   {Regular: fonts.regular, Bold: fonts.mono},
+		"NoBold":  {Regular: fonts.regular},
+		"Reverse": {Regular: fonts.mono, Bold: fonts.regular},
+	}
+
+	render := func(text, font string, markup bool) []byte {
+		request := ImgRequest{
+			WidthPx:         200,
+			HeightPx:        50,
+			BgColor:         white,
+			Format:          PNG,
+			FontFamilies:    families,
+			SingleLineTexts: []StyledText{{Text: text, Font: font, SizePx: pixels(24), Color: black, Position: textPosition(Position{10, 35}), Markup: markup}},
+		}
+
+		return generateRequest(t, request, fonts.faces())
+	}
+
+	tests := []struct {
+		family string
+		want   string
+	}{
+		{"Brand", fonts.mono},
+		{"NoBold", fonts.regular},
+		{"Reverse", fonts.regular},
+	}
+
+	for _, test := range tests {
+		t.Run(test.family, func(t *testing.T) {
+			if !bytes.Equal(render("*Bold*", test.family, true), render("Bold", test.want, false)) {
+				t.Errorf("bold text of %s is not drawn with %s", test.family, test.want)
+			}
+		})
+	}
+}
+
+func TestResolveFontFamiliesEveryText(t *testing.T) {
+	request := ImgRequest{
+		FontFamilies:    map[string]FontFamily{"Brand": {Regular: "regular.ttf", Bold: "bold.ttf"}},
+		SingleLineTexts: []StyledText{{Font: "Brand"}},
+		MultiLineTexts:  []MultiLineText{{StyledText: StyledText{Font: "Brand"}}},
+		TextStacks:      []TextStack{{Items: []TextStackItem{{StyledText: StyledText{Font: "Brand"}}}}},
+		RichTexts:       []RichText{{Spans: []TextSpan{{Font: "Brand"}}}},
+		Markdowns:       []Markdown{{Font: "Brand"}},
+		BarCharts:       []BarChart{{Font: "Brand"}},
+		Callouts:        []Callout{{Font: "Brand"}},
+		Countdowns:      []Countdown{{Font: "Brand"}},
+	}
+	ResolveFontFamilies(&request, nil)
+
+	// Every font a request can name, as listed for remote fonts
+	fields := requestFontFields(&request)
+	if len(fields) != 8 {
+		t.Fatalf("%d font fields, want 8", len(fields))
+	}
+
+	for i, font := range fields {
+		if *font != "regular.ttf" {
+			t.Errorf("font %d is %q, want the regular file of the family", i, *font)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestConicGradient(t *testing.T) {
+	request := ImgRequest{
+		WidthPx:  100,
+		HeightPx: 100,
+		BgGradient: &Gradient{
+			Type:   Conic,
+			Center: Position{50, 50},
+			Stops:  []ColorStop{{0, red}, {1.0 / 3, green}, {2.0 / 3, blue}, {1, red}},
+		},
+	}
+	img := renderRequest(t, request, nil)
+
+	tests := []struct {
+		angleDeg float64
+		want     Color
+	}{
+		{0, red},
+		{120, green},
+		{240, blue},
+	}
+
+	for _, test := range tests {
+		angle := test.angleDeg * math.Pi / 180
+		x := int(math.Round(50 + 40*math.Cos(angle)))
+		y := int(math.Round(50 + 40*math.Sin(angle)))
+
+		if got := pixel(img, x, y); !closeTo(got, nrgba(test.want), 16) {
+			t.Errorf("%v° at (%d, %d) is %v, want %v", test.angleDeg, x, y, got, test.want)
+		}
+	}
+}
+
+func TestMultiStopGradient(t *testing.T) {
+	request := ImgRequest{
+		WidthPx:  101,
+		HeightPx: 10,
+		BgGradient: &Gradient{
+			Start: Position{0, 0},
+			End:   Position{100, 0},
+			Stops: []ColorStop{{0, red}, {0.5, green}, {1, blue}},
+		},
+	}
+	img := renderRequest(t, request, nil)
+
+	for _, test := range []struct {
+		x    int
+		want Color
+	}{{0, red}, {50, green}, {100, blue}} {
+		if got := pixel(img, test.x, 5); !closeTo(got, nrgba(test.want), 8) {
+			t.Errorf("pixel at x=%d is %v, want %v", test.x, got, test.want)
+		}
+	}
+}
+
+func TestGradientValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		stops []ColorStop
+		ok    bool
+	}{
+		{"three stops", []ColorStop{{0, red}, {0.5, green}, {1, blue}}, true},
+		{"one stop", []ColorStop{{0, red}}, false},
+		{"out of range", []ColorStop{{0, red}, {1.5, blue}}, false},
+		{"descending", []ColorStop{{0, red}, {0.6, green}, {0.4, blue}}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := (Gradient{Stops: test.stops}).Validate(); (err == nil) != test.ok {
+				t.Errorf("Validate() = %v, want ok %v", err, test.ok)
+			}
+		})
+	}
+}
+
+func TestGradientStroke(t *testing.T) {
+	gradient := &Gradient{Start: Position{10, 0}, End: Position{290, 0}, Stops: []ColorStop{{0, red}, {1, blue}}}
+
+	// A long box, stroked left to right from red to blue
+	tests := []struct {
+		name    string
+		request ImgRequest
+	}{
+		{"rectangle", ImgRequest{Rectangles: []Rectangle{{Position: Position{10, 20}, WidthPx: 280, HeightPx: 20, StrokeGradient: gradient}}}},
+		{"curve", ImgRequest{Curves: []Curve{{Start: Position{10, 20}, Control1: Position{150, 20}, End: Position{290, 20}, WidthPx: 5, StrokeGradient: gradient}}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.request.WidthPx, test.request.HeightPx, test.request.BgColor = 300, 60, white
+			img := renderRequest(t, test.request, nil)
+
+			left, right := pixel(img, 12, 20), pixel(img, 288, 20)
+			if left.R < 200 || left.B > 55 {
+				t.Errorf("left end of the stroke is %v, want red", left)
+			}
+
+			if right.B < 200 || right.R > 55 {
+				t.Errorf("right end of the stroke is %v, want blue", right)
+			}
+		})
+	}
+}
+
+func TestCheckerboard(t *testing.T) {
+	tests := []struct {
+		name         string
+		checkerboard Checkerboard
+		light, dark  Color
+		cellSize     int
+	}{
+		{"defaults", Checkerboard{}, white, Color{204, 204, 204, 255}, 16},
+		{"configured", Checkerboard{Light: red, Dark: blue, CellSizePx: 10}, red, blue, 10},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			checkerboard := test.checkerboard
+			img := renderRequest(t, ImgRequest{WidthPx: 64, HeightPx: 64, BgCheckerboard: &checkerboard}, nil)
+
+			for y := 0; y < 64; y++ {
+				for x := 0; x < 64; x++ {
+					want := test.light
+					if (x/test.cellSize+y/test.cellSize)%2 == 1 {
+						want = test.dark
+					}
+
+					if got := pixel(img, x, y); got != nrgba(want) {
+						t.Fatalf("pixel (%d, %d) is %v, want %v", x, y, got, want)
+					}
+				}
+			}
+		})
+	}
+}
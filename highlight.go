@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/fogleman/gg"
+)
+
+// HighlightSpan recolors the runes in [Start, End) of a single-line text. A
+// zero Color keeps the text color and a zero BackgroundColor draws no box.
+type HighlightSpan struct {
+	Start           int     `json:"start"`
+	End             int     `json:"end"`
+	Color           Color   `json:"color"`
+	BackgroundColor Color   `json:"backgroundColor"`
+	PaddingPx       float64 `json:"paddingPx"` // extends the box left and right
+}
+
+func validateHighlights(text StyledText) error {
+	if len(text.Highlights) == 0 {
+		return nil
+	}
+
+	// Expanded shortcodes would shift the rune ranges
+	if text.Markup || text.AutoFont || text.Shortcodes || text.TruncateWidthPx > 0 || text.Wave != nil || len(text.TabStopsPx) > 0 {
+		return errors.New("highlights can't be combined with markup, autoFont, shortcodes, truncateWidthPx, wave or tabStopsPx")
+	}
+
+	length := len([]rune(text.Text))
+	for _, highlight := range text.Highlights {
+		if highlight.Start < 0 || highlight.End > length || highlight.Start >= highlight.End {
+			return errors.New("highlight ranges must be within the text and not empty")
+		}
+	}
+
+	return nil
+}
+
+// highlightPiece is a run of runes covered by the same highlight, or by none
+// when highlight is nil
+type highlightPiece struct {
+	start, end int
+	highlight  *HighlightSpan
+}
+
+// highlightPieces splits text at every highlight boundary. Where highlights
+// overlap the later one wins.
+func highlightPieces(text StyledText) []highlightPiece {
+	owners := make([]*HighlightSpan, len([]rune(text.Text)))
+	for i := range text.Highlights {
+		for r := text.Highlights[i].Start; r < text.Highlights[i].End; r++ {
+			owners[r] = &text.Highlights[i]
+		}
+	}
+
+	pieces := []highlightPiece{}
+	for r, owner := range owners {
+		if len(pieces) > 0 && pieces[len(pieces)-1].highlight == owner {
+			pieces[len(pieces)-1].end = r + 1
+			continue
+		}
+
+		pieces = append(pieces, highlightPiece{r, r + 1, owner})
+	}
+
+	return pieces
+}
+
+// DrawHighlightedText draws the highlight boxes and then the text piece by
+// piece, so recolored runes are never drawn over in the base color
+func DrawHighlightedText(dc *gg.Context, text StyledText) {
+	scale := deviceScale(dc)
+
+	face, err := text.loadFace(text.Font, text.SizePx.Value*scale)
+	if err != nil {
+		panic(err)
+	}
+	defer face.Close()
+
+	runes := []rune(text.Text)
+	pieces := highlightPieces(text)
+	x, y := text.Position.X.Value*scale, text.Position.Y.Value*scale
+
+	// Offsets keep the kerning between a piece and the one before it
+	offset := func(r int) float64 {
+		if r == 0 {
+			return 0
+		}
+
+		kern := 0.0
+		if r < len(runes) {
+			kern = float64(face.Kern(runes[r-1], runes[r])) / 64
+		}
+
+		return measureRun(face, string(runes[:r])) + kern
+	}
+
+	metrics := face.Metrics()
+	ascent := float64(metrics.Ascent) / 64
+	descent := float64(metrics.Descent) / 64
+
+	dc.Push()
+	dc.Identity()
+	for _, piece := range pieces {
+		if piece.highlight == nil || piece.highlight.BackgroundColor == (Color{}) {
+			continue
+		}
+
+		padding := piece.highlight.PaddingPx * scale
+		left := x + offset(piece.start) - padding
+		right := x + measureRun(face, string(runes[:piece.end])) + padding
+
+		dc.DrawRectangle(left, y-ascent, right-left, ascent+descent)
+		dc.SetColor(piece.highlight.BackgroundColor.ToRGBA())
+		dc.Fill()
+	}
+	dc.Pop()
+
+	// Pieces sharing a color go through DrawText together so shadows and
+	// antialiasing apply to them as they would to plain text
+	colors := []Color{text.Color}
+	for _, piece := range pieces {
+		if piece.highlight != nil && piece.highlight.Color != (Color{}) {
+			colors = append(colors, piece.highlight.Color)
+		}
+	}
+
+	drawn := map[Color]bool{}
+	for _, pieceColor := range colors {
+		if drawn[pieceColor] {
+			continue
+		}
+		drawn[pieceColor] = true
+
+		styled := text
+		styled.Color = pieceColor
+		// Auto contrast would measure only some of the pieces
+		styled.AutoContrast = text.AutoContrast && pieceColor == text.Color
+
+		DrawText(dc, styled, func(dc *gg.Context, scale float64) {
+			for _, piece := range pieces {
+				if pieceColorOf(piece, text.Color) != pieceColor {
+					continue
+				}
+
+				dc.DrawString(string(runes[piece.start:piece.end]), x+offset(piece.start), y)
+			}
+		})
+	}
+}
+
+func pieceColorOf(piece highlightPiece, base Color) Color {
+	if piece.highlight == nil || piece.highlight.Color == (Color{}) {
+		return base
+	}
+
+	return piece.highlight.Color
+}
@@ -0,0 +1,62 @@
+package main
+
+import "testing"
+
+func TestHighlightMiddleWord(t *testing.T) {
+	fonts := newTestFonts(t)
+	yellow := Color{255, 220, 0, 255}
+
+	text := StyledText{
+		Text:       "find the needle here",
+		Font:       fonts.regular,
+		SizePx:     pixels(32),
+		Color:      black,
+		Position:   textPosition(Position{10, 40}),
+		Highlights: []HighlightSpan{{Start: 9, End: 15, BackgroundColor: yellow}},
+	}
+	img := renderRequest(t, ImgRequest{WidthPx: 400, HeightPx: 60, BgColor: white, SingleLineTexts: []StyledText{text}}, fonts.faces())
+
+	face, err := LoadFontFace(fonts.regular, 32, HintingNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer face.Close()
+
+	left := 10 + int(measureRun(face, "find the "))
+	right := 10 + int(measureRun(face, "find the needle"))
+
+	tests := []struct {
+		name   string
+		x0, x1 int
+		yellow bool
+	}{
+		{"before", 0, left - 1, false},
+		{"needle", left + 1, right - 1, true},
+		{"after", right + 1, 400, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			yellowPixels, colored := 0, 0
+			for y := 0; y < 60; y++ {
+				for x := test.x0; x < test.x1; x++ {
+					got := pixel(img, x, y)
+					if closeTo(got, nrgba(yellow), 8) {
+						yellowPixels++
+					} else if got.R != got.G || got.G != got.B {
+						colored++
+					}
+				}
+			}
+
+			if test.yellow && yellowPixels == 0 {
+				t.Error("no highlight background")
+			}
+
+			// The rest is black text on white
+			if !test.yellow && (yellowPixels > 0 || colored > 0) {
+				t.Errorf("%d highlight and %d other colored pixels, want only gray", yellowPixels, colored)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/png"
+	"io"
+
+	"github.com/disintegration/imaging"
+)
+
+var icoSizes = []int{16, 32, 48}
+
+// encodeICO packs the image downscaled to every icoSizes entry into one ICO
+// file. Entries are stored as PNG, which every current browser and OS reads.
+func encodeICO(w io.Writer, img image.Image) error {
+	images := [][]byte{}
+
+	for _, size := range icoSizes {
+		buff := new(bytes.Buffer)
+		if err := png.Encode(buff, imaging.Resize(img, size, size, imaging.Lanczos)); err != nil {
+			return err
+		}
+
+		images = append(images, buff.Bytes())
+	}
+
+	// ICONDIR header followed by one ICONDIRENTRY per image
+	header := new(bytes.Buffer)
+	binary.Write(header, binary.LittleEndian, [3]uint16{0, 1, uint16(len(images))})
+
+	offset := 6 + 16*len(images)
+	for i, data := range images {
+		binary.Write(header, binary.LittleEndian, struct {
+			Width, Height, Colors, Reserved uint8
+			Planes, BitsPerPixel            uint16
+			Size, Offset                    uint32
+		}{uint8(icoSizes[i]), uint8(icoSizes[i]), 0, 0, 1, 32, uint32(len(data)), uint32(offset)})
+
+		offset += len(data)
+	}
+
+	if _, err := w.Write(header.Bytes()); err != nil {
+		return err
+	}
+
+	for _, data := range images {
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/fogleman/gg"
+)
+
+// Icon draws a named icon from the built in set, filling a SizePx square with
+// its top-left corner at Position
+type Icon struct {
+	Name     string   `json:"name"`
+	Position Position `json:"position"`
+	SizePx   float64  `json:"sizePx" default:"24"`
+	Color    Color    `json:"color"`
+	Visibility
+}
+
+// iconPaths are SVG path strings on a 24x24 grid, taken from the Material
+// icons (Apache 2.0). Only M, L, H, V, C, Q and Z are used.
+var iconPaths = map[string]string{
+	"check":       "M9 16.17 4.83 12l-1.42 1.41L9 19 21 7l-1.41-1.41z",
+	"close":       "M19 6.41 17.59 5 12 10.59 6.41 5 5 6.41 10.59 12 5 17.59 6.41 19 12 13.41 17.59 19 19 17.59 13.41 12z",
+	"plus":        "M19 13h-6v6h-2v-6H5v-2h6V5h2v6h6v2z",
+	"minus":       "M19 13H5v-2h14v2z",
+	"star":        "M12 17.27 18.18 21l-1.64-7.03L22 9.24l-7.19-.61L12 2 9.19 8.63 2 9.24l5.46 4.73L5.82 21z",
+	"heart":       "M12 21.35l-1.45-1.32C5.4 15.36 2 12.28 2 8.5 2 5.42 4.42 3 7.5 3c1.74 0 3.41.81 4.5 2.09C13.09 3.81 14.76 3 16.5 3 19.58 3 22 5.42 22 8.5c0 3.78-3.4 6.86-8.55 11.54L12 21.35z",
+	"arrow-right": "M12 4l-1.41 1.41L16.17 11H4v2h12.17l-5.58 5.59L12 20l8-8z",
+	"arrow-left":  "M20 11H7.83l5.59-5.59L12 4l-8 8 8 8 1.41-1.41L7.83 13H20v-2z",
+	"play":        "M8 5v14l11-7z",
+}
+
+func (icon Icon) Validate() error {
+	if _, ok := iconPaths[icon.Name]; !ok {
+		return fmt.Errorf("unknown icon %q", icon.Name)
+	}
+
+	return nil
+}
+
+func DrawIcon(dc *gg.Context, icon Icon) {
+	size := icon.SizePx
+	if size == 0 {
+		size = 24
+	}
+
+	dc.Push()
+	defer dc.Pop()
+
+	dc.Translate(icon.Position.X, icon.Position.Y)
+	dc.Scale(size/24, size/24)
+
+	tracePath(dc, iconPaths[icon.Name])
+	dc.SetColor(icon.Color.ToRGBA())
+	dc.Fill()
+}
+
+// tracePath adds an SVG path to dc. Relative commands are resolved against
+// the current point and a command letter repeats for each extra set of
+// arguments, with a repeated moveto continuing as a lineto.
+func tracePath(dc *gg.Context, path string) {
+	scanner := pathScanner{path: path}
+	var command byte
+	x, y, startX, startY := 0.0, 0.0, 0.0, 0.0
+
+	for scanner.skipSeparators() {
+		if letter := path[scanner.offset]; isPathCommand(letter) {
+			command = letter
+			scanner.offset++
+		} else if command == 'M' {
+			command = 'L'
+		} else if command == 'm' {
+			command = 'l'
+		}
+
+		relative := command >= 'a'
+		ox, oy := 0.0, 0.0
+		if relative {
+			ox, oy = x, y
+		}
+
+		switch command | 0x20 {
+		case 'm':
+			x, y = ox+scanner.number(), oy+scanner.number()
+			startX, startY = x, y
+			dc.MoveTo(x, y)
+		case 'l':
+			x, y = ox+scanner.number(), oy+scanner.number()
+			dc.LineTo(x, y)
+		case 'h':
+			x = ox + scanner.number()
+			dc.LineTo(x, y)
+		case 'v':
+			y = oy + scanner.number()
+			dc.LineTo(x, y)
+		case 'c':
+			x1, y1 := ox+scanner.number(), oy+scanner.number()
+			x2, y2 := ox+scanner.number(), oy+scanner.number()
+			x, y = ox+scanner.number(), oy+scanner.number()
+			dc.CubicTo(x1, y1, x2, y2, x, y)
+		case 'q':
+			x1, y1 := ox+scanner.number(), oy+scanner.number()
+			x, y = ox+scanner.number(), oy+scanner.number()
+			dc.QuadraticTo(x1, y1, x, y)
+		case 'z':
+			dc.ClosePath()
+			x, y = startX, startY
+		default:
+			panic(fmt.Errorf("unsupported path command %q", command))
+		}
+	}
+}
+
+func isPathCommand(letter byte) bool {
+	switch letter | 0x20 {
+	case 'm', 'l', 'h', 'v', 'c', 'q', 'z':
+		return true
+	}
+
+	return false
+}
+
+type pathScanner struct {
+	path   string
+	offset int
+}
+
+// skipSeparators moves past spaces and commas and reports whether anything
+// is left
+func (scanner *pathScanner) skipSeparators() bool {
+	for scanner.offset < len(scanner.path) && (scanner.path[scanner.offset] == ' ' || scanner.path[scanner.offset] == ',') {
+		scanner.offset++
+	}
+
+	return scanner.offset < len(scanner.path)
+}
+
+// number reads the next number. A sign or a second decimal point starts a new
+// number, so "3.41.81" is 3.41 followed by .81.
+func (scanner *pathScanner) number() float64 {
+	scanner.skipSeparators()
+
+	start := scanner.offset
+	if start < len(scanner.path) && scanner.path[start] == '-' {
+		scanner.offset++
+	}
+
+	seenDot := false
+	for scanner.offset < len(scanner.path) {
+		char := scanner.path[scanner.offset]
+		if char == '.' && !seenDot {
+			seenDot = true
+		} else if char < '0' || char > '9' {
+			break
+		}
+		scanner.offset++
+	}
+
+	value, err := strconv.ParseFloat(scanner.path[start:scanner.offset], 64)
+	if err != nil {
+		panic(fmt.Errorf("bad icon path number at %d: %w", start, err))
+	}
+
+	return value
+}
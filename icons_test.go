@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestCheckIcon(t *testing.T) {
+	// 48px is twice the 24px grid of the icon paths
+	request := ImgRequest{WidthPx: 48, HeightPx: 48, BgColor: white, Icons: []Icon{{Name: "check", SizePx: 48, Color: green}}}
+	img := renderRequest(t, request, nil)
+
+	tests := []struct {
+		name   string
+		x, y   int
+		inside bool
+	}{
+		{"corner of the tick", 18, 35, true},
+		{"long arm", 30, 23, true},
+		{"short arm", 12, 29, true},
+		{"top left", 8, 8, false},
+		{"above the corner", 24, 16, false},
+		{"bottom right", 40, 40, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			want := white
+			if test.inside {
+				want = green
+			}
+
+			if got := pixel(img, test.x, test.y); !closeTo(got, nrgba(want), 8) {
+				t.Errorf("pixel (%d, %d) is %v, want %v", test.x, test.y, got, want)
+			}
+		})
+	}
+}
+
+func TestUnknownIcon(t *testing.T) {
+	server := newTestServer(t, Config{})
+
+	response := postJSON(t, server.URL+"/generate", map[string]any{"widthPx": 20, "heightPx": 20, "icons": []map[string]any{{"name": "unicorn"}}})
+	if body := readBody(t, response); response.StatusCode != 400 {
+		t.Errorf("status %d, want 400: %s", response.StatusCode, body)
+	}
+}
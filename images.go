@@ -0,0 +1,511 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"github.com/fogleman/gg"
+	_ "golang.org/x/image/webp"
+)
+
+// Formats accepted for backgrounds, placed images and masks
+var inputFormats = map[string]bool{"png": true, "jpeg": true, "gif": true, "webp": true}
+
+type ImageFit string
+
+const (
+	Stretch ImageFit = "stretch"
+	Cover   ImageFit = "cover"
+	Contain ImageFit = "contain"
+)
+
+type PlacedImage struct {
+	Anchoring
+	Rotation
+	Path     string   `json:"path"`
+	Position Position `json:"position"`
+	WidthPx  float64  `json:"widthPx"`
+	HeightPx float64  `json:"heightPx"`
+	FlipH    bool     `json:"flipH"`
+	FlipV    bool     `json:"flipV"`
+	// Grayscale image whose luminance becomes the alpha of the placed image
+	MaskPath string `json:"maskPath"`
+	// Recolors the image by mapping its luminance through the stops, black to
+	// the first and white to the last. Two stops give a duotone.
+	GradientMap []ColorStop `json:"gradientMap"`
+	// Draw an earlier result of the same batch instead of the file at Path
+	BatchIndex *int `json:"batchIndex"`
+	// Cover crops and contain letterboxes the image into the WidthPx x HeightPx
+	// cell instead of stretching it
+	Fit            ImageFit  `json:"fit" default:"stretch"`
+	Focus          *Position `json:"focus"` // cover only, see FillFocused
+	CornerRadiusPx float64   `json:"cornerRadiusPx"`
+	BlendMode      BlendMode `json:"blendMode" default:"normal"`
+	Visibility
+}
+
+func DrawPlacedImage(dc *gg.Context, placed PlacedImage, batchResults []image.Image, images *ImageCache) {
+	var img image.Image
+	var err error
+
+	if placed.BatchIndex != nil {
+		img = batchResults[*placed.BatchIndex]
+	} else if img, err = images.Load(placed.Path); err != nil {
+		panic(err)
+	}
+
+	switch {
+	case placed.Fit == Cover:
+		img = FillFocused(img, int(placed.WidthPx), int(placed.HeightPx), placed.Focus)
+	case placed.Fit == Contain:
+		img = imaging.Fit(img, int(placed.WidthPx), int(placed.HeightPx), imaging.Lanczos)
+	case placed.WidthPx > 0 || placed.HeightPx > 0:
+		// Zero on one side keeps the aspect ratio
+		img = imaging.Resize(img, int(placed.WidthPx), int(placed.HeightPx), imaging.Lanczos)
+	}
+
+	if placed.FlipH {
+		img = imaging.FlipH(img)
+	}
+
+	if placed.FlipV {
+		img = imaging.FlipV(img)
+	}
+
+	if len(placed.GradientMap) > 0 {
+		img = ApplyGradientMap(img, placed.GradientMap)
+	}
+
+	if placed.MaskPath != "" {
+		mask, err := images.Load(placed.MaskPath)
+		if err != nil {
+			panic(err)
+		}
+
+		img = ApplyAlphaMask(img, mask)
+	}
+
+	if placed.CornerRadiusPx > 0 {
+		img = RoundCorners(img, placed.CornerRadiusPx)
+	}
+
+	bounds := img.Bounds()
+	cellWidth, cellHeight := float64(bounds.Dx()), float64(bounds.Dy())
+
+	// Contained images are centered in their cell
+	if placed.Fit == Contain {
+		cellWidth, cellHeight = placed.WidthPx, placed.HeightPx
+	}
+
+	canvasWidth, canvasHeight := canvasSize(dc)
+	placed.Position = placed.Resolve(placed.Position, cellWidth, cellHeight, canvasWidth, canvasHeight)
+
+	dc.Push()
+	defer dc.Pop()
+
+	// The cell center is the default pivot, so the position stays the top-left
+	// corner
+	placed.Rotate(dc, placed.Position.X, placed.Position.Y, cellWidth, cellHeight, 1)
+	dc.DrawImage(
+		img,
+		int(placed.Position.X+(cellWidth-float64(bounds.Dx()))/2),
+		int(placed.Position.Y+(cellHeight-float64(bounds.Dy()))/2),
+	)
+}
+
+// placedImageSize is the size of the cell a placed image is drawn in, before
+// rotation, following the resizing of DrawPlacedImage
+func placedImageSize(placed PlacedImage, batchResults []image.Image, images *ImageCache) (float64, float64) {
+	if placed.Fit == Cover || placed.Fit == Contain {
+		return placed.WidthPx, placed.HeightPx
+	}
+
+	var img image.Image
+	var err error
+
+	if placed.BatchIndex != nil {
+		img = batchResults[*placed.BatchIndex]
+	} else if img, err = images.Load(placed.Path); err != nil {
+		panic(err)
+	}
+
+	bounds := img.Bounds()
+	widthPx, heightPx := float64(bounds.Dx()), float64(bounds.Dy())
+
+	// Zero on one side keeps the aspect ratio, rounded like imaging.Resize
+	switch {
+	case placed.WidthPx > 0 && placed.HeightPx > 0:
+		return placed.WidthPx, placed.HeightPx
+	case placed.WidthPx > 0:
+		return placed.WidthPx, math.Max(1, math.Floor(placed.WidthPx*heightPx/widthPx+0.5))
+	case placed.HeightPx > 0:
+		return math.Max(1, math.Floor(placed.HeightPx*widthPx/heightPx+0.5)), placed.HeightPx
+	}
+
+	return widthPx, heightPx
+}
+
+// FillFocused crops and scales img to fill width x height like imaging.Fill,
+// but keeps focus, given as fractions of the image size, as near the middle
+// of the crop as the image edges allow. A nil focus crops around the center.
+func FillFocused(img image.Image, width, height int, focus *Position) image.Image {
+	if focus == nil {
+		return imaging.Fill(img, width, height, imaging.Center, imaging.Lanczos)
+	}
+
+	bounds := img.Bounds()
+	ratio := math.Max(float64(width)/float64(bounds.Dx()), float64(height)/float64(bounds.Dy()))
+	resizedWidth := max(width, int(math.Round(float64(bounds.Dx())*ratio)))
+	resizedHeight := max(height, int(math.Round(float64(bounds.Dy())*ratio)))
+	resized := imaging.Resize(img, resizedWidth, resizedHeight, imaging.Lanczos)
+
+	// offset centers the focus on one axis, clamped to the resized image
+	offset := func(focus float64, resized, size int) int {
+		return max(0, min(resized-size, int(math.Round(focus*float64(resized)-float64(size)/2))))
+	}
+
+	x, y := offset(focus.X, resizedWidth, width), offset(focus.Y, resizedHeight, height)
+	return imaging.Crop(resized, image.Rect(x, y, x+width, y+height))
+}
+
+func validateFocus(focus *Position, fit ImageFit) error {
+	if focus == nil {
+		return nil
+	}
+
+	if fit != Cover {
+		return errors.New("focus needs cover fit")
+	}
+
+	if focus.X < 0 || focus.X > 1 || focus.Y < 0 || focus.Y > 1 {
+		return errors.New("focus must be between 0 and 1")
+	}
+
+	return nil
+}
+
+// DrawBackgroundImage draws img fitted to the whole canvas. Without a fit it is
+// drawn as is from the top-left corner. Contained images are centered.
+func DrawBackgroundImage(dc *gg.Context, img image.Image, fit ImageFit, focus *Position) {
+	if fit == "" {
+		dc.DrawImage(img, 0, 0)
+		return
+	}
+
+	// Fitted to the device pixels so supersampling doesn't upscale it
+	width, height := dc.Width(), dc.Height()
+	switch fit {
+	case Stretch:
+		img = imaging.Resize(img, width, height, imaging.Lanczos)
+	case Cover:
+		img = FillFocused(img, width, height, focus)
+	case Contain:
+		img = imaging.Fit(img, width, height, imaging.Lanczos)
+	}
+
+	bounds := img.Bounds()
+
+	dc.Push()
+	defer dc.Pop()
+	dc.Identity()
+	dc.DrawImage(img, (width-bounds.Dx())/2, (height-bounds.Dy())/2)
+}
+
+// RoundCorners clips img to a rounded rectangle covering its bounds
+func RoundCorners(img image.Image, radiusPx float64) image.Image {
+	bounds := img.Bounds()
+
+	clipped := gg.NewContext(bounds.Dx(), bounds.Dy())
+	clipped.DrawRoundedRectangle(0, 0, float64(bounds.Dx()), float64(bounds.Dy()), radiusPx)
+	clipped.Clip()
+	clipped.DrawImage(img, -bounds.Min.X, -bounds.Min.Y)
+
+	return clipped.Image()
+}
+
+// ApplyAlphaMask multiplies the alpha of img by the luminance of mask, which is
+// stretched to the size of img first
+func ApplyAlphaMask(img image.Image, mask image.Image) *image.NRGBA {
+	masked := imaging.Clone(img)
+	bounds := masked.Bounds()
+	mask = imaging.Resize(mask, bounds.Dx(), bounds.Dy(), imaging.Linear)
+	maskBounds := mask.Bounds()
+
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			r, g, b, _ := mask.At(maskBounds.Min.X+x, maskBounds.Min.Y+y).RGBA()
+			luminance := (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 0xffff
+
+			i := masked.PixOffset(x, y) + 3
+			masked.Pix[i] = uint8(float64(masked.Pix[i]) * luminance)
+		}
+	}
+
+	return masked
+}
+
+// ApplyGradientMap replaces every pixel with the color of stops at the pixel's
+// luminance. The stop alpha is multiplied into the pixel alpha.
+func ApplyGradientMap(img image.Image, stops []ColorStop) *image.NRGBA {
+	mapped := imaging.Clone(img)
+
+	table := [256]color.RGBA{}
+	for i := range table {
+		table[i] = colorAtOffset(stops, float64(i)/255)
+	}
+
+	for i := 0; i < len(mapped.Pix); i += 4 {
+		r, g, b := float64(mapped.Pix[i]), float64(mapped.Pix[i+1]), float64(mapped.Pix[i+2])
+		mappedColor := table[uint8(math.Round(0.299*r+0.587*g+0.114*b))]
+
+		mapped.Pix[i] = mappedColor.R
+		mapped.Pix[i+1] = mappedColor.G
+		mapped.Pix[i+2] = mappedColor.B
+		mapped.Pix[i+3] = uint8(uint16(mapped.Pix[i+3]) * uint16(mappedColor.A) / 255)
+	}
+
+	return mapped
+}
+
+// decodeBase64 accepts raw base64 as well as data URIs
+func decodeBase64(encoded string) ([]byte, error) {
+	if _, data, ok := strings.Cut(encoded, ";base64,"); ok {
+		encoded = data
+	}
+
+	return base64.StdEncoding.DecodeString(encoded)
+}
+
+// LoadBackgroundImage decodes the inline base64 background, or loads the one at
+// BgImgPath when there is none
+func LoadBackgroundImage(request ImgRequest) (image.Image, error) {
+	if request.BgImgBase64 == "" {
+		return request.ImageCache.Load(request.BgImgPath)
+	}
+
+	data, err := decodeBase64(request.BgImgBase64)
+	if err != nil {
+		return nil, err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+// ResolveCanvasSize fills in omitted canvas dimensions from the background
+// image so it can be annotated as is. With only one dimension given the
+// other follows the image aspect ratio.
+func ResolveCanvasSize(request *ImgRequest) error {
+	if request.WidthPx > 0 && request.HeightPx > 0 {
+		return nil
+	}
+
+	var config image.Config
+	var err error
+
+	switch {
+	case request.BgImgBase64 != "":
+		var data []byte
+		if data, err = decodeBase64(request.BgImgBase64); err != nil {
+			return err
+		}
+
+		config, err = DecodeImageConfig("background image", bytes.NewReader(data))
+	case request.BgImgPath != "":
+		config, err = fileImageConfig("background image", request.BgImgPath)
+	default:
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case request.WidthPx > 0:
+		request.HeightPx = request.WidthPx * config.Height / config.Width
+	case request.HeightPx > 0:
+		request.WidthPx = request.HeightPx * config.Width / config.Height
+	default:
+		request.WidthPx, request.HeightPx = config.Width, config.Height
+	}
+
+	return nil
+}
+
+// DecodeImageConfig reads the header of an input image so unsupported or
+// corrupt files are rejected before rendering. name describes the image in
+// the error.
+func DecodeImageConfig(name string, r io.Reader) (image.Config, error) {
+	config, format, err := image.DecodeConfig(r)
+	if errors.Is(err, image.ErrFormat) {
+		return config, fmt.Errorf("%s is not a supported image, use png, jpeg, gif or webp", name)
+	}
+
+	if err != nil {
+		return config, fmt.Errorf("%s is corrupt: %w", name, err)
+	}
+
+	if !inputFormats[format] {
+		return config, fmt.Errorf("%s has unsupported format %s, use png, jpeg, gif or webp", name, format)
+	}
+
+	return config, nil
+}
+
+func fileImageConfig(name string, path string) (image.Config, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return image.Config{}, err
+	}
+	defer file.Close()
+
+	return DecodeImageConfig(name, file)
+}
+
+// PreflightImages checks the format of every image the request reads and
+// decodes it fully, so corrupt data fails validation rather than rendering.
+// Files go through the image cache and are not decoded twice.
+func PreflightImages(request ImgRequest) error {
+	if request.BgImgBase64 != "" {
+		data, err := decodeBase64(request.BgImgBase64)
+		if err != nil {
+			return err
+		}
+
+		if _, err := DecodeImageConfig("background image", bytes.NewReader(data)); err != nil {
+			return err
+		}
+
+		if _, _, err := image.Decode(bytes.NewReader(data)); err != nil {
+			return fmt.Errorf("background image is corrupt: %w", err)
+		}
+	} else if request.BgImgPath != "" {
+		if err := preflightFile("background image", request.BgImgPath, request.ImageCache); err != nil {
+			return err
+		}
+	}
+
+	for i, placed := range request.Images {
+		if placed.BatchIndex == nil {
+			if err := preflightFile(fmt.Sprintf("image %d", i), placed.Path, request.ImageCache); err != nil {
+				return err
+			}
+		}
+
+		if placed.MaskPath != "" {
+			if err := preflightFile(fmt.Sprintf("mask of image %d", i), placed.MaskPath, request.ImageCache); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func preflightFile(name string, path string, images *ImageCache) error {
+	if _, err := fileImageConfig(name, path); err != nil {
+		return err
+	}
+
+	if _, err := images.Load(path); err != nil {
+		return fmt.Errorf("%s is corrupt: %w", name, err)
+	}
+
+	return nil
+}
+
+// RotateImage turns the whole image clockwise by request.RotateDeg. Quarter
+// turns are lossless; other angles grow the canvas to fit and fill the
+// uncovered corners with the background color, or leave them transparent.
+func RotateImage(img image.Image, request ImgRequest) image.Image {
+	deg := math.Mod(request.RotateDeg, 360)
+	if deg < 0 {
+		deg += 360
+	}
+
+	switch deg {
+	case 0:
+		return img
+	case 90:
+		return imaging.Rotate270(img)
+	case 180:
+		return imaging.Rotate180(img)
+	case 270:
+		return imaging.Rotate90(img)
+	}
+
+	var fill color.Color = color.Transparent
+	if !request.Transparent && request.BgColor != (Color{}) {
+		fill = request.BgColor.ToRGBA()
+	}
+
+	// imaging rotates counter-clockwise
+	return imaging.Rotate(img, -deg, fill)
+}
+
+// Trim crops away border rows and columns matching the top-left pixel, which
+// is taken as the background. Tolerance is the largest per channel difference
+// still counted as background.
+type Trim struct {
+	Tolerance uint8 `json:"tolerance"`
+	PaddingPx int   `json:"paddingPx"` // background kept around the content
+}
+
+func (trim Trim) Validate() error {
+	if trim.PaddingPx < 0 {
+		return errors.New("trim paddingPx can't be negative")
+	}
+
+	return nil
+}
+
+// TrimImage returns the smallest crop of img holding every pixel that differs
+// from the background, grown by the padding. An image that is all background
+// is returned unchanged.
+func TrimImage(img image.Image, trim Trim) image.Image {
+	cloned := imaging.Clone(img)
+	width, height := cloned.Bounds().Dx(), cloned.Bounds().Dy()
+
+	background := cloned.Pix[0:4]
+	isBackground := func(x, y int) bool {
+		i := cloned.PixOffset(x, y)
+		for c := 0; c < 4; c++ {
+			diff := int(cloned.Pix[i+c]) - int(background[c])
+			if diff > int(trim.Tolerance) || -diff > int(trim.Tolerance) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	minX, minY, maxX, maxY := width, height, -1, -1
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if isBackground(x, y) {
+				continue
+			}
+
+			minX, minY = min(minX, x), min(minY, y)
+			maxX, maxY = max(maxX, x), max(maxY, y)
+		}
+	}
+
+	if maxX < 0 {
+		return img
+	}
+
+	content := image.Rect(minX-trim.PaddingPx, minY-trim.PaddingPx, maxX+1+trim.PaddingPx, maxY+1+trim.PaddingPx)
+	return imaging.Crop(cloned, content.Intersect(cloned.Bounds()))
+}
@@ -0,0 +1,458 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestPlacedImageFlip(t *testing.T) {
+	// Red on the left, blue on the right; flipping vertically changes nothing
+	_, assets := testAssets(t, map[string]image.Image{"split": splitImage(40, 20, red, blue)})
+
+	tests := []struct {
+		name        string
+		flipH       bool
+		flipV       bool
+		left, right Color
+	}{
+		{"as is", false, false, red, blue},
+		{"flipH", true, false, blue, red},
+		{"flipV", false, true, red, blue},
+		{"both", true, true, blue, red},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := ImgRequest{
+				WidthPx:  40,
+				HeightPx: 20,
+				BgColor:  white,
+				Images:   []PlacedImage{{Path: "split", FlipH: test.flipH, FlipV: test.flipV}},
+			}
+			img := renderWithAssets(t, request, nil, assets)
+
+			if got := pixel(img, 5, 10); got != nrgba(test.left) {
+				t.Errorf("left is %v, want %v", got, test.left)
+			}
+
+			if got := pixel(img, 35, 10); got != nrgba(test.right) {
+				t.Errorf("right is %v, want %v", got, test.right)
+			}
+		})
+	}
+}
+
+// radialMask is white in the center fading to black at radius size/2
+func radialMask(size int) *image.NRGBA {
+	mask := solidImage(size, size, black)
+	center := float64(size) / 2
+
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			distance := math.Hypot(float64(x)+0.5-center, float64(y)+0.5-center)
+			level := uint8(255 * math.Max(0, 1-distance/center))
+			mask.SetNRGBA(x, y, color.NRGBA{level, level, level, 255})
+		}
+	}
+
+	return mask
+}
+
+func TestApplyAlphaMask(t *testing.T) {
+	masked := ApplyAlphaMask(solidImage(64, 64, red), radialMask(64))
+
+	tests := []struct {
+		name     string
+		x, y     int
+		min, max uint8
+	}{
+		{"center", 32, 32, 245, 255},
+		{"halfway", 48, 32, 100, 155},
+		{"edge", 63, 32, 0, 10},
+		{"corner", 0, 0, 0, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := pixel(masked, test.x, test.y)
+			if got.A < test.min || got.A > test.max {
+				t.Errorf("alpha %d, want %d..%d", got.A, test.min, test.max)
+			}
+
+			if got.R != 255 || got.G != 0 || got.B != 0 {
+				t.Errorf("color %v, want red", got)
+			}
+		})
+	}
+}
+
+func TestBackgroundImageSize(t *testing.T) {
+	photo := splitImage(123, 77, red, blue)
+	_, assets := testAssets(t, map[string]image.Image{"photo": photo})
+
+	buff := new(bytes.Buffer)
+	if err := EncodeImage(buff, photo, PNG, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// No width or height, just an annotation on top
+	box := Rectangle{Position: Position{10, 10}, WidthPx: 20, HeightPx: 20, Color: green}
+
+	tests := []struct {
+		name    string
+		request ImgRequest
+	}{
+		{"base64", ImgRequest{BgImgBase64: base64.StdEncoding.EncodeToString(buff.Bytes()), Rectangles: []Rectangle{box}}},
+		{"path", ImgRequest{BgImgPath: "photo", Rectangles: []Rectangle{box}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			img := renderWithAssets(t, test.request, nil, assets)
+
+			if size := img.Bounds().Size(); size != photo.Bounds().Size() {
+				t.Fatalf("size %v, want the source %v", size, photo.Bounds().Size())
+			}
+
+			// Rectangles are outlines
+			if got := pixel(img, 10, 20); got != nrgba(green) {
+				t.Errorf("annotation is %v, want %v", got, green)
+			}
+
+			if got := pixel(img, 20, 20); got != nrgba(red) {
+				t.Errorf("inside the annotation is %v, want %v", got, red)
+			}
+
+			if got := pixel(img, 100, 60); got != nrgba(blue) {
+				t.Errorf("background is %v, want %v", got, blue)
+			}
+		})
+	}
+}
+
+func TestPlacedImageFit(t *testing.T) {
+	// Three 40px stripes, so only the middle one fits a 40px square without
+	// scaling
+	stripes := solidImage(120, 40, red)
+	for y := 0; y < 40; y++ {
+		for x := 40; x < 120; x++ {
+			stripes.SetNRGBA(x, y, nrgba(green))
+			if x >= 80 {
+				stripes.SetNRGBA(x, y, nrgba(blue))
+			}
+		}
+	}
+	_, assets := testAssets(t, map[string]image.Image{"stripes": stripes})
+
+	tests := []struct {
+		name         string
+		fit          ImageFit
+		cornerRadius float64
+		// Samples along the middle row and the top-left corner of the cell
+		left, middle, right, corner Color
+	}{
+		{"cover", Cover, 0, green, green, green, green},
+		{"cover rounded", Cover, 12, green, green, green, white},
+		{"stretch", Stretch, 0, red, green, blue, red},
+		{"contain", Contain, 0, red, green, blue, white},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := ImgRequest{
+				WidthPx:  60,
+				HeightPx: 60,
+				BgColor:  white,
+				Images:   []PlacedImage{{Path: "stripes", Position: Position{10, 10}, WidthPx: 40, HeightPx: 40, Fit: test.fit, CornerRadiusPx: test.cornerRadius}},
+			}
+			img := renderWithAssets(t, request, nil, assets)
+
+			for _, sample := range []struct {
+				x, y int
+				want Color
+			}{{13, 30, test.left}, {30, 30, test.middle}, {46, 30, test.right}, {10, 10, test.corner}} {
+				if got := pixel(img, sample.x, sample.y); !closeTo(got, nrgba(sample.want), 8) {
+					t.Errorf("pixel (%d, %d) is %v, want %v", sample.x, sample.y, got, sample.want)
+				}
+			}
+
+			// Covering leaves no background inside the square cell
+			if test.fit == Cover && test.cornerRadius == 0 {
+				if ink := inkBounds(img); ink != image.Rect(10, 10, 50, 50) {
+					t.Errorf("image covers %v, want the whole cell", ink)
+				}
+			}
+		})
+	}
+}
+
+func TestRotateImage(t *testing.T) {
+	tests := []struct {
+		deg    float64
+		size   image.Point
+		marker image.Point // where the marker at (10, 10) ends up
+	}{
+		{90, image.Pt(40, 80), image.Pt(29, 10)},
+		{180, image.Pt(80, 40), image.Pt(69, 29)},
+		{270, image.Pt(40, 80), image.Pt(10, 69)},
+		{-90, image.Pt(40, 80), image.Pt(10, 69)},
+		{450, image.Pt(40, 80), image.Pt(29, 10)},
+	}
+
+	for _, test := range tests {
+		t.Run(strconv.FormatFloat(test.deg, 'f', -1, 64), func(t *testing.T) {
+			request := ImgRequest{
+				WidthPx:   80,
+				HeightPx:  40,
+				BgColor:   red,
+				Format:    PNG,
+				Circles:   []Circle{{Center: Position{10.5, 10.5}, RadiusPx: 4, FillColor: green}},
+				RotateDeg: test.deg,
+			}
+			img := decodeImage(t, generateRequest(t, request, nil))
+
+			if got := img.Bounds().Size(); got != test.size {
+				t.Fatalf("image is %v, want %v", got, test.size)
+			}
+
+			if got := pixel(img, test.marker.X, test.marker.Y); got != nrgba(green) {
+				t.Errorf("marker pixel %v is %v, want %v", test.marker, got, green)
+			}
+		})
+	}
+
+	// Other angles grow the canvas to fit and fill the corners
+	request := ImgRequest{WidthPx: 80, HeightPx: 40, BgColor: red, Format: PNG, RotateDeg: 45}
+	img := decodeImage(t, generateRequest(t, request, nil))
+	if size := img.Bounds().Size(); size.X < 84 || size.X > 86 || size.Y != size.X {
+		t.Errorf("45 degrees gives %v, want about 85x85", size)
+	}
+
+	if got := pixel(img, 0, 0); got != nrgba(red) {
+		t.Errorf("corner is %v, want the background %v", got, red)
+	}
+}
+
+// grayRamp runs from black on the left to white on the right
+func grayRamp(width, height int) *image.NRGBA {
+	ramp := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			level := uint8(x * 255 / (width - 1))
+			ramp.SetNRGBA(x, y, color.NRGBA{level, level, level, 255})
+		}
+	}
+
+	return ramp
+}
+
+func TestGradientMapDuotone(t *testing.T) {
+	orange := Color{255, 140, 0, 255}
+	_, assets := testAssets(t, map[string]image.Image{"ramp": grayRamp(256, 10)})
+
+	request := ImgRequest{
+		WidthPx:  256,
+		HeightPx: 10,
+		BgColor:  white,
+		Images:   []PlacedImage{{Path: "ramp", GradientMap: []ColorStop{{0, black}, {1, orange}}}},
+	}
+	img := renderWithAssets(t, request, nil, assets)
+
+	tests := []struct {
+		name string
+		x    int
+		want color.NRGBA
+	}{
+		{"dark", 2, nrgba(black)},
+		{"middle", 128, color.NRGBA{128, 70, 0, 255}},
+		{"bright", 253, nrgba(orange)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := pixel(img, test.x, 5); !closeTo(got, test.want, 6) {
+				t.Errorf("pixel at x=%d is %v, want %v", test.x, got, test.want)
+			}
+		})
+	}
+
+	// More stops give a tritone, and stop alpha fades the image
+	mapped := ApplyGradientMap(grayRamp(256, 1), []ColorStop{{0, red}, {0.5, green}, {1, Color{0, 0, 255, 0}}})
+	for x, want := range map[int]color.NRGBA{0: nrgba(red), 128: nrgba(green), 255: {0, 0, 255, 0}} {
+		if got := pixel(mapped, x, 0); !closeTo(got, want, 4) {
+			t.Errorf("tritone at x=%d is %v, want %v", x, got, want)
+		}
+	}
+
+	request.Images[0].GradientMap = []ColorStop{{0, black}, {2, orange}}
+	if err := PrepareRequest(&request, nil, assets, testDefaults); err == nil {
+		t.Error("stop offset 2 was accepted")
+	}
+}
+
+func TestTrim(t *testing.T) {
+	fonts := newTestFonts(t)
+	request := ImgRequest{
+		WidthPx:         400,
+		HeightPx:        300,
+		BgColor:         white,
+		SingleLineTexts: []StyledText{{Text: "Trim me", Font: fonts.regular, SizePx: pixels(32), Color: black, Position: textPosition(Position{150, 160})}},
+	}
+	text := inkBounds(decodeImage(t, generateRequest(t, request, fonts.faces())))
+	if text.Empty() {
+		t.Fatal("no text was drawn")
+	}
+
+	tests := []struct {
+		name string
+		trim Trim
+		want image.Point
+	}{
+		{"tight", Trim{Tolerance: 8}, text.Size()},
+		{"padding", Trim{Tolerance: 8, PaddingPx: 10}, text.Size().Add(image.Pt(20, 20))},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request.Trim = &test.trim
+			img := decodeImage(t, generateRequest(t, request, fonts.faces()))
+
+			if got := img.Bounds().Size(); got != test.want {
+				t.Errorf("trimmed to %v, want %v around the text", got, test.want)
+			}
+
+			if got := inkBounds(img).Size(); got != text.Size() {
+				t.Errorf("text is %v after trimming, want %v", got, text.Size())
+			}
+		})
+	}
+
+	// Nothing but background stays as it is
+	blank := TrimImage(solidImage(40, 30, red), Trim{})
+	if got := blank.Bounds().Size(); got != image.Pt(40, 30) {
+		t.Errorf("blank image trimmed to %v, want 40x30", got)
+	}
+}
+
+func TestCanvasCornerRadius(t *testing.T) {
+	request := ImgRequest{WidthPx: 100, HeightPx: 80, BgColor: red, CanvasCornerRadiusPx: 20, Format: PNG}
+	img := decodeImage(t, generateRequest(t, request, nil))
+
+	tests := []struct {
+		name   string
+		points []image.Point
+		alpha  uint8
+	}{
+		{"corners", []image.Point{{0, 0}, {99, 0}, {0, 79}, {99, 79}, {3, 3}, {96, 76}}, 0},
+		{"inside the arcs", []image.Point{{10, 10}, {89, 10}, {10, 69}, {89, 69}}, 255},
+		{"center and edges", []image.Point{{50, 40}, {50, 0}, {0, 40}, {99, 40}, {50, 79}}, 255},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, point := range test.points {
+				if got := pixel(img, point.X, point.Y); got.A != test.alpha {
+					t.Errorf("pixel %v is %v, want alpha %d", point, got, test.alpha)
+				}
+			}
+		})
+	}
+
+	if got := pixel(img, 50, 40); got != nrgba(red) {
+		t.Errorf("center is %v, want %v", got, red)
+	}
+
+	request.Format = JPEG
+	if err := PrepareRequest(&request, nil, nil, testDefaults); err == nil {
+		t.Error("rounded corners were accepted for jpeg")
+	}
+}
+
+func TestBgColorBehindBackgroundImage(t *testing.T) {
+	// Transparent on the left, half transparent blue in the middle and opaque
+	// blue on the right
+	photo := solidImage(60, 20, blue)
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 40; x++ {
+			photo.SetNRGBA(x, y, color.NRGBA{0, 0, 255, uint8(x / 20 * 128)})
+		}
+	}
+	_, assets := testAssets(t, map[string]image.Image{"photo": photo})
+
+	tests := []struct {
+		name      string
+		format    ImageFormat
+		tolerance int
+	}{
+		{"png", PNG, 1},
+		{"jpeg", JPEG, 12},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := ImgRequest{BgImgPath: "photo", BgColor: red, Format: test.format}
+			if err := PrepareRequest(&request, nil, assets, testDefaults); err != nil {
+				t.Fatal(err)
+			}
+
+			buff, err := TryGenerateImage(request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			img := decodeImage(t, buff.Bytes())
+
+			for x, want := range map[int]color.NRGBA{10: nrgba(red), 30: {127, 0, 128, 255}, 50: nrgba(blue)} {
+				if got := pixel(img, x, 10); !closeTo(got, want, test.tolerance) {
+					t.Errorf("pixel at x=%d is %v, want %v", x, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBgImgFocus(t *testing.T) {
+	// A tall image of red, green and blue bands from the top, shown on a wide
+	// canvas so cover keeps a third of one band
+	bands := solidImage(40, 120, blue)
+	for y := 0; y < 80; y++ {
+		for x := 0; x < 40; x++ {
+			bands.SetNRGBA(x, y, nrgba([]Color{red, green}[y/40]))
+		}
+	}
+	_, assets := testAssets(t, map[string]image.Image{"bands": bands})
+
+	tests := []struct {
+		name  string
+		focus *Position
+		want  Color
+	}{
+		{"center", nil, green},
+		{"top", &Position{0.5, 0}, red},
+		{"top third", &Position{0.5, 1.0 / 6}, red},
+		{"bottom", &Position{0.5, 1}, blue},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := ImgRequest{WidthPx: 120, HeightPx: 40, BgImgPath: "bands", BgImgFit: Cover, BgImgFocus: test.focus}
+			img := renderWithAssets(t, request, nil, assets)
+
+			for _, point := range [][2]int{{60, 2}, {60, 37}, {2, 20}, {117, 20}} {
+				if got := pixel(img, point[0], point[1]); !closeTo(got, nrgba(test.want), 8) {
+					t.Errorf("pixel %v is %v, want %v", point, got, test.want)
+				}
+			}
+		})
+	}
+
+	// Only cover crops
+	request := ImgRequest{WidthPx: 120, HeightPx: 40, BgImgPath: "bands", BgImgFit: Contain, BgImgFocus: &Position{0.5, 0}}
+	if err := PrepareRequest(&request, nil, assets, testDefaults); err == nil {
+		t.Error("focus was accepted with contain")
+	}
+}
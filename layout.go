@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fogleman/gg"
+)
+
+type Anchor string
+
+const (
+	TopLeft     Anchor = "topLeft"
+	Top         Anchor = "top"
+	TopRight    Anchor = "topRight"
+	LeftCenter  Anchor = "left"
+	Middle      Anchor = "center"
+	RightCenter Anchor = "right"
+	BottomLeft  Anchor = "bottomLeft"
+	Bottom      Anchor = "bottom"
+	BottomRight Anchor = "bottomRight"
+)
+
+var anchors = map[Anchor]bool{
+	TopLeft: true, Top: true, TopRight: true,
+	LeftCenter: true, Middle: true, RightCenter: true,
+	BottomLeft: true, Bottom: true, BottomRight: true,
+}
+
+// Anchoring places an element relative to the canvas instead of at a raw
+// Position. The margin keeps the element away from the edges it is anchored
+// to and is ignored along centered axes.
+type Anchoring struct {
+	Anchor   Anchor  `json:"anchor"`
+	MarginPx float64 `json:"marginPx"`
+}
+
+func (anchoring Anchoring) Validate() error {
+	if anchoring.Anchor != "" && !anchors[anchoring.Anchor] {
+		return fmt.Errorf("unsupported anchor %q", anchoring.Anchor)
+	}
+
+	return nil
+}
+
+// Resolve returns the top-left corner of a widthPx x heightPx box anchored on
+// the canvas, or position unchanged when no anchor is set
+func (anchoring Anchoring) Resolve(position Position, widthPx, heightPx, canvasWidthPx, canvasHeightPx float64) Position {
+	if anchoring.Anchor == "" {
+		return position
+	}
+
+	name := strings.ToLower(string(anchoring.Anchor))
+	margin := anchoring.MarginPx
+
+	x := (canvasWidthPx - widthPx) / 2
+	switch {
+	case strings.HasSuffix(name, "left"):
+		x = margin
+	case strings.HasSuffix(name, "right"):
+		x = canvasWidthPx - widthPx - margin
+	}
+
+	y := (canvasHeightPx - heightPx) / 2
+	switch {
+	case strings.HasPrefix(name, "top"):
+		y = margin
+	case strings.HasPrefix(name, "bottom"):
+		y = canvasHeightPx - heightPx - margin
+	}
+
+	return Position{x, y}
+}
+
+// Rotation turns an element clockwise about a pivot. PivotX and PivotY are
+// fractions of the element box from its top-left corner, or canvas pixels with
+// PivotPx. An omitted coordinate is the center of the box.
+type Rotation struct {
+	RotationDeg float64  `json:"rotationDeg"`
+	PivotX      *float64 `json:"pivotX"`
+	PivotY      *float64 `json:"pivotY"`
+	PivotPx     bool     `json:"pivotPx"`
+}
+
+// Pivot returns the point the box at (x, y) rotates about
+func (rotation Rotation) Pivot(x, y, widthPx, heightPx float64) (float64, float64) {
+	pivot := func(pivot *float64, start, length float64) float64 {
+		switch {
+		case pivot == nil:
+			return start + length/2
+		case rotation.PivotPx:
+			return *pivot
+		}
+
+		return start + *pivot*length
+	}
+
+	return pivot(rotation.PivotX, x, widthPx), pivot(rotation.PivotY, y, heightPx)
+}
+
+// Rotate applies the rotation to dc for the box at (x, y) in request
+// coordinates. scale is 1 on scaled contexts and the supersampling factor on
+// the identity transforms text is drawn with.
+func (rotation Rotation) Rotate(dc *gg.Context, x, y, widthPx, heightPx, scale float64) {
+	if rotation.RotationDeg == 0 {
+		return
+	}
+
+	pivotX, pivotY := rotation.Pivot(x, y, widthPx, heightPx)
+	dc.RotateAbout(gg.Radians(rotation.RotationDeg), pivotX*scale, pivotY*scale)
+}
+
+// canvasSize is the size of dc in request coordinates
+func canvasSize(dc *gg.Context) (float64, float64) {
+	scale := deviceScale(dc)
+	return float64(dc.Width()) / scale, float64(dc.Height()) / scale
+}
+
+type PositionAnchor string
+
+const (
+	BaselineAnchor PositionAnchor = "baseline"
+	TopAnchor      PositionAnchor = "top"
+	CenterAnchor   PositionAnchor = "center"
+)
+
+// AnchorText resolves the baseline position of single-line text, measuring it
+// with its own face since the anchor box depends on the text size
+func AnchorText(text StyledText, canvasWidthPx, canvasHeightPx float64) Position {
+	if text.Anchor == "" && (text.PositionAnchor == "" || text.PositionAnchor == BaselineAnchor) {
+		return text.Position.Pixels()
+	}
+
+	position := text.Position.Pixels()
+	widthPx, ascent, descent := MeasureText(text)
+	heightPx := ascent + descent
+
+	if text.Anchor == "" {
+		// PositionAnchor moves the baseline so Position.Y is the top or the
+		// vertical center of the line box
+		if text.PositionAnchor == TopAnchor {
+			return Position{position.X, position.Y + ascent}
+		}
+
+		return Position{position.X, position.Y + (ascent-descent)/2}
+	}
+
+	topLeft := text.Anchoring.Resolve(position, widthPx, heightPx, canvasWidthPx, canvasHeightPx)
+	return Position{topLeft.X, topLeft.Y + ascent}
+}
+
+// MeasureText returns the advance width of single-line text, capped by its
+// truncation width, and the ascent and descent of its face
+func MeasureText(text StyledText) (widthPx, ascent, descent float64) {
+	face, err := text.loadFace(text.Font, text.SizePx.Value)
+	if err != nil {
+		panic(err)
+	}
+	defer face.Close()
+
+	label := text.Text
+	if text.Markup {
+		label = ""
+		for _, run := range parseMarkup(text.Text) {
+			label += run.text
+		}
+	}
+
+	widthPx = measureRun(face, label)
+	if text.TruncateWidthPx > 0 {
+		widthPx = min(widthPx, text.TruncateWidthPx)
+	}
+
+	metrics := face.Metrics()
+	return widthPx, float64(metrics.Ascent) / 64, float64(metrics.Descent) / 64
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func TestAnchorBottomRight(t *testing.T) {
+	tests := []struct {
+		width, height int
+		want          Position
+	}{
+		{200, 100, Position{140, 50}},
+		{400, 300, Position{340, 250}},
+	}
+
+	for _, test := range tests {
+		request := ImgRequest{
+			WidthPx:    test.width,
+			HeightPx:   test.height,
+			BgColor:    white,
+			Rectangles: []Rectangle{{Anchoring: Anchoring{BottomRight, 20}, WidthPx: 40, HeightPx: 30, Color: red}},
+		}
+
+		if err := PrepareRequest(&request, nil, nil, testDefaults); err != nil {
+			t.Fatal(err)
+		}
+
+		layout, err := TryComputeLayout(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := layout.Elements[0]; got.X != test.want.X || got.Y != test.want.Y {
+			t.Errorf("%dx%d: rectangle at (%v, %v), want %v", test.width, test.height, got.X, got.Y, test.want)
+		}
+
+		// The outline straddles the box edges by half its width
+		img, err := tryCompose(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := image.Rect(int(test.want.X)-3, int(test.want.Y)-3, int(test.want.X)+43, int(test.want.Y)+33)
+		if got := inkBounds(img); got != want {
+			t.Errorf("%dx%d: ink at %v, want %v", test.width, test.height, got, want)
+		}
+	}
+}
+
+func TestPositionAnchor(t *testing.T) {
+	fonts := newTestFonts(t)
+
+	metrics, err := MeasureFont(fonts.regular, 40, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rows of the cap top and the baseline for Position.Y = 30
+	tests := []struct {
+		anchor   PositionAnchor
+		baseline float64
+	}{
+		{"", 30},
+		{BaselineAnchor, 30},
+		{TopAnchor, 30 + metrics.AscentPx},
+		{CenterAnchor, 30 + (metrics.AscentPx-metrics.DescentPx)/2},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.anchor), func(t *testing.T) {
+			request := ImgRequest{
+				WidthPx:         100,
+				HeightPx:        120,
+				BgColor:         white,
+				SingleLineTexts: []StyledText{{Text: "H", Font: fonts.regular, SizePx: pixels(40), Color: black, Position: textPosition(Position{10, 30}), PositionAnchor: test.anchor}},
+			}
+			ink := inkBounds(renderRequest(t, request, fonts.faces()))
+
+			capTop := test.baseline - metrics.CapHeightPx
+			if math.Abs(float64(ink.Min.Y)-capTop) > 1.5 || math.Abs(float64(ink.Max.Y)-test.baseline) > 1.5 {
+				t.Errorf("H spans rows %d..%d, want %.1f..%.1f", ink.Min.Y, ink.Max.Y, capTop, test.baseline)
+			}
+
+			// Top anchoring keeps the whole line box below Position.Y
+			if test.anchor == TopAnchor && float64(ink.Min.Y) < 30 {
+				t.Errorf("cap starts at row %d, above the position", ink.Min.Y)
+			}
+		})
+	}
+}
+
+func TestRotationPivot(t *testing.T) {
+	zero, one := 0.0, 1.0
+	centerX, centerY := 130.0, 70.0
+
+	// A 60x20 outline at (100, 60) turned a quarter clockwise. Ink straddles
+	// the box by half of the 5px stroke.
+	tests := []struct {
+		name     string
+		rotation Rotation
+		want     image.Rectangle
+	}{
+		{"center", Rotation{RotationDeg: 90}, image.Rect(117, 37, 143, 103)},
+		{"top-left", Rotation{RotationDeg: 90, PivotX: &zero, PivotY: &zero}, image.Rect(77, 57, 103, 123)},
+		{"bottom-right", Rotation{RotationDeg: 90, PivotX: &one, PivotY: &one}, image.Rect(157, 17, 183, 83)},
+		{"center in pixels", Rotation{RotationDeg: 90, PivotX: &centerX, PivotY: &centerY, PivotPx: true}, image.Rect(117, 37, 143, 103)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rectangle := Rectangle{Position: Position{100, 60}, WidthPx: 60, HeightPx: 20, Color: red, Rotation: test.rotation}
+			img := renderRequest(t, ImgRequest{WidthPx: 240, HeightPx: 160, BgColor: white, Rectangles: []Rectangle{rectangle}}, nil)
+
+			got := inkBounds(img)
+			for _, diff := range []int{got.Min.X - test.want.Min.X, got.Min.Y - test.want.Min.Y, got.Max.X - test.want.Max.X, got.Max.Y - test.want.Max.Y} {
+				if diff < -1 || diff > 1 {
+					t.Errorf("ink at %v, want %v", got, test.want)
+					break
+				}
+			}
+		})
+	}
+}
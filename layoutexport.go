@@ -0,0 +1,252 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+// Layout is the geometry of a prepared request, returned by ?layout=json in
+// place of the image
+type Layout struct {
+	WidthPx  int             `json:"widthPx"`
+	HeightPx int             `json:"heightPx"`
+	Format   ImageFormat     `json:"format"`
+	Elements []LayoutElement `json:"elements"`
+}
+
+// LayoutElement is the box an element occupies in canvas pixels, before any
+// rotation, together with the element as it is rendered with every default
+// filled in. Text boxes run from the ascent to the descent of the line.
+type LayoutElement struct {
+	Type     string  `json:"type"`
+	Index    int     `json:"index"`
+	X        float64 `json:"x"`
+	Y        float64 `json:"y"`
+	WidthPx  float64 `json:"widthPx"`
+	HeightPx float64 `json:"heightPx"`
+	Element  any     `json:"element"`
+}
+
+// TryComputeLayout turns measuring panics, such as unreadable fonts, into
+// errors like TryGenerateImage
+func TryComputeLayout(request ImgRequest) (layout Layout, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("%v", recovered)
+		}
+	}()
+
+	return ComputeLayout(request), nil
+}
+
+// ComputeLayout lists every element of a prepared request in drawing order
+func ComputeLayout(request ImgRequest) Layout {
+	canvasWidth, canvasHeight := float64(request.WidthPx), float64(request.HeightPx)
+	elements := []LayoutElement{}
+
+	add := func(kind string, index int, x, y, widthPx, heightPx float64, element any) {
+		elements = append(elements, LayoutElement{kind, index, x, y, widthPx, heightPx, element})
+	}
+
+	// bounds adds the box around points
+	bounds := func(kind string, index int, points []Position, element any) {
+		minX, minY := math.Inf(1), math.Inf(1)
+		maxX, maxY := math.Inf(-1), math.Inf(-1)
+		for _, point := range points {
+			minX, minY = math.Min(minX, point.X), math.Min(minY, point.Y)
+			maxX, maxY = math.Max(maxX, point.X), math.Max(maxY, point.Y)
+		}
+
+		add(kind, index, minX, minY, maxX-minX, maxY-minY, element)
+	}
+
+	// Rich text and markdown are laid out by drawing them onto a scratch
+	// canvas, which clips everything but still reports where the text ends
+	scratch := gg.NewContext(1, 1)
+
+	for i, placed := range request.Images {
+		widthPx, heightPx := placedImageSize(placed, request.BatchResults, request.ImageCache)
+		placed.Position = placed.Resolve(placed.Position, widthPx, heightPx, canvasWidth, canvasHeight)
+		add("image", i, placed.Position.X, placed.Position.Y, widthPx, heightPx, placed)
+	}
+
+	for i, text := range request.SingleLineTexts {
+		position := AnchorText(text, canvasWidth, canvasHeight)
+		widthPx, ascent, descent := MeasureText(text)
+		add("singleLineText", i, position.X, position.Y-ascent, widthPx, ascent+descent, text)
+	}
+
+	for i, rectangle := range request.Rectangles {
+		rectangle.Position = rectangle.Resolve(rectangle.Position, rectangle.WidthPx, rectangle.HeightPx, canvasWidth, canvasHeight)
+		add("rectangle", i, rectangle.Position.X, rectangle.Position.Y, rectangle.WidthPx, rectangle.HeightPx, rectangle)
+	}
+
+	for i, group := range request.RectangleGroups {
+		points := []Position{}
+		for _, box := range group.Rectangles {
+			points = append(points, box.Position, Position{box.Position.X + box.WidthPx, box.Position.Y + box.HeightPx})
+		}
+
+		bounds("rectangleGroup", i, points, group)
+	}
+
+	for i, circle := range request.Circles {
+		if circle.Anchor != "" {
+			topLeft := circle.Resolve(circle.Center, 2*circle.RadiusPx, 2*circle.RadiusPx, canvasWidth, canvasHeight)
+			circle.Center = Position{topLeft.X + circle.RadiusPx, topLeft.Y + circle.RadiusPx}
+		}
+
+		add("circle", i, circle.Center.X-circle.RadiusPx, circle.Center.Y-circle.RadiusPx, 2*circle.RadiusPx, 2*circle.RadiusPx, circle)
+	}
+
+	for i, bar := range request.ProgressBars {
+		bar.Position = bar.Resolve(bar.Position, bar.WidthPx, bar.HeightPx, canvasWidth, canvasHeight)
+		add("progressBar", i, bar.Position.X, bar.Position.Y, bar.WidthPx, bar.HeightPx, bar)
+	}
+
+	for i, rating := range request.StarRatings {
+		if rating.Count == 0 {
+			rating.Count = 5
+		}
+
+		widthPx := float64(rating.Count)*(rating.SizePx+rating.GapPx) - rating.GapPx
+		add("starRating", i, rating.Position.X, rating.Position.Y, widthPx, rating.SizePx, rating)
+	}
+
+	for i, icon := range request.Icons {
+		if icon.SizePx == 0 {
+			icon.SizePx = 24
+		}
+
+		add("icon", i, icon.Position.X, icon.Position.Y, icon.SizePx, icon.SizePx, icon)
+	}
+
+	for i, chart := range request.BarCharts {
+		if chart.GapPx == 0 {
+			chart.GapPx = 8
+		}
+
+		add("barChart", i, chart.Position.X, chart.Position.Y, chart.WidthPx, chart.HeightPx, chart)
+	}
+
+	for i, sparkline := range request.Sparklines {
+		if sparkline.LineWidthPx == 0 {
+			sparkline.LineWidthPx = 2
+		}
+
+		add("sparkline", i, sparkline.Position.X, sparkline.Position.Y, sparkline.WidthPx, sparkline.HeightPx, sparkline)
+	}
+
+	for i, countdown := range request.Countdowns {
+		if countdown.Digits == 0 {
+			countdown.Digits = 2
+		}
+
+		if countdown.GapPx == 0 {
+			countdown.GapPx = 16
+		}
+
+		if countdown.LabelSizePx == 0 {
+			countdown.LabelSizePx = countdown.SizePx / 3
+		}
+
+		count := float64(len(countdown.Values))
+		heightPx := countdown.BoxHeightPx
+		if len(countdown.Labels) > 0 {
+			// Labels are centered a label size below the boxes
+			heightPx += 1.5 * countdown.LabelSizePx
+		}
+
+		add("countdown", i, countdown.Position.X, countdown.Position.Y, count*(countdown.BoxWidthPx+countdown.GapPx)-countdown.GapPx, heightPx, countdown)
+	}
+
+	for i, triangle := range request.Triangles {
+		bounds("triangle", i, triangle.Vertices[:], triangle)
+	}
+
+	for i, path := range request.Paths {
+		bounds("path", i, path.points(), path)
+	}
+
+	// The control points bound the curve
+	for i, curve := range request.Curves {
+		if curve.WidthPx == 0 {
+			curve.WidthPx = 1
+		}
+
+		points := []Position{curve.Start, curve.Control1, curve.End}
+		if curve.Control2 != nil {
+			points = append(points, *curve.Control2)
+		}
+
+		bounds("curve", i, points, curve)
+	}
+
+	for i, callout := range request.Callouts {
+		callout.applyDefaults()
+
+		x, y, widthPx, heightPx := callout.Position.X, callout.Position.Y, callout.WidthPx, callout.HeightPx
+		switch callout.PointerEdge {
+		case TopEdge:
+			y -= callout.PointerLengthPx
+			heightPx += callout.PointerLengthPx
+		case BottomEdge:
+			heightPx += callout.PointerLengthPx
+		case LeftEdge:
+			x -= callout.PointerLengthPx
+			widthPx += callout.PointerLengthPx
+		case RightEdge:
+			widthPx += callout.PointerLengthPx
+		}
+
+		add("callout", i, x, y, widthPx, heightPx, callout)
+	}
+
+	for i, text := range request.MultiLineTexts {
+		face, err := text.loadFace(text.Font, text.SizePx.Value)
+		if err != nil {
+			panic(err)
+		}
+
+		scratch.SetFontFace(face)
+		lines := scratch.WordWrap(LimitLines(scratch, text.Text, text.WrapWidthPx.Value, text.MaxLines), text.WrapWidthPx.Value)
+		face.Close()
+
+		lineHeight := scratch.FontHeight()
+		heightPx := 0.0
+		if len(lines) > 0 {
+			heightPx = float64(len(lines)-1)*lineHeight*text.LineSpacingMultiple(lineHeight) + lineHeight
+		}
+		if text.VerticalDistribution != "" {
+			heightPx = text.BoxHeightPx
+		}
+
+		add("multiLineText", i, text.Position.X.Value, text.Position.Y.Value, text.WrapWidthPx.Value, heightPx, text)
+	}
+
+	for i, richText := range request.RichTexts {
+		bottom := DrawRichText(scratch, richText)
+		add("richText", i, richText.Position.X, richText.Position.Y, richText.WrapWidthPx, bottom-richText.Position.Y, richText)
+	}
+
+	for i, markdown := range request.Markdowns {
+		bottom := DrawMarkdown(scratch, markdown)
+		add("markdown", i, markdown.Position.X, markdown.Position.Y, markdown.WrapWidthPx, bottom-markdown.Position.Y, markdown)
+	}
+
+	if request.Border != nil {
+		add("border", 0, 0, 0, canvasWidth, canvasHeight, *request.Border)
+	}
+
+	for i, region := range request.BlurRegions {
+		add("blurRegion", i, region.Position.X, region.Position.Y, region.WidthPx, region.HeightPx, region)
+	}
+
+	for i, region := range request.PixelateRegions {
+		add("pixelateRegion", i, region.Position.X, region.Position.Y, region.WidthPx, region.HeightPx, region)
+	}
+
+	return Layout{request.WidthPx, request.HeightPx, request.Format, elements}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestLayoutJSON(t *testing.T) {
+	fonts := newTestFonts(t)
+	server := newTestServer(t, Config{FontDir: fonts.dir})
+
+	body := map[string]any{
+		"widthPx":         200,
+		"heightPx":        100,
+		"singleLineTexts": []map[string]any{{"text": "Hello", "font": fonts.regular, "position": map[string]any{"x": 10, "y": 30}}},
+		"multiLineTexts":  []map[string]any{{"styledText": map[string]any{"text": "one two three four", "font": fonts.regular, "sizePx": 16, "position": map[string]any{"x": "5%", "y": 40}}, "wrapWidthPx": "30%", "lineSpacingPx": 1.5}},
+		"rectangles":      []map[string]any{{"anchor": "bottomRight", "marginPx": 20, "widthPx": 40, "heightPx": 30, "color": red}},
+		"circles":         []map[string]any{{"center": map[string]any{"x": 150, "y": 50}, "radiusPx": 10, "fillColor": blue}},
+	}
+
+	response := postJSON(t, server.URL+"/generate?layout=json", body)
+	data := readBody(t, response)
+	if response.StatusCode != 200 {
+		t.Fatalf("status %d: %s", response.StatusCode, data)
+	}
+
+	var layout struct {
+		WidthPx, HeightPx int
+		Format            ImageFormat
+		Elements          []struct {
+			Type                    string
+			Index                   int
+			X, Y, WidthPx, HeightPx float64
+			Element                 map[string]any
+		}
+	}
+	if err := json.Unmarshal(data, &layout); err != nil {
+		t.Fatal(err)
+	}
+
+	if layout.WidthPx != 200 || layout.HeightPx != 100 || layout.Format != PNG {
+		t.Errorf("canvas %dx%d %s, want 200x100 png", layout.WidthPx, layout.HeightPx, layout.Format)
+	}
+
+	tests := []struct {
+		kind       string
+		x, y, w, h float64 // NaN where the size depends on measuring
+	}{
+		{"rectangle", 140, 50, 40, 30},
+		{"circle", 140, 40, 20, 20},
+		{"singleLineText", 10, math.NaN(), math.NaN(), math.NaN()},
+		{"multiLineText", 10, 40, 60, math.NaN()},
+	}
+
+	for _, test := range tests {
+		t.Run(test.kind, func(t *testing.T) {
+			for _, element := range layout.Elements {
+				if element.Type != test.kind {
+					continue
+				}
+
+				for _, field := range []struct {
+					name      string
+					got, want float64
+				}{{"x", element.X, test.x}, {"y", element.Y, test.y}, {"width", element.WidthPx, test.w}, {"height", element.HeightPx, test.h}} {
+					if !math.IsNaN(field.want) && field.got != field.want {
+						t.Errorf("%s %v, want %v", field.name, field.got, field.want)
+					}
+				}
+
+				if element.WidthPx <= 0 || element.HeightPx <= 0 {
+					t.Errorf("size %vx%v, want it measured", element.WidthPx, element.HeightPx)
+				}
+
+				if element.Element == nil {
+					t.Error("element is missing")
+				}
+				return
+			}
+
+			t.Errorf("no %s in %s", test.kind, data)
+		})
+	}
+
+	// Defaults are filled in, and wrapping gives the text block several lines
+	for _, element := range layout.Elements {
+		switch element.Type {
+		case "singleLineText":
+			if size := element.Element["sizePx"]; size != 24.0 {
+				t.Errorf("text sizePx %v, want the default 24", size)
+			}
+
+			if baseline := element.Y + element.HeightPx; baseline <= 30 {
+				t.Errorf("text box ends at y=%v, want it below the baseline at 30", baseline)
+			}
+		case "multiLineText":
+			if element.HeightPx < 2*16 {
+				t.Errorf("wrapped text is %vpx tall, want several lines", element.HeightPx)
+			}
+		}
+	}
+
+	// Unknown fonts are refused up front like when rendering
+	body["multiLineTexts"] = []map[string]any{{"styledText": map[string]any{"text": "hi", "font": "missing.ttf"}, "wrapWidthPx": 60}}
+	response = postJSON(t, server.URL+"/generate?layout=json", body)
+	if data := readBody(t, response); response.StatusCode != 400 || !strings.Contains(string(data), errFontNotFound.Error()) {
+		t.Errorf("status %d: %s, want font not found", response.StatusCode, data)
+	}
+}
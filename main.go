@@ -1,217 +1,587 @@
-package main
-
-import (
-	"bytes"
-	"fmt"
-	"image/color"
-	"image/jpeg"
-	"os"
-	"path/filepath"
-	"slices"
-
-	"github.com/fogleman/gg"
-	"github.com/gin-gonic/gin"
-)
-
-type Color struct {
-	R uint8 `json:"r" default:"0"`
-	G uint8 `json:"g" default:"0"`
-	B uint8 `json:"b" default:"0"`
-	A uint8 `json:"a" default:"255"`
-}
-
-type Position struct {
-	X float64 `json:"x"`
-	Y float64 `json:"y"`
-}
-
-type TextAlign string
-
-const (
-	Left   TextAlign = "left"
-	Center TextAlign = "center"
-	Right  TextAlign = "right"
-)
-
-type StyledText struct {
-	Text     string   `json:"text"`
-	Color    Color    `json:"color"`
-	Font     string   `json:"font"`
-	SizePx   float64  `json:"sizePx"`
-	Position Position `json:"position"`
-}
-
-// Set default values for LineSpacingPx
-type MultiLineText struct {
-	StyledText    `json:"styledText"`
-	WrapWidthPx   float64   `json:"wrapWidthPx" binding:"required"`
-	LineSpacingPx float64   `json:"lineSpacingPx" default:"1.5"`
-	Align         TextAlign `json:"align"`
-}
-
-type Rectangle struct {
-	Position Position `json:"position"`
-	Color    Color    `json:"color"`
-	WidthPx  float64  `json:"widthPx"`
-	HeightPx float64  `json:"heightPx"`
-}
-
-type ImgRequest struct {
-	Name            string          `json:"name"`
-	WidthPx         int             `json:"widthPx" binding:"required"`
-	HeightPx        int             `json:"heightPx" binding:"required"`
-	BgImgPath       string          `json:"bgImgPath"`
-	BgColor         Color           `json:"bgColor"`
-	SingleLineTexts []StyledText    `json:"singleLineTexts"`
-	MultiLineTexts  []MultiLineText `json:"multiLineTexts"`
-	Rectangles      []Rectangle     `json:"rectangles"`
-	Quality         int             `json:"quality"`
-}
-
-func GenerateImage(request ImgRequest) *bytes.Buffer {
-	newImg := gg.NewContext(request.WidthPx, request.HeightPx)
-
-	if request.BgImgPath != "" {
-		img, err := gg.LoadImage(request.BgImgPath)
-		if err != nil {
-			panic(err)
-		}
-
-		// Paste image to new image
-		newImg.DrawImage(img, 0, 0)
-	} else if request.BgColor != (Color{}) {
-		newImg.SetColor(color.RGBA{request.BgColor.R, request.BgColor.G, request.BgColor.B, request.BgColor.A})
-		newImg.Clear()
-	} else {
-		panic("No background image or color provided")
-	}
-
-	for _, text := range request.SingleLineTexts {
-
-		fontFace, fontFaceErr := gg.LoadFontFace(text.Font, text.SizePx)
-		if fontFaceErr != nil {
-			panic(fontFaceErr)
-		}
-
-		newImg.SetFontFace(fontFace)
-		newImg.SetColor(color.RGBA{text.Color.R, text.Color.G, text.Color.B, text.Color.A})
-		newImg.DrawString(text.Text, text.Position.X, text.Position.Y)
-	}
-
-	for _, rectangle := range request.Rectangles {
-		strokePattern := gg.NewSolidPattern(color.RGBA{rectangle.Color.R, rectangle.Color.G, rectangle.Color.B, rectangle.Color.A})
-
-		newImg.SetStrokeStyle(strokePattern)
-		newImg.SetLineWidth(5)
-
-		newImg.DrawRectangle(rectangle.Position.X, rectangle.Position.Y, rectangle.WidthPx, rectangle.HeightPx)
-		newImg.Stroke()
-		newImg.Fill()
-	}
-
-	for _, text := range request.MultiLineTexts {
-		fontFace, fontFaceErr := gg.LoadFontFace(text.Font, text.SizePx)
-		if fontFaceErr != nil {
-			panic(fontFaceErr)
-		}
-
-		newImg.SetFontFace(fontFace)
-		newImg.SetColor(color.RGBA{text.Color.R, text.Color.G, text.Color.B, text.Color.A})
-
-		var align gg.Align
-
-		switch text.Align {
-		case Left:
-			align = gg.AlignLeft
-		case Center:
-			align = gg.AlignCenter
-		case Right:
-			align = gg.AlignRight
-		}
-
-		newImg.DrawStringWrapped(
-			text.Text,
-			text.Position.X,
-			text.Position.Y,
-			0,                  // ax: horizontal alignment (0 = left)
-			0,                  // ay: vertical alignment (0 = top)
-			text.WrapWidthPx,   // width before wrapping
-			text.LineSpacingPx, // line spacing
-			align,              // text alignment within the box
-		)
-	}
-
-	// Return Base64 encoded image
-	buff := new(bytes.Buffer)
-	jpeg.Encode(buff, newImg.Image(), &jpeg.Options{Quality: request.Quality})
-
-	return buff
-}
-
-func BuildFontFaceList() []string {
-	// Glob all font files in gfonts folder
-	files, err := filepath.Glob("gfonts/**/*.ttf")
-	if err != nil {
-		panic(err)
-	}
-
-	fontFaces := []string{}
-
-	for _, file := range files {
-		fmt.Println(file)
-		fontFaces = append(fontFaces, file)
-	}
-
-	return fontFaces
-}
-
-func Authenticate() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		token := c.GetHeader("Authorization")
-		if token != os.Getenv("API_KEY") {
-			c.JSON(401, gin.H{"error": "Unauthorized"})
-			c.Abort()
-		}
-	}
-}
-
-func main() {
-	fontFaces := BuildFontFaceList()
-	opts := gin.OptionFunc(func(engine *gin.Engine) {
-		engine.Use(gin.Recovery())
-	})
-
-	router := gin.New(opts)
-
-	router.Use(Authenticate())
-
-	router.GET("/font-faces", func(c *gin.Context) {
-		c.JSON(200, gin.H{"fontFaces": fontFaces})
-	})
-
-	router.POST("/generate", func(c *gin.Context) {
-		var request ImgRequest
-		if err := c.ShouldBindJSON(&request); err != nil {
-			c.JSON(400, gin.H{"error": err.Error()})
-			return
-		}
-
-		for _, text := range request.SingleLineTexts {
-			if !slices.Contains(fontFaces, text.Font) {
-				c.JSON(400, gin.H{"error": "Font not found"})
-				return
-			}
-		}
-
-		image := GenerateImage(request)
-		if image == nil {
-			c.JSON(500, gin.H{"error": "Failed to generate image"})
-			return
-		}
-
-		// Stream image to client
-		c.Data(200, "image/jpeg", image.Bytes())
-	})
-
-	router.Run(":8080")
-}
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/disintegration/imaging"
+	"github.com/fogleman/gg"
+)
+
+type Color struct {
+	R uint8 `json:"r" default:"0"`
+	G uint8 `json:"g" default:"0"`
+	B uint8 `json:"b" default:"0"`
+	A uint8 `json:"a" default:"255"`
+}
+
+type Position struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+type TextAlign string
+
+const (
+	Left   TextAlign = "left"
+	Center TextAlign = "center"
+	Right  TextAlign = "right"
+)
+
+func (align TextAlign) gg() gg.Align {
+	switch align {
+	case Center:
+		return gg.AlignCenter
+	case Right:
+		return gg.AlignRight
+	}
+
+	return gg.AlignLeft
+}
+
+type StyledText struct {
+	Text            string             `json:"text"`
+	Color           Color              `json:"color"`
+	Font            string             `json:"font"`
+	SizePx          Dimension          `json:"sizePx"`
+	Position        TextPosition       `json:"position"`
+	Antialias       *bool              `json:"antialias" default:"true"`
+	Hinting         FontHinting        `json:"hinting" default:"none"`
+	AutoContrast    bool               `json:"autoContrast"`    // black or white depending on the background
+	Markup          bool               `json:"markup"`          // *bold* and _italic_ runs
+	AutoFont        bool               `json:"autoFont"`        // per-script fonts from SCRIPT_FONTS
+	Axes            map[string]float64 `json:"axes"`            // variable font axes such as {"wght": 700}
+	Shortcodes      bool               `json:"shortcodes"`      // expand :heart: style emoji shortcodes
+	TruncateWidthPx float64            `json:"truncateWidthPx"` // single-line only, ends in an ellipsis
+	Shadows         []Shadow           `json:"shadows"`         // drawn back to front before the text
+	Highlights      []HighlightSpan    `json:"highlights"`      // single-line only, recolored rune ranges
+	Wave            *Wave              `json:"wave"`            // single-line only, glyphs follow a sine
+	TabStopsPx      []float64          `json:"tabStopsPx"`      // single-line only, x offsets tabs advance to
+	Anchoring                          // single-line only, replaces Position when set
+	PositionAnchor  PositionAnchor     `json:"positionAnchor" default:"baseline"` // single-line only, what Position.Y refers to
+	Kerning         *bool              `json:"kerning" default:"true"`            // false advances by the plain glyph widths
+	Rotation                           // single-line only, about the line box from ascent to descent
+	Visibility
+
+	// Set when Font named one of the request's font families
+	family *FontFamily
+}
+
+type LineSpacingMode string
+
+const (
+	Multiple LineSpacingMode = "multiple"
+	Absolute LineSpacingMode = "absolute"
+)
+
+// LineDistribution places the wrapped lines of a multi-line text within its
+// box, like justify-content in a CSS column
+type LineDistribution string
+
+const (
+	DistributeTop          LineDistribution = "top"
+	DistributeCenter       LineDistribution = "center"
+	DistributeBottom       LineDistribution = "bottom"
+	DistributeSpaceBetween LineDistribution = "space-between"
+	DistributeSpaceEvenly  LineDistribution = "space-evenly"
+)
+
+// LineSpacingPx is a multiple of the font line height unless LineSpacingMode
+// is "absolute", in which case it is the baseline distance in pixels
+type MultiLineText struct {
+	StyledText      `json:"styledText"`
+	WrapWidthPx     Dimension       `json:"wrapWidthPx"`
+	LineSpacingPx   float64         `json:"lineSpacingPx" default:"1.5"`
+	LineSpacingMode LineSpacingMode `json:"lineSpacingMode" default:"multiple"`
+	Align           TextAlign       `json:"align"`
+	LineAligns      []TextAlign     `json:"lineAligns"` // per wrapped line, empty or missing entries use align
+	MaxLines        int             `json:"maxLines"`   // extra lines are dropped and the last ends in an ellipsis
+	// With verticalDistribution the lines are spread over a box of BoxHeightPx
+	// below Position instead of following the line spacing
+	BoxHeightPx          float64          `json:"boxHeightPx"`
+	VerticalDistribution LineDistribution `json:"verticalDistribution"`
+}
+
+// LineSpacingMultiple converts the spacing into the multiple of the line
+// height that gg expects
+func (text MultiLineText) LineSpacingMultiple(lineHeightPx float64) float64 {
+	if text.LineSpacingMode == Absolute && lineHeightPx > 0 {
+		return text.LineSpacingPx / lineHeightPx
+	}
+
+	return text.LineSpacingPx
+}
+
+type Rectangle struct {
+	Anchoring
+	Position     Position  `json:"position"`
+	Color        Color     `json:"color"`
+	WidthPx      float64   `json:"widthPx"`
+	HeightPx     float64   `json:"heightPx"`
+	FillGradient *Gradient `json:"fillGradient"`
+	ShadowColor  Color     `json:"shadowColor"`
+	ShadowOffset Position  `json:"shadowOffset"`
+	ShadowBlurPx float64   `json:"shadowBlurPx"`
+	// Replaces Color for the outline
+	StrokeGradient *Gradient `json:"strokeGradient"`
+	BlendMode      BlendMode `json:"blendMode" default:"normal"`
+	Rotation
+	Visibility
+}
+
+// RectangleGroup draws many rectangles with one style, setting it up once and
+// sharing one blend layer
+type RectangleGroup struct {
+	Rectangles     []RectangleBox `json:"rectangles"`
+	Color          Color          `json:"color"`
+	FillGradient   *Gradient      `json:"fillGradient"`
+	StrokeGradient *Gradient      `json:"strokeGradient"` // replaces Color for the outline
+	BlendMode      BlendMode      `json:"blendMode" default:"normal"`
+	Visibility
+}
+
+type RectangleBox struct {
+	Position Position `json:"position"`
+	WidthPx  float64  `json:"widthPx"`
+	HeightPx float64  `json:"heightPx"`
+}
+
+// drawRectangles fills boxes with fillGradient when set and outlines them in
+// color or strokeGradient. Each box is filled and stroked on its own, since the
+// rasterizer slows down on paths with many boxes side by side.
+func drawRectangles(dc *gg.Context, boxes []RectangleBox, rectColor Color, fillGradient, strokeGradient *Gradient, scale int) {
+	if fillGradient != nil {
+		dc.SetFillStyle(ScalePattern(fillGradient.Pattern(), scale))
+		for _, box := range boxes {
+			dc.DrawRectangle(box.Position.X, box.Position.Y, box.WidthPx, box.HeightPx)
+			dc.Fill()
+		}
+	}
+
+	strokePattern := gg.NewSolidPattern(color.RGBA{rectColor.R, rectColor.G, rectColor.B, rectColor.A})
+	if strokeGradient != nil {
+		strokePattern = ScalePattern(strokeGradient.Pattern(), scale)
+	}
+
+	dc.SetStrokeStyle(strokePattern)
+	dc.SetLineWidth(5)
+
+	for _, box := range boxes {
+		dc.DrawRectangle(box.Position.X, box.Position.Y, box.WidthPx, box.HeightPx)
+		dc.Stroke()
+	}
+}
+
+type ImgRequest struct {
+	Name            string                `json:"name"`
+	WidthPx         int                   `json:"widthPx"`          // optional with a background image
+	HeightPx        int                   `json:"heightPx"`         // optional with a background image
+	DPI             float64               `json:"dpi" default:"96"` // converts pt dimensions to pixels, stored in jpeg and png output when set
+	Preset          string                `json:"preset"`           // named canvas size such as og-image
+	Profile         string                `json:"profile"`          // web, print or thumbnail, sets format, quality and resizing left out
+	FontFamilies    map[string]FontFamily `json:"fontFamilies"`     // family names usable as fonts of text elements
+	BgImgPath       string                `json:"bgImgPath"`
+	BgImgBase64     string                `json:"bgImgBase64"`
+	BgImgFit        ImageFit              `json:"bgImgFit"`   // stretch, cover or contain to the canvas, drawn as is when omitted
+	BgImgFocus      *Position             `json:"bgImgFocus"` // cover only, the point kept in view as fractions of the image, centered when omitted
+	BgColor         Color                 `json:"bgColor"`    // also shows through transparent parts of a background image
+	BgColorHex      string                `json:"bgColorHex"` // "#112233", replaces bgColor when set
+	BgGradient      *Gradient             `json:"bgGradient"`
+	BgCheckerboard  *Checkerboard         `json:"bgCheckerboard"`
+	Transparent     bool                  `json:"transparent"` // only for alpha formats
+	Background      string                `json:"background"`  // "none" is the same as transparent
+	SingleLineTexts []StyledText          `json:"singleLineTexts"`
+	MultiLineTexts  []MultiLineText       `json:"multiLineTexts"`
+	TextStacks      []TextStack           `json:"textStacks"` // drawn with the single-line texts
+	Rectangles      []Rectangle           `json:"rectangles"`
+	RectangleGroups []RectangleGroup      `json:"rectangleGroups"` // drawn after the rectangles
+	Circles         []Circle              `json:"circles"`
+	Images          []PlacedImage         `json:"images"`
+	ProgressBars    []ProgressBar         `json:"progressBars"`
+	StarRatings     []StarRating          `json:"starRatings"`
+	Icons           []Icon                `json:"icons"`
+	BarCharts       []BarChart            `json:"barCharts"`
+	Sparklines      []Sparkline           `json:"sparklines"`
+	Countdowns      []Countdown           `json:"countdowns"`
+	RichTexts       []RichText            `json:"richTexts"`
+	Markdowns       []Markdown            `json:"markdowns"`
+	Curves          []Curve               `json:"curves"`
+	Triangles       []Triangle            `json:"triangles"`
+	Paths           []Path                `json:"paths"` // drawn after the triangles
+	Callouts        []Callout             `json:"callouts"`
+	Border          *Border               `json:"border"` // drawn after every other element
+	BlurRegions     []BlurRegion          `json:"blurRegions"`
+	PixelateRegions []PixelateRegion      `json:"pixelateRegions"`
+	Quality         int                   `json:"quality"`
+	Format          ImageFormat           `json:"format" default:"jpeg"`
+	Supersample     int                   `json:"supersample" default:"1"`     // render at 2x or 3x and downscale
+	Metadata        *Metadata             `json:"metadata"`                    // jpeg and png only, stripped when omitted
+	Output          *Output               `json:"output"`                      // /generate only, defaults to the response body
+	Trim            *Trim                 `json:"trim"`                        // crops to the content before thumbnail resizing
+	RotateDeg       float64               `json:"rotateDeg"`                   // clockwise, after thumbnail resizing
+	Filters         []Filter              `json:"filters"`                     // applied in order after thumbnail resizing
+	ColorProfile    ColorProfile          `json:"colorProfile" default:"srgb"` // ICC profile tagged on jpeg and png
+
+	// Rounds the corners of the final image, in output pixels, leaving them
+	// transparent. Alpha formats only.
+	CanvasCornerRadiusPx float64 `json:"canvasCornerRadiusPx"`
+
+	// Set from the thumbnail query parameter rather than the body
+	ThumbnailWidthPx int `json:"-"`
+	// Images rendered earlier in the same batch, for PlacedImage.BatchIndex
+	BatchResults []image.Image `json:"-"`
+	// Set by the server that prepares the request. A nil cache decodes every
+	// image file again and without script fonts AutoFont keeps text.Font.
+	ImageCache  *ImageCache       `json:"-"`
+	ScriptFonts map[string]string `json:"-"`
+}
+
+// GenerateImage is deterministic: the same request against the same fonts and
+// images encodes to the same bytes. Elements draw in request order, map fields
+// are walked in sorted key order and no encoder stamps times into the output.
+func GenerateImage(request ImgRequest) *bytes.Buffer {
+	return EncodeRequest(ComposeImage(request), request)
+}
+
+// ComposeImage renders the request and applies trimming, output resizing,
+// rotation, filters and canvas corner rounding
+func ComposeImage(request ImgRequest) image.Image {
+	img := RenderImage(request)
+
+	if request.Trim != nil {
+		img = TrimImage(img, *request.Trim)
+	}
+
+	if request.ThumbnailWidthPx > 0 {
+		// Height 0 keeps the aspect ratio
+		img = imaging.Resize(img, request.ThumbnailWidthPx, 0, imaging.Lanczos)
+	}
+
+	img = RotateImage(img, request)
+	img = ApplyFilters(img, request.Filters)
+
+	if request.CanvasCornerRadiusPx > 0 {
+		img = RoundCorners(img, request.CanvasCornerRadiusPx)
+	}
+
+	return img
+}
+
+func EncodeRequest(img image.Image, request ImgRequest) *bytes.Buffer {
+	buff := new(bytes.Buffer)
+	if err := EncodeImage(buff, img, request.Format, request.Quality); err != nil {
+		panic(err)
+	}
+
+	if request.DPI > 0 {
+		data, err := InjectDensity(buff.Bytes(), request.Format, request.DPI)
+		if err != nil {
+			panic(err)
+		}
+
+		buff = bytes.NewBuffer(data)
+	}
+
+	if request.Metadata != nil {
+		data, err := InjectMetadata(buff.Bytes(), request.Format, *request.Metadata)
+		if err != nil {
+			panic(err)
+		}
+
+		buff = bytes.NewBuffer(data)
+	}
+
+	data, err := EmbedColorProfile(buff.Bytes(), request.Format, request.ColorProfile)
+	if err != nil {
+		panic(err)
+	}
+
+	return bytes.NewBuffer(data)
+}
+
+func RenderImage(request ImgRequest) image.Image {
+	scale := max(1, request.Supersample)
+	newImg := gg.NewContext(request.WidthPx*scale, request.HeightPx*scale)
+	newImg.Scale(float64(scale), float64(scale))
+
+	if request.Transparent {
+		// gg contexts start fully transparent
+	} else if request.BgImgBase64 != "" || request.BgImgPath != "" {
+		img, err := LoadBackgroundImage(request)
+		if err != nil {
+			panic(err)
+		}
+
+		// Transparent parts of the image would otherwise encode as black in
+		// formats without alpha
+		if request.BgColor != (Color{}) {
+			newImg.SetColor(request.BgColor.ToRGBA())
+			newImg.Clear()
+		}
+
+		DrawBackgroundImage(newImg, img, request.BgImgFit, request.BgImgFocus)
+	} else if request.BgGradient != nil {
+		newImg.DrawRectangle(0, 0, float64(request.WidthPx), float64(request.HeightPx))
+		newImg.SetFillStyle(ScalePattern(request.BgGradient.Pattern(), scale))
+		newImg.Fill()
+	} else if request.BgCheckerboard != nil {
+		newImg.DrawRectangle(0, 0, float64(request.WidthPx), float64(request.HeightPx))
+		newImg.SetFillStyle(ScalePattern(request.BgCheckerboard.Pattern(), scale))
+		newImg.Fill()
+	} else if request.BgColor != (Color{}) {
+		newImg.SetColor(color.RGBA{request.BgColor.R, request.BgColor.G, request.BgColor.B, request.BgColor.A})
+		newImg.Clear()
+	} else {
+		panic("No background image or color provided")
+	}
+
+	for _, placed := range request.Images {
+		DrawBlended(newImg, placed.BlendMode, func(dc *gg.Context) {
+			DrawPlacedImage(dc, placed, request.BatchResults, request.ImageCache)
+		})
+	}
+
+	canvasWidth, canvasHeight := float64(request.WidthPx), float64(request.HeightPx)
+
+	for _, text := range request.SingleLineTexts {
+		text.Position = textPosition(AnchorText(text, canvasWidth, canvasHeight))
+
+		if len(text.Highlights) > 0 {
+			DrawHighlightedText(newImg, text)
+			continue
+		}
+
+		// The line box, measured only when it is needed as the pivot
+		var widthPx, ascent, descent float64
+		if text.RotationDeg != 0 {
+			widthPx, ascent, descent = MeasureText(text)
+		}
+
+		DrawText(newImg, text, func(dc *gg.Context, scale float64) {
+			x, y := text.Position.X.Value*scale, text.Position.Y.Value*scale
+
+			// Shadows and contrast checks run this more than once
+			dc.Push()
+			defer dc.Pop()
+			text.Rotate(dc, text.Position.X.Value, text.Position.Y.Value-ascent, widthPx, ascent+descent, scale)
+
+			if text.Markup || text.AutoFont {
+				DrawMarkupString(dc, text, x, y, scale, request.ScriptFonts)
+				return
+			}
+
+			label := text.Text
+			if text.TruncateWidthPx > 0 {
+				label = TruncateText(dc, label, text.TruncateWidthPx*scale)
+			}
+
+			if text.Wave != nil {
+				DrawWaveString(dc, label, x, y, *text.Wave, scale)
+				return
+			}
+
+			if len(text.TabStopsPx) > 0 {
+				stops := []float64{}
+				for _, stop := range text.TabStopsPx {
+					stops = append(stops, stop*scale)
+				}
+
+				DrawTabbedString(dc, label, x, y, stops)
+				return
+			}
+
+			dc.DrawString(label, x, y)
+		})
+	}
+
+	for _, rectangle := range request.Rectangles {
+		rectangle.Position = rectangle.Resolve(rectangle.Position, rectangle.WidthPx, rectangle.HeightPx, canvasWidth, canvasHeight)
+
+		// Rotated inside the callbacks, which may draw on fresh layers
+		rotate := func(dc *gg.Context) {
+			rectangle.Rotate(dc, rectangle.Position.X, rectangle.Position.Y, rectangle.WidthPx, rectangle.HeightPx, 1)
+		}
+
+		DrawShadow(newImg, rectangle.ShadowColor, rectangle.ShadowOffset, rectangle.ShadowBlurPx, func(dc *gg.Context) {
+			rotate(dc)
+			dc.DrawRectangle(rectangle.Position.X, rectangle.Position.Y, rectangle.WidthPx, rectangle.HeightPx)
+		})
+
+		DrawBlended(newImg, rectangle.BlendMode, func(dc *gg.Context) {
+			dc.Push()
+			defer dc.Pop()
+			rotate(dc)
+
+			box := RectangleBox{rectangle.Position, rectangle.WidthPx, rectangle.HeightPx}
+			drawRectangles(dc, []RectangleBox{box}, rectangle.Color, rectangle.FillGradient, rectangle.StrokeGradient, scale)
+		})
+	}
+
+	for _, group := range request.RectangleGroups {
+		DrawBlended(newImg, group.BlendMode, func(dc *gg.Context) {
+			drawRectangles(dc, group.Rectangles, group.Color, group.FillGradient, group.StrokeGradient, scale)
+		})
+	}
+
+	for _, circle := range request.Circles {
+		if circle.Anchor != "" {
+			topLeft := circle.Resolve(circle.Center, 2*circle.RadiusPx, 2*circle.RadiusPx, canvasWidth, canvasHeight)
+			circle.Center = Position{topLeft.X + circle.RadiusPx, topLeft.Y + circle.RadiusPx}
+		}
+
+		DrawBlended(newImg, circle.BlendMode, func(dc *gg.Context) {
+			DrawCircle(dc, circle)
+		})
+	}
+
+	for _, bar := range request.ProgressBars {
+		bar.Position = bar.Resolve(bar.Position, bar.WidthPx, bar.HeightPx, canvasWidth, canvasHeight)
+		DrawProgressBar(newImg, bar)
+	}
+
+	for _, rating := range request.StarRatings {
+		DrawStarRating(newImg, rating)
+	}
+
+	for _, icon := range request.Icons {
+		DrawIcon(newImg, icon)
+	}
+
+	for _, chart := range request.BarCharts {
+		DrawBarChart(newImg, chart)
+	}
+
+	for _, sparkline := range request.Sparklines {
+		DrawSparkline(newImg, sparkline)
+	}
+
+	for _, countdown := range request.Countdowns {
+		DrawCountdown(newImg, countdown)
+	}
+
+	for _, triangle := range request.Triangles {
+		DrawBlended(newImg, triangle.BlendMode, func(dc *gg.Context) {
+			DrawTriangle(dc, triangle)
+		})
+	}
+
+	for _, path := range request.Paths {
+		DrawBlended(newImg, path.BlendMode, func(dc *gg.Context) {
+			DrawPath(dc, path)
+		})
+	}
+
+	for _, curve := range request.Curves {
+		DrawCurve(newImg, curve)
+	}
+
+	for _, callout := range request.Callouts {
+		DrawCallout(newImg, callout)
+	}
+
+	for _, text := range request.MultiLineTexts {
+		DrawText(newImg, text.StyledText, func(dc *gg.Context, scale float64) {
+			lineSpacing := text.LineSpacingMultiple(dc.FontHeight() / scale)
+
+			if text.Markup || text.AutoFont {
+				DrawMarkupWrapped(dc, text, lineSpacing, scale, request.ScriptFonts)
+				return
+			}
+
+			label := LimitLines(dc, text.Text, text.WrapWidthPx.Value*scale, text.MaxLines)
+
+			if len(text.LineAligns) > 0 || text.VerticalDistribution != "" {
+				// Each wrapped line is drawn as its own box, which already fits
+				// the width so it isn't wrapped again
+				lines := dc.WordWrap(label, text.WrapWidthPx.Value*scale)
+				offsets := lineOffsets(len(lines), dc.FontHeight(), dc.FontHeight()*lineSpacing, text.BoxHeightPx*scale, text.VerticalDistribution)
+
+				for i, line := range lines {
+					y := text.Position.Y.Value*scale + offsets[i]
+					dc.DrawStringWrapped(line, text.Position.X.Value*scale, y, 0, 0, text.WrapWidthPx.Value*scale, lineSpacing, lineAlign(text.LineAligns, i, text.Align).gg())
+				}
+				return
+			}
+
+			dc.DrawStringWrapped(
+				label,
+				text.Position.X.Value*scale,
+				text.Position.Y.Value*scale,
+				0,                            // ax: horizontal alignment (0 = left)
+				0,                            // ay: vertical alignment (0 = top)
+				text.WrapWidthPx.Value*scale, // width before wrapping
+				lineSpacing,                  // line spacing as a multiple of the line height
+				text.Align.gg(),              // text alignment within the box
+			)
+		})
+	}
+
+	for _, richText := range request.RichTexts {
+		DrawRichText(newImg, richText)
+	}
+
+	for _, markdown := range request.Markdowns {
+		DrawMarkdown(newImg, markdown)
+	}
+
+	if request.Border != nil {
+		DrawBorder(newImg, *request.Border, float64(request.WidthPx), float64(request.HeightPx))
+	}
+
+	img := newImg.Image()
+	if scale > 1 {
+		img = imaging.Resize(img, request.WidthPx, request.HeightPx, imaging.Lanczos)
+	}
+
+	// Region effects work on the final pixels
+	img = ApplyBlurRegions(img, request.BlurRegions)
+	img = ApplyPixelateRegions(img, request.PixelateRegions)
+
+	return img
+}
+
+func BuildFontFaceList(fontDir string) []string {
+	// Glob all font files in the font folder
+	files, err := filepath.Glob(filepath.Join(fontDir, "**", "*.ttf"))
+	if err != nil {
+		panic(err)
+	}
+
+	fontFaces := []string{}
+
+	// Listed on stderr so stdout stays clean for -cli output
+	for _, file := range files {
+		fmt.Fprintln(os.Stderr, file)
+		fontFaces = append(fontFaces, file)
+	}
+
+	return fontFaces
+}
+
+func main() {
+	cli := flag.Bool("cli", false, "render a JSON request from stdin instead of serving HTTP")
+	outputPath := flag.String("o", "", "with -cli, write the image to this file instead of stdout")
+	flag.Parse()
+
+	config := LoadConfig()
+
+	if *cli {
+		if err := RunCLI(config, os.Stdin, os.Stdout, *outputPath); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	server := NewServer(config)
+
+	if err := http.ListenAndServe(fmt.Sprintf(":%d", config.Port), server.Handler()); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	_ "golang.org/x/image/bmp"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+	_ "golang.org/x/image/tiff"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+// testFonts is a font directory laid out like FONT_DIR, holding the Go fonts
+type testFonts struct {
+	dir     string
+	regular string
+	bold    string
+	italic  string
+	mono    string
+}
+
+func newTestFonts(t *testing.T) testFonts {
+	t.Helper()
+
+	dir := t.TempDir()
+	fonts := testFonts{
+		dir:     dir,
+		regular: filepath.Join(dir, "Go", "Go-Regular.ttf"),
+		bold:    filepath.Join(dir, "Go", "Go-Bold.ttf"),
+		italic:  filepath.Join(dir, "Go", "Go-Italic.ttf"),
+		mono:    filepath.Join(dir, "Go", "Go-Mono.ttf"),
+	}
+
+	files := map[string][]byte{fonts.regular: goregular.TTF, fonts.bold: gobold.TTF, fonts.italic: goitalic.TTF, fonts.mono: gomono.TTF}
+	for path, data := range files {
+		writeTestFile(t, path, data)
+	}
+
+	return fonts
+}
+
+// faces lists the fonts the way the font registry does
+func (fonts testFonts) faces() []string {
+	return []string{fonts.bold, fonts.italic, fonts.mono, fonts.regular}
+}
+
+// testdataFont copies a font from testdata into dir/<family>/ so it is picked
+// up by BuildFontFaceList as well
+func testdataFont(t *testing.T, dir, family, name string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, family, name)
+	writeTestFile(t, path, data)
+	return path
+}
+
+func writeTestFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeTestImage encodes img as a PNG file
+func writeTestImage(t *testing.T, path string, img image.Image) {
+	t.Helper()
+
+	buff := new(bytes.Buffer)
+	if err := EncodeImage(buff, img, PNG, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	writeTestFile(t, path, buff.Bytes())
+}
+
+// testAssets writes each image as <name>.png into an asset directory and
+// lists it like the server does, so requests can use the names
+func testAssets(t *testing.T, images map[string]image.Image) (string, map[string]string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, img := range images {
+		writeTestImage(t, filepath.Join(dir, name+".png"), img)
+	}
+
+	return dir, BuildAssetList(dir)
+}
+
+var testDefaults = Defaults{
+	BgColor:    &Color{255, 255, 255, 255},
+	TextColor:  Color{0, 0, 0, 255},
+	TextSizePx: 24,
+	Format:     PNG,
+}
+
+// renderRequest prepares request like the server and returns the composed
+// image before encoding
+func renderRequest(t *testing.T, request ImgRequest, fontFaces []string) image.Image {
+	t.Helper()
+	return renderWithAssets(t, request, fontFaces, nil)
+}
+
+func renderWithAssets(t *testing.T, request ImgRequest, fontFaces []string, assets map[string]string) image.Image {
+	t.Helper()
+
+	if err := PrepareRequest(&request, fontFaces, assets, testDefaults); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := tryCompose(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return img
+}
+
+func tryCompose(request ImgRequest) (img image.Image, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("%v", recovered)
+		}
+	}()
+
+	return ComposeImage(request), nil
+}
+
+// generateRequest prepares and encodes request like the server
+func generateRequest(t *testing.T, request ImgRequest, fontFaces []string) []byte {
+	t.Helper()
+
+	if err := PrepareRequest(&request, fontFaces, nil, testDefaults); err != nil {
+		t.Fatal(err)
+	}
+
+	buff, err := TryGenerateImage(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buff.Bytes()
+}
+
+func decodeImage(t *testing.T, data []byte) image.Image {
+	t.Helper()
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return img
+}
+
+func pixel(img image.Image, x, y int) color.NRGBA {
+	bounds := img.Bounds()
+	return color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+}
+
+// closeTo compares every channel of got and want within tolerance
+func closeTo(got, want color.NRGBA, tolerance int) bool {
+	near := func(a, b uint8) bool {
+		diff := int(a) - int(b)
+		return diff >= -tolerance && diff <= tolerance
+	}
+
+	return near(got.R, want.R) && near(got.G, want.G) && near(got.B, want.B) && near(got.A, want.A)
+}
+
+func nrgba(c Color) color.NRGBA {
+	return color.NRGBA{c.R, c.G, c.B, c.A}
+}
+
+var (
+	white = Color{255, 255, 255, 255}
+	black = Color{0, 0, 0, 255}
+	red   = Color{255, 0, 0, 255}
+	green = Color{0, 255, 0, 255}
+	blue  = Color{0, 0, 255, 255}
+)
+
+// solidImage is a width x height image of one color
+func solidImage(width, height int, c Color) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+
+	return img
+}
+
+// splitImage is left half left and right half right, top to bottom
+func splitImage(width, height int, left, right Color) *image.NRGBA {
+	img := solidImage(width, height, right)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width/2; x++ {
+			img.SetNRGBA(x, y, nrgba(left))
+		}
+	}
+
+	return img
+}
+
+// inkBounds is the box around every pixel of img that differs from the
+// top-left one, empty when there are none
+func inkBounds(img image.Image) image.Rectangle {
+	bounds := img.Bounds()
+	background := pixel(img, 0, 0)
+	ink := image.Rectangle{}
+
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			if !closeTo(pixel(img, x, y), background, 8) {
+				ink = ink.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+
+	return ink
+}
+
+const testAPIKey = "test-key"
+
+// newTestServer serves config, authenticating with testAPIKey unless other
+// keys are configured
+func newTestServer(t *testing.T, config Config) *httptest.Server {
+	t.Helper()
+
+	if config.APIKey == "" && len(config.APIKeys) == 0 {
+		config.APIKey = testAPIKey
+	}
+
+	if config.Defaults == (Defaults{}) {
+		config.Defaults = testDefaults
+	}
+
+	server := httptest.NewServer(NewServer(config).Handler())
+	t.Cleanup(server.Close)
+	return server
+}
+
+// postJSON sends body to url with the test API key
+func postJSON(t *testing.T, url string, body any, headers ...string) *http.Response {
+	t.Helper()
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request.Header.Set("Authorization", testAPIKey)
+	request.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(headers); i += 2 {
+		request.Header.Set(headers[i], headers[i+1])
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return response
+}
+
+// getURL sends a GET to url with the test API key
+func getURL(t *testing.T, url string) *http.Response {
+	t.Helper()
+
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request.Header.Set("Authorization", testAPIKey)
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return response
+}
+
+func readBody(t *testing.T, response *http.Response) []byte {
+	t.Helper()
+	defer response.Body.Close()
+
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/fogleman/gg"
+)
+
+// Markdown renders a small markdown subset inside a box: # to ### headings,
+// - and * bullet lists, paragraphs separated by blank lines and inline
+// **bold**, *italic* and _italic_. Bold and italic use the font family when
+// Font names one, or the RegisteredFamily of the font file.
+type Markdown struct {
+	Position           Position `json:"position"`
+	WrapWidthPx        float64  `json:"wrapWidthPx" binding:"required"`
+	Text               string   `json:"text"`
+	Font               string   `json:"font"`
+	SizePx             float64  `json:"sizePx"` // body size, headings scale from it
+	Color              Color    `json:"color"`
+	LineSpacing        float64  `json:"lineSpacing" default:"1.3"`
+	ParagraphSpacingPx float64  `json:"paragraphSpacingPx"` // defaults to half the body size
+	Shortcodes         bool     `json:"shortcodes"`         // expand :heart: style emoji shortcodes
+	Visibility
+
+	// Set when Font named one of the request's font families
+	family *FontFamily
+}
+
+type markdownBlock struct {
+	text    string
+	heading int // 1 to 3, 0 for body text
+	bullet  bool
+}
+
+// Heading sizes relative to the body size
+var headingScales = []float64{1, 2, 1.5, 1.25}
+
+func parseMarkdownBlocks(text string) []markdownBlock {
+	blocks := []markdownBlock{}
+	paragraph := []string{}
+
+	flush := func() {
+		if len(paragraph) > 0 {
+			blocks = append(blocks, markdownBlock{strings.Join(paragraph, " "), 0, false})
+			paragraph = nil
+		}
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+
+		switch {
+		case line == "":
+			flush()
+		case strings.HasPrefix(line, "# "), strings.HasPrefix(line, "## "), strings.HasPrefix(line, "### "):
+			flush()
+			level := strings.Index(line, " ")
+			blocks = append(blocks, markdownBlock{strings.TrimSpace(line[level:]), level, false})
+		case strings.HasPrefix(line, "- "), strings.HasPrefix(line, "* "):
+			flush()
+			blocks = append(blocks, markdownBlock{strings.TrimSpace(line[2:]), 0, true})
+		default:
+			paragraph = append(paragraph, line)
+		}
+	}
+	flush()
+
+	return blocks
+}
+
+// parseMarkdownInline splits text on **bold** and *italic* or _italic_
+// markers, with a backslash escaping the next character
+func parseMarkdownInline(text string) []markupRun {
+	runs := []markupRun{}
+	current := strings.Builder{}
+	bold, italic, escaped := false, false, false
+	runes := []rune(text)
+
+	flush := func() {
+		if current.Len() > 0 {
+			runs = append(runs, markupRun{current.String(), bold, italic})
+			current.Reset()
+		}
+	}
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '*' && i+1 < len(runes) && runes[i+1] == '*':
+			flush()
+			bold = !bold
+			i++
+		case r == '*' || r == '_':
+			flush()
+			italic = !italic
+		default:
+			current.WriteRune(r)
+		}
+	}
+	flush()
+
+	return runs
+}
+
+// DrawMarkdown returns the y below the last block in request coordinates
+func DrawMarkdown(dc *gg.Context, markdown Markdown) float64 {
+	// Lay out in device pixels for the same reason as DrawText
+	scale := deviceScale(dc)
+
+	paragraphSpacing := markdown.ParagraphSpacingPx
+	if paragraphSpacing == 0 {
+		paragraphSpacing = markdown.SizePx / 2
+	}
+
+	lineSpacing := markdown.LineSpacing
+	if lineSpacing == 0 {
+		lineSpacing = 1.3
+	}
+
+	bodyFace, err := LoadFontFace(markdown.Font, markdown.SizePx*scale, "")
+	if err != nil {
+		panic(err)
+	}
+
+	// Bullet text hangs past the bullet so wrapped lines stay aligned
+	bullet := "•"
+	indent := measureRun(bodyFace, bullet+"  ")
+
+	dc.Push()
+	defer dc.Pop()
+	dc.Identity()
+	dc.SetColor(markdown.Color.ToRGBA())
+
+	x, y := markdown.Position.X*scale, markdown.Position.Y*scale
+	width := markdown.WrapWidthPx * scale
+	blocks := parseMarkdownBlocks(markdown.Text)
+
+	for i, block := range blocks {
+		sizePx := markdown.SizePx * headingScales[block.heading] * scale
+
+		spans := []faceSpan{}
+		for _, run := range parseMarkdownInline(block.text) {
+			face, err := LoadFontFace(styledFontVariant(markdown.family, markdown.Font, run.bold || block.heading > 0, run.italic), sizePx, "")
+			if err != nil {
+				panic(err)
+			}
+
+			spans = append(spans, faceSpan{run.text, face, nil})
+		}
+
+		box := RichText{Position: Position{x, y}, WrapWidthPx: width, LineSpacing: lineSpacing}
+		if block.bullet {
+			dc.SetFontFace(bodyFace)
+			dc.DrawString(bullet, x, y+float64(bodyFace.Metrics().Ascent)/64)
+
+			box.Position.X += indent
+			box.WrapWidthPx -= indent
+		}
+
+		y = drawLines(dc, layoutSpans(spans, box.WrapWidthPx), box)
+
+		// Consecutive bullets form one list without paragraph spacing
+		if i+1 < len(blocks) && !(block.bullet && blocks[i+1].bullet) {
+			y += paragraphSpacing * scale
+		}
+	}
+
+	return y / scale
+}
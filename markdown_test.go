@@ -0,0 +1,102 @@
+package main
+
+import (
+	"image"
+	"slices"
+	"testing"
+)
+
+func TestParseMarkdownBlocks(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []markdownBlock
+	}{
+		{"heading and list", "# Title\n- one\n* two", []markdownBlock{{"Title", 1, false}, {"one", 0, true}, {"two", 0, true}}},
+		{"paragraph lines join", "first\nsecond\n\nthird", []markdownBlock{{"first second", 0, false}, {"third", 0, false}}},
+		{"heading levels", "## Two\n### Three", []markdownBlock{{"Two", 2, false}, {"Three", 3, false}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := parseMarkdownBlocks(test.text); !slices.Equal(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// inkIn is the box around the pixels of img within area that differ from the
+// top-left pixel
+func inkIn(img image.Image, area image.Rectangle) image.Rectangle {
+	background := pixel(img, 0, 0)
+	ink := image.Rectangle{}
+
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			if !closeTo(pixel(img, x, y), background, 8) {
+				ink = ink.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+
+	return ink
+}
+
+func TestMarkdownHeadingAndList(t *testing.T) {
+	fonts := newTestFonts(t)
+	request := ImgRequest{
+		WidthPx:   300,
+		HeightPx:  160,
+		BgColor:   white,
+		Markdowns: []Markdown{{Position: Position{10, 10}, WrapWidthPx: 280, Text: "# Title\n- one\n- two", Font: fonts.regular, SizePx: 20, Color: black}},
+	}
+	img := renderRequest(t, request, fonts.faces())
+
+	tops := lineTops(img)
+	if len(tops) != 3 {
+		t.Fatalf("%d lines of ink at %v, want 3", len(tops), tops)
+	}
+	tops = append(tops, 160)
+
+	lines := []image.Rectangle{}
+	for i := range 3 {
+		lines = append(lines, inkIn(img, image.Rect(0, tops[i], 300, tops[i+1])))
+	}
+
+	// The heading is twice the body size
+	if heading, item := lines[0].Dy(), lines[1].Dy(); heading < item*3/2 {
+		t.Errorf("heading is %dpx tall, the list item %dpx, want a larger heading", heading, item)
+	}
+
+	face, err := LoadFontFace(fonts.regular, 20, HintingNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer face.Close()
+	bulletEnd, textStart := 10+int(measureRun(face, "•"))+1, 10+int(measureRun(face, "•  "))
+
+	// Each item is a bullet, a blank indent and then its text
+	for i, line := range lines[1:] {
+		bullet := inkIn(img, image.Rect(0, line.Min.Y, bulletEnd, line.Max.Y))
+		indent := inkIn(img, image.Rect(bulletEnd, line.Min.Y, textStart-1, line.Max.Y))
+		text := inkIn(img, image.Rect(textStart-1, line.Min.Y, 300, line.Max.Y))
+
+		if bullet.Empty() || bullet.Min.X < 10 {
+			t.Errorf("item %d: bullet ink %v, want a glyph from x 10", i, bullet)
+		}
+
+		// The bullet is centered on the x-height, well below the line top
+		if bullet.Dy() >= line.Dy() {
+			t.Errorf("item %d: bullet is %dpx tall like its line", i, bullet.Dy())
+		}
+
+		if !indent.Empty() {
+			t.Errorf("item %d: ink %v in the indent", i, indent)
+		}
+
+		if text.Empty() {
+			t.Errorf("item %d has no text after the indent", i)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/fogleman/gg"
+	"golang.org/x/image/font"
+)
+
+type markupRun struct {
+	text   string
+	bold   bool
+	italic bool
+}
+
+// parseMarkup splits text on *bold* and _italic_ markers. A backslash escapes
+// the next character so literal markers can still be written.
+func parseMarkup(text string) []markupRun {
+	runs := []markupRun{}
+	current := strings.Builder{}
+	bold, italic, escaped := false, false, false
+
+	flush := func() {
+		if current.Len() > 0 {
+			runs = append(runs, markupRun{current.String(), bold, italic})
+			current.Reset()
+		}
+	}
+
+	for _, r := range text {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '*':
+			flush()
+			bold = !bold
+		case r == '_':
+			flush()
+			italic = !italic
+		default:
+			current.WriteRune(r)
+		}
+	}
+	flush()
+
+	return runs
+}
+
+var fontStyleSuffixes = []string{"-BoldItalic", "-Bold-Italic", "-Regular", "-Bold", "-Italic"}
+
+// RegisteredFamily groups path with the bold and italic fonts of fontFaces
+// named after it, following the Family-Bold.ttf naming used by Google Fonts.
+// Styles without a registered file use the regular one.
+func RegisteredFamily(path string, fontFaces []string) *FontFamily {
+	ext := filepath.Ext(path)
+	family := strings.TrimSuffix(path, ext)
+	for _, suffix := range fontStyleSuffixes {
+		family = strings.TrimSuffix(family, suffix)
+	}
+
+	registered := func(suffixes ...string) string {
+		for _, suffix := range suffixes {
+			if variant := family + suffix + ext; slices.Contains(fontFaces, variant) {
+				return variant
+			}
+		}
+
+		return ""
+	}
+
+	return &FontFamily{
+		Regular:    path,
+		Bold:       registered("-Bold"),
+		Italic:     registered("-Italic"),
+		BoldItalic: registered("-BoldItalic", "-Bold-Italic"),
+	}
+}
+
+// markupFaces loads a face per markup run, and per script within a run when
+// text.AutoFont is set. Without Markup the text is a single plain run.
+func markupFaces(text StyledText, scale float64, scriptFonts map[string]string) []faceSpan {
+	spans := []faceSpan{}
+
+	runs := []markupRun{{text.Text, false, false}}
+	if text.Markup {
+		runs = parseMarkup(text.Text)
+	}
+
+	for _, run := range runs {
+		scriptRuns := []scriptRun{{run.text, text.Font}}
+		if text.AutoFont {
+			scriptRuns = splitScripts(run.text, text.Font, scriptFonts)
+		}
+
+		for _, part := range scriptRuns {
+			// Script fallback fonts aren't part of the family
+			family := text.family
+			if part.font != text.Font {
+				family = nil
+			}
+
+			face, err := text.loadFace(styledFontVariant(family, part.font, run.bold, run.italic), text.SizePx.Value*scale)
+			if err != nil {
+				panic(err)
+			}
+
+			spans = append(spans, faceSpan{part.text, face, nil})
+		}
+	}
+
+	return spans
+}
+
+// DrawMarkupString draws a single line of markup with its baseline at x, y
+// using the color already set on dc. Like the DrawText callbacks, x and y are
+// device coordinates and the faces are loaded at scale.
+func DrawMarkupString(dc *gg.Context, text StyledText, x, y, scale float64, scriptFonts map[string]string) {
+	for _, span := range markupFaces(text, scale, scriptFonts) {
+		dc.SetFontFace(span.face)
+		dc.DrawString(span.text, x, y)
+		x += float64(font.MeasureString(span.face, span.text)) / 64
+	}
+}
+
+// DrawMarkupWrapped lays markup out like a RichText box using the color
+// already set on dc, in device coordinates at scale
+func DrawMarkupWrapped(dc *gg.Context, text MultiLineText, lineSpacing, scale float64, scriptFonts map[string]string) {
+	box := RichText{
+		Position:    Position{text.Position.X.Value * scale, text.Position.Y.Value * scale},
+		WrapWidthPx: text.WrapWidthPx.Value * scale,
+		LineSpacing: lineSpacing,
+		Align:       text.Align,
+		LineAligns:  text.LineAligns,
+	}
+
+	lines := layoutSpans(markupFaces(text.StyledText, scale, scriptFonts), box.WrapWidthPx)
+	drawLines(dc, limitTextLines(lines, box.WrapWidthPx, text.MaxLines), box)
+}
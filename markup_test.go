@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"reflect"
+	"testing"
+
+	"golang.org/x/image/font"
+)
+
+func TestParseMarkup(t *testing.T) {
+	tests := []struct {
+		text string
+		want []markupRun
+	}{
+		{"plain", []markupRun{{"plain", false, false}}},
+		{"Hello *world*", []markupRun{{"Hello ", false, false}, {"world", true, false}}},
+		{"_a *b*_", []markupRun{{"a ", false, true}, {"b", true, true}}},
+		{`2 \* 3`, []markupRun{{"2 * 3", false, false}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			if got := parseMarkup(test.text); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parseMarkup(%q) = %v, want %v", test.text, got, test.want)
+			}
+		})
+	}
+}
+
+// darkness sums how far each pixel of img between columns x0 and x1 is from
+// white
+func darkness(img image.Image, x0, x1 int) int {
+	total := 0
+	for y := 0; y < img.Bounds().Dy(); y++ {
+		for x := x0; x < x1; x++ {
+			total += 255 - int(pixel(img, x, y).G)
+		}
+	}
+
+	return total
+}
+
+func TestMarkupBold(t *testing.T) {
+	fonts := newTestFonts(t)
+
+	face, err := LoadFontFace(fonts.regular, 32, HintingNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	split := 10 + font.MeasureString(face, "Hello ").Ceil()
+	face.Close()
+
+	render := func(text string) image.Image {
+		request := ImgRequest{
+			WidthPx:         300,
+			HeightPx:        60,
+			BgColor:         white,
+			SingleLineTexts: []StyledText{{Text: text, Font: fonts.regular, SizePx: pixels(32), Color: black, Position: textPosition(Position{10, 40}), Markup: true}},
+		}
+
+		return renderRequest(t, request, fonts.faces())
+	}
+
+	plain, marked := render("Hello world"), render("Hello *world*")
+
+	// The first word stays regular, give or take antialiasing at the split
+	if got, want := darkness(marked, 0, split), darkness(plain, 0, split); got < want*99/100 || got > want*101/100 {
+		t.Errorf("first word coverage %d, want about the plain %d", got, want)
+	}
+
+	regular, bold := darkness(plain, split, 300), darkness(marked, split, 300)
+	if bold < regular*6/5 {
+		t.Errorf("bold word coverage %d, want well above the regular %d", bold, regular)
+	}
+}
+
+func TestMarkupBoldOnlyRegistered(t *testing.T) {
+	fonts := newTestFonts(t)
+
+	render := func(text string, fontFaces []string) []byte {
+		request := ImgRequest{
+			WidthPx:         300,
+			HeightPx:        60,
+			BgColor:         white,
+			Format:          PNG,
+			SingleLineTexts: []StyledText{{Text: text, Font: fonts.regular, SizePx: pixels(32), Color: black, Position: textPosition(Position{10, 40}), Markup: true}},
+		}
+
+		return generateRequest(t, request, fontFaces)
+	}
+
+	// Go-Bold.ttf is still on disk next to the regular file
+	unregistered := []string{fonts.regular, fonts.italic}
+	if !bytes.Equal(render("*Hello*", unregistered), render("Hello", unregistered)) {
+		t.Error("bold run used a font file that isn't registered")
+	}
+
+	if bytes.Equal(render("*Hello*", fonts.faces()), render("Hello", fonts.faces())) {
+		t.Error("bold run didn't use the registered bold file")
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"slices"
+)
+
+// Metadata is written into the encoded file. Encoders here never copy metadata
+// from source images, so omitting it yields a stripped file.
+type Metadata struct {
+	Copyright string `json:"copyright"`
+	Software  string `json:"software"`
+	Comment   string `json:"comment"`
+}
+
+func (metadata Metadata) entries() [][2]string {
+	entries := [][2]string{}
+
+	for _, entry := range [][2]string{
+		{"Copyright", metadata.Copyright},
+		{"Software", metadata.Software},
+		{"Comment", metadata.Comment},
+	} {
+		if entry[1] != "" {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+// InjectMetadata adds JPEG COM segments or PNG tEXt chunks for each field
+func InjectMetadata(data []byte, format ImageFormat, metadata Metadata) ([]byte, error) {
+	var err error
+
+	// Each insert lands at the same offset, so go backwards to keep the order
+	entries := metadata.entries()
+	slices.Reverse(entries)
+
+	for _, entry := range entries {
+		switch format {
+		case JPEG:
+			data, err = insertJPEGSegment(data, 0xfe, []byte(entry[0]+": "+entry[1]))
+		case PNG:
+			data, err = insertPNGChunk(data, "tEXt", []byte(entry[0]+"\x00"+entry[1]))
+		default:
+			err = fmt.Errorf("metadata is not supported for %s", format)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
+
+// InjectDensity stores dpi as a JFIF APP0 density for JPEG or a pHYs chunk for
+// PNG. Other formats are returned untouched. For JPEG it has to run before any
+// other APPn segment is added, since JFIF must come first.
+func InjectDensity(data []byte, format ImageFormat, dpi float64) ([]byte, error) {
+	switch format {
+	case JPEG:
+		// Version 1.02, units of dots per inch and no thumbnail
+		density := uint16(math.Round(math.Min(dpi, 0xffff)))
+		payload := []byte("JFIF\x00\x01\x02\x01")
+		payload = binary.BigEndian.AppendUint16(payload, density)
+		payload = binary.BigEndian.AppendUint16(payload, density)
+		payload = append(payload, 0, 0)
+
+		return insertJPEGSegment(data, 0xe0, payload)
+	case PNG:
+		// pHYs counts pixels per meter, unit 1
+		perMeter := uint32(math.Round(dpi / 0.0254))
+		payload := binary.BigEndian.AppendUint32(nil, perMeter)
+		payload = binary.BigEndian.AppendUint32(payload, perMeter)
+		payload = append(payload, 1)
+
+		return insertPNGChunk(data, "pHYs", payload)
+	}
+
+	return data, nil
+}
+
+// insertJPEGSegment adds a marker segment after SOI and any APPn segments, so
+// JFIF and EXIF headers stay first
+func insertJPEGSegment(data []byte, marker byte, payload []byte) ([]byte, error) {
+	if len(payload) > 0xffff-2 {
+		return nil, errors.New("jpeg segment too large")
+	}
+
+	if len(data) < 2 || data[0] != 0xff || data[1] != 0xd8 {
+		return nil, errors.New("not a jpeg")
+	}
+
+	offset := 2
+	for offset+4 <= len(data) && data[offset] == 0xff && data[offset+1] >= 0xe0 && data[offset+1] <= 0xef {
+		offset += 2 + int(binary.BigEndian.Uint16(data[offset+2:]))
+	}
+
+	segment := []byte{0xff, marker, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	segment = append(segment, payload...)
+
+	return splice(data, offset, segment), nil
+}
+
+// insertPNGChunk adds a chunk straight after IHDR, which keeps it ahead of
+// IDAT as required for ancillary chunks like pHYs and iCCP
+func insertPNGChunk(data []byte, chunkType string, payload []byte) ([]byte, error) {
+	const ihdrEnd = 8 + 4 + 4 + 13 + 4
+
+	if len(data) < ihdrEnd || !bytes.Equal(data[:8], []byte("\x89PNG\r\n\x1a\n")) {
+		return nil, errors.New("not a png")
+	}
+
+	chunk := make([]byte, 4, 12+len(payload))
+	binary.BigEndian.PutUint32(chunk, uint32(len(payload)))
+	chunk = append(chunk, chunkType...)
+	chunk = append(chunk, payload...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+
+	return splice(data, ihdrEnd, chunk), nil
+}
+
+func splice(data []byte, offset int, insert []byte) []byte {
+	result := make([]byte, 0, len(data)+len(insert))
+	result = append(result, data[:offset]...)
+	result = append(result, insert...)
+
+	return append(result, data[offset:]...)
+}
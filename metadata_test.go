@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"slices"
+	"testing"
+)
+
+// jpegComments reads the COM segments before the image data
+func jpegComments(t *testing.T, data []byte) []string {
+	t.Helper()
+
+	comments := []string{}
+	for offset := 2; offset+4 <= len(data) && data[offset] == 0xff; {
+		marker := data[offset+1]
+		if marker == 0xda {
+			break
+		}
+
+		length := int(binary.BigEndian.Uint16(data[offset+2:]))
+		if marker == 0xfe {
+			comments = append(comments, string(data[offset+4:offset+2+length]))
+		}
+		offset += 2 + length
+	}
+
+	return comments
+}
+
+// pngTexts reads the tEXt chunks as keyword: text
+func pngTexts(data []byte) []string {
+	texts := []string{}
+	for offset := 8; offset+12 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[offset:]))
+		if string(data[offset+4:offset+8]) == "tEXt" {
+			keyword, text, _ := bytes.Cut(data[offset+8:offset+8+length], []byte{0})
+			texts = append(texts, string(keyword)+": "+string(text))
+		}
+		offset += 12 + length
+	}
+
+	return texts
+}
+
+func TestInjectMetadata(t *testing.T) {
+	metadata := &Metadata{Copyright: "© 2024 Example", Software: "thumbgen"}
+	want := []string{"Copyright: © 2024 Example", "Software: thumbgen"}
+
+	tests := []struct {
+		format ImageFormat
+		read   func(data []byte) []string
+	}{
+		{JPEG, func(data []byte) []string { return jpegComments(t, data) }},
+		{PNG, pngTexts},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.format), func(t *testing.T) {
+			request := ImgRequest{WidthPx: 20, HeightPx: 20, BgColor: red, Format: test.format, Metadata: metadata}
+			data := generateRequest(t, request, nil)
+
+			if got := test.read(data); !slices.Equal(got, want) {
+				t.Errorf("metadata %q, want %q", got, want)
+			}
+
+			// Still a valid image
+			decodeImage(t, data)
+
+			request.Metadata = nil
+			if got := test.read(generateRequest(t, request, nil)); len(got) != 0 {
+				t.Errorf("metadata %q without any requested", got)
+			}
+		})
+	}
+}
+
+// jpegDensity reads the JFIF APP0 segment, which must come straight after SOI
+func jpegDensity(t *testing.T, data []byte) (uint8, uint16, uint16) {
+	t.Helper()
+
+	if len(data) < 20 || data[2] != 0xff || data[3] != 0xe0 || string(data[6:11]) != "JFIF\x00" {
+		t.Fatalf("no JFIF segment after SOI: % x", data[:min(len(data), 20)])
+	}
+
+	return data[13], binary.BigEndian.Uint16(data[14:]), binary.BigEndian.Uint16(data[16:])
+}
+
+// pngDensity reads the pHYs chunk, which must come before any IDAT
+func pngDensity(t *testing.T, data []byte) (uint8, uint32, uint32) {
+	t.Helper()
+
+	for offset := 8; offset+12 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[offset:]))
+		switch string(data[offset+4 : offset+8]) {
+		case "pHYs":
+			chunk := data[offset+8:]
+			return chunk[8], binary.BigEndian.Uint32(chunk), binary.BigEndian.Uint32(chunk[4:])
+		case "IDAT":
+			t.Fatal("no pHYs chunk before IDAT")
+		}
+		offset += 12 + length
+	}
+
+	t.Fatal("no pHYs chunk")
+	return 0, 0, 0
+}
+
+func TestInjectDensity(t *testing.T) {
+	// Metadata is added as well, since JFIF has to stay ahead of it
+	request := ImgRequest{WidthPx: 30, HeightPx: 20, BgColor: red, DPI: 300, Metadata: &Metadata{Comment: "print"}}
+
+	t.Run("jpeg", func(t *testing.T) {
+		request.Format = JPEG
+		data := generateRequest(t, request, nil)
+
+		// Unit 1 is dots per inch
+		if unit, x, y := jpegDensity(t, data); unit != 1 || x != 300 || y != 300 {
+			t.Errorf("density unit %d %dx%d, want 300 dpi", unit, x, y)
+		}
+
+		if got := decodeImage(t, data).Bounds().Size(); got != image.Pt(30, 20) {
+			t.Errorf("size %v, want 30x20", got)
+		}
+	})
+
+	t.Run("png", func(t *testing.T) {
+		request.Format = PNG
+		data := generateRequest(t, request, nil)
+
+		// Unit 1 is pixels per meter, 300 / 0.0254 rounded
+		if unit, x, y := pngDensity(t, data); unit != 1 || x != 11811 || y != 11811 {
+			t.Errorf("density unit %d %dx%d, want 11811 per meter", unit, x, y)
+		}
+
+		if got := decodeImage(t, data).Bounds().Size(); got != image.Pt(30, 20) {
+			t.Errorf("size %v, want 30x20", got)
+		}
+	})
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"golang.org/x/image/font"
+)
+
+type GlyphAdvance struct {
+	Rune      string  `json:"rune"`
+	AdvancePx float64 `json:"advancePx"`
+}
+
+type FontMetrics struct {
+	Font         string         `json:"font"`
+	SizePx       float64        `json:"sizePx"`
+	AscentPx     float64        `json:"ascentPx"`
+	DescentPx    float64        `json:"descentPx"`
+	LineHeightPx float64        `json:"lineHeightPx"`
+	XHeightPx    float64        `json:"xHeightPx"`
+	CapHeightPx  float64        `json:"capHeightPx"`
+	Advances     []GlyphAdvance `json:"advances,omitempty"`
+	// Width of text with kerning applied, for comparison with the advances sum
+	TextWidthPx float64 `json:"textWidthPx,omitempty"`
+}
+
+func MeasureFont(path string, sizePx float64, text string) (FontMetrics, error) {
+	face, err := LoadFontFace(path, sizePx, "")
+	if err != nil {
+		return FontMetrics{}, err
+	}
+	defer face.Close()
+
+	metrics := face.Metrics()
+	result := FontMetrics{
+		Font:         path,
+		SizePx:       sizePx,
+		AscentPx:     float64(metrics.Ascent) / 64,
+		DescentPx:    float64(metrics.Descent) / 64,
+		LineHeightPx: float64(metrics.Height) / 64,
+		XHeightPx:    float64(metrics.XHeight) / 64,
+		CapHeightPx:  float64(metrics.CapHeight) / 64,
+	}
+
+	for _, r := range text {
+		advance, _ := face.GlyphAdvance(r)
+		result.Advances = append(result.Advances, GlyphAdvance{string(r), float64(advance) / 64})
+	}
+
+	if text != "" {
+		result.TextWidthPx = float64(font.MeasureString(face, text)) / 64
+	}
+
+	return result, nil
+}
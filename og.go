@@ -0,0 +1,167 @@
+package main
+
+import (
+	"image/color"
+	"slices"
+
+	"github.com/fogleman/gg"
+)
+
+// OGRequest describes a 1200x630 social preview card. It is turned into an
+// ImgRequest, so it renders with the same elements as /generate.
+type OGRequest struct {
+	Title       string      `json:"title" binding:"required"`
+	Subtitle    string      `json:"subtitle"`
+	Logo        string      `json:"logo"` // asset name, drawn in the bottom left corner
+	BgColor     Color       `json:"bgColor" default:"#111827"`
+	AccentColor Color       `json:"accentColor" default:"#6366f1"` // bar along the left edge
+	Font        string      `json:"font" binding:"required"`
+	TitleFont   string      `json:"titleFont"` // usually a bold face, defaults to font
+	Format      ImageFormat `json:"format" default:"png"`
+	Quality     int         `json:"quality"`
+}
+
+const (
+	ogWidthPx   = 1200
+	ogHeightPx  = 630
+	ogPaddingPx = 80
+	ogAccentPx  = 16
+	ogLogoPx    = 64
+
+	// The title shrinks in steps until it wraps into ogTitleLines
+	ogTitleMaxSizePx = 80
+	ogTitleMinSizePx = 48
+	ogTitleLines     = 3
+	ogSubtitleSizePx = 36
+	ogSubtitleLines  = 2
+	ogLineSpacing    = 1.2
+)
+
+func (request *OGRequest) applyDefaults() {
+	if request.BgColor == (Color{}) {
+		request.BgColor = Color{17, 24, 39, 255}
+	}
+
+	if request.AccentColor == (Color{}) {
+		request.AccentColor = Color{99, 102, 241, 255}
+	}
+
+	if request.TitleFont == "" {
+		request.TitleFont = request.Font
+	}
+
+	if request.Format == "" {
+		request.Format = PNG
+	}
+}
+
+// Prepare applies the defaults and checks the fonts, which are measured
+// before the card request is validated as a whole
+func (request *OGRequest) Prepare(fontFaces []string) error {
+	request.applyDefaults()
+
+	if !slices.Contains(fontFaces, request.Font) || !slices.Contains(fontFaces, request.TitleFont) {
+		return errFontNotFound
+	}
+
+	return nil
+}
+
+// fitWrappedText fits text into widthPx at the largest size from maxSizePx down to
+// minSizePx, in 4px steps, that needs at most maxLines lines. It returns the
+// size and the height of the wrapped block, which is cut to maxLines at the
+// smallest size.
+func fitWrappedText(text StyledText, widthPx float64, maxLines int, maxSizePx, minSizePx float64) (float64, float64, error) {
+	dc := gg.NewContext(1, 1)
+
+	for size := maxSizePx; ; size -= 4 {
+		size = max(size, minSizePx)
+
+		face, err := text.loadFace(text.Font, size)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		dc.SetFontFace(face)
+		lines := len(dc.WordWrap(text.Text, widthPx))
+		lineHeight := dc.FontHeight()
+		face.Close()
+
+		if lines <= maxLines || size == minSizePx {
+			lines = min(lines, maxLines)
+			return size, float64(lines-1)*lineHeight*ogLineSpacing + lineHeight, nil
+		}
+	}
+}
+
+// ImgRequest lays the card out: the title from the top, the subtitle below it
+// and the logo at the bottom, all left aligned after the accent bar
+func (request OGRequest) ImgRequest() (ImgRequest, error) {
+	textColor := Color{255, 255, 255, 255}
+	if luminance := 0.299*float64(request.BgColor.R) + 0.587*float64(request.BgColor.G) + 0.114*float64(request.BgColor.B); luminance > 127.5 {
+		textColor = Color{0, 0, 0, 255}
+	}
+
+	x := float64(ogPaddingPx)
+	widthPx := float64(ogWidthPx - 2*ogPaddingPx)
+
+	title := StyledText{Text: request.Title, Font: request.TitleFont, Color: textColor}
+	titleSize, titleHeight, err := fitWrappedText(title, widthPx, ogTitleLines, ogTitleMaxSizePx, ogTitleMinSizePx)
+	if err != nil {
+		return ImgRequest{}, err
+	}
+
+	title.SizePx = pixels(titleSize)
+	title.Position = textPosition(Position{x, ogPaddingPx})
+
+	texts := []MultiLineText{{StyledText: title, WrapWidthPx: pixels(widthPx), LineSpacingPx: ogLineSpacing, MaxLines: ogTitleLines}}
+
+	if request.Subtitle != "" {
+		// Slightly faded so the title leads. Color is premultiplied like
+		// color.RGBA, so the channels are scaled down with the alpha.
+		faded := color.RGBAModel.Convert(color.NRGBA{textColor.R, textColor.G, textColor.B, 190}).(color.RGBA)
+		subtitleColor := Color{faded.R, faded.G, faded.B, faded.A}
+
+		subtitle := StyledText{
+			Text:     request.Subtitle,
+			Font:     request.Font,
+			SizePx:   pixels(ogSubtitleSizePx),
+			Color:    subtitleColor,
+			Position: textPosition(Position{x, ogPaddingPx + titleHeight + 32}),
+		}
+
+		texts = append(texts, MultiLineText{StyledText: subtitle, WrapWidthPx: pixels(widthPx), LineSpacingPx: ogLineSpacing, MaxLines: ogSubtitleLines})
+	}
+
+	accent := Path{
+		Subpaths: [][]PathCommand{{
+			{MoveOp, []Position{{0, 0}}},
+			{LineOp, []Position{{ogAccentPx, 0}}},
+			{LineOp, []Position{{ogAccentPx, ogHeightPx}}},
+			{LineOp, []Position{{0, ogHeightPx}}},
+			{CloseOp, nil},
+		}},
+		FillColor: request.AccentColor,
+	}
+
+	imgRequest := ImgRequest{
+		WidthPx:        ogWidthPx,
+		HeightPx:       ogHeightPx,
+		BgColor:        request.BgColor,
+		MultiLineTexts: texts,
+		Paths:          []Path{accent},
+		Format:         request.Format,
+		Quality:        request.Quality,
+	}
+
+	// A zero width keeps the aspect ratio of the logo
+	if request.Logo != "" {
+		imgRequest.Images = []PlacedImage{{
+			Path:     request.Logo,
+			Position: Position{x, ogHeightPx - ogPaddingPx - ogLogoPx},
+			HeightPx: ogLogoPx,
+		}}
+	}
+
+	return imgRequest, nil
+}
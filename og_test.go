@@ -0,0 +1,128 @@
+package main
+
+import (
+	"image"
+	"testing"
+)
+
+// brightest is the highest red channel within area
+func brightest(img image.Image, area image.Rectangle) uint8 {
+	value := uint8(0)
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			value = max(value, pixel(img, x, y).R)
+		}
+	}
+
+	return value
+}
+
+func TestOGCard(t *testing.T) {
+	fonts := newTestFonts(t)
+	assetDir, _ := testAssets(t, map[string]image.Image{"logo": solidImage(32, 32, red)})
+	server := newTestServer(t, Config{FontDir: fonts.dir, AssetDir: assetDir})
+
+	card := map[string]any{"title": "Rendering cards", "subtitle": "From a few fields", "logo": "logo", "font": fonts.regular, "titleFont": fonts.bold}
+	response := postJSON(t, server.URL+"/og", card)
+	data := readBody(t, response)
+	if response.StatusCode != 200 {
+		t.Fatalf("status %d: %s", response.StatusCode, data)
+	}
+
+	img := decodeImage(t, data)
+	if got := img.Bounds().Size(); got != image.Pt(ogWidthPx, ogHeightPx) {
+		t.Fatalf("card is %v, want 1200x630", got)
+	}
+
+	// Beside the accent bar and above the logo there are two texts: the
+	// title and, lower down, the subtitle. Coordinates below are relative to
+	// the text area, whose top-left pixel is background.
+	textArea := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}).SubImage(image.Rect(ogPaddingPx, 0, ogWidthPx, ogHeightPx-ogPaddingPx-ogLogoPx))
+	width, height := textArea.Bounds().Dx(), textArea.Bounds().Dy()
+
+	tops := lineTops(textArea)
+	if len(tops) != 2 {
+		t.Fatalf("text lines start at %v, want the title and the subtitle", tops)
+	}
+
+	// Capitals start below the top of the line box
+	if tops[0] < ogPaddingPx || tops[0] > ogPaddingPx+40 {
+		t.Errorf("title starts at y=%d, want it just below the %dpx padding", tops[0], ogPaddingPx)
+	}
+
+	title, subtitle := image.Rect(0, tops[0], width, tops[1]-1), image.Rect(0, tops[1], width, height)
+	titleInk, subtitleInk := inkIn(textArea, title), inkIn(textArea, subtitle)
+	if titleInk.Dy() <= subtitleInk.Dy() || titleInk.Dx() <= subtitleInk.Dx() {
+		t.Errorf("title ink %v and subtitle ink %v, want the title larger", titleInk, subtitleInk)
+	}
+
+	// White on the dark default background, with the subtitle faded to about
+	// 190/255 of the way from the background
+	if got := brightest(textArea, title); got < 250 {
+		t.Errorf("title peaks at %d, want white", got)
+	}
+
+	if got := brightest(textArea, subtitle); got < 185 || got > 203 {
+		t.Errorf("subtitle peaks at %d, want it faded to about 194", got)
+	}
+
+	if got := pixel(img, 4, 300); got != nrgba(Color{99, 102, 241, 255}) {
+		t.Errorf("accent bar is %v, want the default accent", got)
+	}
+
+	if got := pixel(img, ogPaddingPx+10, ogHeightPx-ogPaddingPx-10); got != nrgba(red) {
+		t.Errorf("logo corner is %v, want %v", got, red)
+	}
+
+	tests := []struct {
+		name string
+		card map[string]any
+	}{
+		{"no title", map[string]any{"font": fonts.regular}},
+		{"unknown font", map[string]any{"title": "Hi", "font": "missing.ttf"}},
+		{"unknown logo", map[string]any{"title": "Hi", "font": fonts.regular, "logo": "missing"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := postJSON(t, server.URL+"/og", test.card)
+			if body := readBody(t, response); response.StatusCode != 400 {
+				t.Errorf("status %d: %s, want 400", response.StatusCode, body)
+			}
+		})
+	}
+}
+
+func TestOGSubtitleColor(t *testing.T) {
+	fonts := newTestFonts(t)
+
+	// Colors are premultiplied, so no channel may exceed the alpha
+	tests := []struct {
+		name    string
+		bgColor Color
+		want    Color
+	}{
+		{"white on dark", Color{17, 24, 39, 255}, Color{190, 190, 190, 190}},
+		{"black on light", Color{240, 240, 240, 255}, Color{0, 0, 0, 190}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			card := OGRequest{Title: "Title", Subtitle: "Subtitle", Font: fonts.regular, BgColor: test.bgColor}
+			if err := card.Prepare(fonts.faces()); err != nil {
+				t.Fatal(err)
+			}
+
+			request, err := card.ImgRequest()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := request.MultiLineTexts[1].Color; got != test.want {
+				t.Errorf("subtitle color %v, want %v", got, test.want)
+			}
+		})
+	}
+}
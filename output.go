@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type OutputType string
+
+const (
+	ResponseOutput OutputType = "response"
+	FileOutput     OutputType = "file"
+)
+
+// Output sends the encoded image somewhere other than the response body. A
+// file output is written below the server's output directory and the
+// response carries the path instead.
+type Output struct {
+	Type OutputType `json:"type" default:"response"`
+	Path string     `json:"path"` // file only, relative to the output directory
+}
+
+func (output Output) Validate() error {
+	if output.Type != "" && output.Type != ResponseOutput && output.Type != FileOutput {
+		return errors.New("output type must be response or file")
+	}
+
+	if output.Type == FileOutput && output.Path == "" {
+		return errors.New("file output needs a path")
+	}
+
+	return nil
+}
+
+var errOutputOutsideDir = errors.New("output path must stay inside the output directory")
+
+// OutputPath resolves path below outputDir, rejecting absolute paths and any
+// that climb out of it
+func OutputPath(outputDir, path string) (string, error) {
+	if outputDir == "" {
+		return "", errors.New("file output is disabled on this server")
+	}
+
+	if filepath.IsAbs(path) {
+		return "", errOutputOutsideDir
+	}
+
+	resolved := filepath.Join(outputDir, path)
+	relative, err := filepath.Rel(outputDir, resolved)
+	if err != nil || relative == "." || relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+		return "", errOutputOutsideDir
+	}
+
+	return resolved, nil
+}
+
+// WriteOutputFile writes data to a path from OutputPath, creating its
+// directories one at a time. Every existing component below outputDir is
+// checked before anything is created, and symlinks are refused, so a link
+// inside the output directory can't send the write or its directories
+// elsewhere.
+func WriteOutputFile(outputDir, path string, data []byte) error {
+	relative, err := filepath.Rel(outputDir, path)
+	if err != nil {
+		return errOutputOutsideDir
+	}
+
+	current := outputDir
+	parts := strings.Split(relative, string(filepath.Separator))
+	for i, part := range parts {
+		current = filepath.Join(current, part)
+		last := i == len(parts)-1
+
+		info, err := os.Lstat(current)
+		switch {
+		case errors.Is(err, os.ErrNotExist) && last:
+		case errors.Is(err, os.ErrNotExist):
+			if err := os.Mkdir(current, 0o755); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case info.Mode()&os.ModeSymlink != 0:
+			return errOutputOutsideDir
+		case !last && !info.IsDir():
+			return fmt.Errorf("output path %q is not a directory", filepath.Join(parts[:i+1]...))
+		}
+	}
+
+	return os.WriteFile(path, data, 0o644)
+}
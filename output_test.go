@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileOutput(t *testing.T) {
+	outputDir, outside := t.TempDir(), t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(outputDir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	server := newTestServer(t, Config{OutputDir: outputDir})
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+	}{
+		{"file", "card.png", 200},
+		{"subdirectory", "cards/2024/card.png", 200},
+		{"parent directory", "../card.png", 400},
+		{"absolute", filepath.Join(outside, "card.png"), 400},
+		{"the directory itself", ".", 400},
+		{"through a symlink", "link/card.png", 400},
+		{"directories through a symlink", "link/a/b/card.png", 400},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body := map[string]any{"widthPx": 20, "heightPx": 10, "bgColor": red, "format": "png", "output": map[string]any{"type": "file", "path": test.path}}
+			response := postJSON(t, server.URL+"/generate", body)
+			data := readBody(t, response)
+			if response.StatusCode != test.status {
+				t.Fatalf("status %d, want %d: %s", response.StatusCode, test.status, data)
+			}
+
+			if test.status != 200 {
+				// Not even the directories of the path are created
+				if entries, _ := os.ReadDir(outside); len(entries) > 0 {
+					t.Errorf("%d entries created outside the output directory", len(entries))
+				}
+				return
+			}
+
+			var written struct {
+				Path  string `json:"path"`
+				Bytes int    `json:"bytes"`
+			}
+			if err := json.Unmarshal(data, &written); err != nil {
+				t.Fatal(err)
+			}
+
+			file, err := os.ReadFile(filepath.Join(outputDir, test.path))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if written.Path != test.path || written.Bytes != len(file) {
+				t.Errorf("response %+v, want path %q with %d bytes", written, test.path, len(file))
+			}
+
+			if got := pixel(decodeImage(t, file), 5, 5); got != nrgba(red) {
+				t.Errorf("written image is %v, want %v", got, red)
+			}
+		})
+	}
+}
+
+func TestOutputDirSymlink(t *testing.T) {
+	// The configured directory may itself be a link, only links below it are
+	// refused
+	target := t.TempDir()
+	outputDir := filepath.Join(t.TempDir(), "output")
+	if err := os.Symlink(target, outputDir); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := OutputPath(outputDir, "cards/card.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteOutputFile(outputDir, path, []byte("png")); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := os.ReadFile(filepath.Join(target, "cards", "card.png")); err != nil || string(data) != "png" {
+		t.Errorf("file holds %q, %v, want it written through the linked directory", data, err)
+	}
+
+	// A file where a directory is needed
+	path, _ = OutputPath(outputDir, "cards/card.png/x.png")
+	if err := WriteOutputFile(outputDir, path, nil); err == nil {
+		t.Error("a file was used as a directory")
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fogleman/gg"
+)
+
+type PathOp string
+
+const (
+	MoveOp  PathOp = "move"
+	LineOp  PathOp = "line"
+	QuadOp  PathOp = "quad"
+	CubicOp PathOp = "cubic"
+	CloseOp PathOp = "close"
+)
+
+// Number of points each op takes, control points first and the end point last
+var pathOpPoints = map[PathOp]int{MoveOp: 1, LineOp: 1, QuadOp: 2, CubicOp: 3, CloseOp: 0}
+
+type PathCommand struct {
+	Op     PathOp     `json:"op"`
+	Points []Position `json:"points"`
+}
+
+type FillRule string
+
+const (
+	NonZero FillRule = "nonzero"
+	EvenOdd FillRule = "evenodd"
+)
+
+// Path is a compound shape filled as one, so a subpath inside another cuts a
+// hole in it with the evenodd rule, or with nonzero when it runs the other way
+type Path struct {
+	Subpaths      [][]PathCommand `json:"subpaths"` // each starts with a move
+	FillRule      FillRule        `json:"fillRule" default:"nonzero"`
+	FillColor     Color           `json:"fillColor"`
+	StrokeColor   Color           `json:"strokeColor"`
+	StrokeWidthPx float64         `json:"strokeWidthPx"`
+	BlendMode     BlendMode       `json:"blendMode" default:"normal"`
+	Visibility
+}
+
+func (path Path) Validate() error {
+	if path.FillRule != "" && path.FillRule != NonZero && path.FillRule != EvenOdd {
+		return errors.New("fillRule must be nonzero or evenodd")
+	}
+
+	if len(path.Subpaths) == 0 {
+		return errors.New("paths need at least one subpath")
+	}
+
+	for _, subpath := range path.Subpaths {
+		if len(subpath) == 0 || subpath[0].Op != MoveOp {
+			return errors.New("every subpath must start with a move")
+		}
+
+		for _, command := range subpath {
+			count, ok := pathOpPoints[command.Op]
+			if !ok {
+				return fmt.Errorf("unsupported path op %q", command.Op)
+			}
+
+			if len(command.Points) != count {
+				return fmt.Errorf("path op %q takes %d point(s)", command.Op, count)
+			}
+		}
+	}
+
+	return nil
+}
+
+// points lists every point of the path, control points included
+func (path Path) points() []Position {
+	points := []Position{}
+	for _, subpath := range path.Subpaths {
+		for _, command := range subpath {
+			points = append(points, command.Points...)
+		}
+	}
+
+	return points
+}
+
+func DrawPath(dc *gg.Context, path Path) {
+	dc.Push()
+	defer dc.Pop()
+
+	dc.SetFillRule(gg.FillRuleWinding)
+	if path.FillRule == EvenOdd {
+		dc.SetFillRule(gg.FillRuleEvenOdd)
+	}
+
+	for _, subpath := range path.Subpaths {
+		for _, command := range subpath {
+			p := command.Points
+
+			switch command.Op {
+			case MoveOp:
+				dc.MoveTo(p[0].X, p[0].Y)
+			case LineOp:
+				dc.LineTo(p[0].X, p[0].Y)
+			case QuadOp:
+				dc.QuadraticTo(p[0].X, p[0].Y, p[1].X, p[1].Y)
+			case CubicOp:
+				dc.CubicTo(p[0].X, p[0].Y, p[1].X, p[1].Y, p[2].X, p[2].Y)
+			case CloseOp:
+				dc.ClosePath()
+			}
+		}
+	}
+
+	fillAndStroke(dc, path.FillColor, path.StrokeColor, path.StrokeWidthPx)
+}
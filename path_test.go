@@ -0,0 +1,83 @@
+package main
+
+import "testing"
+
+// square is a closed subpath around x0, y0 to x1, y1, clockwise unless reversed
+func square(x0, y0, x1, y1 float64, reversed bool) []PathCommand {
+	corners := []Position{{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}}
+	if reversed {
+		corners[1], corners[3] = corners[3], corners[1]
+	}
+
+	return []PathCommand{
+		{MoveOp, corners[:1]},
+		{LineOp, corners[1:2]},
+		{LineOp, corners[2:3]},
+		{LineOp, corners[3:4]},
+		{CloseOp, nil},
+	}
+}
+
+func TestPathHole(t *testing.T) {
+	tests := []struct {
+		name     string
+		fillRule FillRule
+		reversed bool
+		hole     Color
+	}{
+		{"evenodd", EvenOdd, false, white},
+		{"evenodd reversed", EvenOdd, true, white},
+		{"nonzero same direction", NonZero, false, red},
+		{"nonzero reversed", NonZero, true, white},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := Path{
+				Subpaths:  [][]PathCommand{square(20, 20, 100, 100, false), square(50, 50, 70, 70, test.reversed)},
+				FillRule:  test.fillRule,
+				FillColor: red,
+			}
+			img := renderRequest(t, ImgRequest{WidthPx: 120, HeightPx: 120, BgColor: white, Paths: []Path{path}}, nil)
+
+			if got := pixel(img, 60, 60); got != nrgba(test.hole) {
+				t.Errorf("hole is %v, want %v", got, test.hole)
+			}
+
+			// The ring around the hole is filled and the outside is not
+			for _, point := range [][2]int{{30, 30}, {60, 40}, {90, 90}, {45, 60}} {
+				if got := pixel(img, point[0], point[1]); got != nrgba(red) {
+					t.Errorf("ring pixel %v is %v, want %v", point, got, red)
+				}
+			}
+
+			if got := pixel(img, 10, 10); got != nrgba(white) {
+				t.Errorf("outside is %v, want %v", got, white)
+			}
+		})
+	}
+}
+
+func TestPathValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		path Path
+		ok   bool
+	}{
+		{"square", Path{Subpaths: [][]PathCommand{square(0, 0, 10, 10, false)}}, true},
+		{"curves", Path{Subpaths: [][]PathCommand{{{MoveOp, []Position{{0, 0}}}, {QuadOp, []Position{{5, 0}, {5, 5}}}, {CubicOp, []Position{{5, 10}, {0, 10}, {0, 5}}}}}}, true},
+		{"no subpaths", Path{}, false},
+		{"no move", Path{Subpaths: [][]PathCommand{{{LineOp, []Position{{1, 1}}}}}}, false},
+		{"point count", Path{Subpaths: [][]PathCommand{{{MoveOp, []Position{{0, 0}}}, {QuadOp, []Position{{5, 0}}}}}}, false},
+		{"unknown op", Path{Subpaths: [][]PathCommand{{{MoveOp, []Position{{0, 0}}}, {"arc", nil}}}}, false},
+		{"fill rule", Path{Subpaths: [][]PathCommand{square(0, 0, 10, 10, false)}, FillRule: "odd"}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.path.Validate(); (err == nil) != test.ok {
+				t.Errorf("Validate() = %v, want ok %v", err, test.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"strconv"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// PDFRequest renders every page like a batch, so pages can place earlier
+// ones through PlacedImage.BatchIndex. PageSize is a4, letter or image, which
+// sizes each page to its image at DPI.
+type PDFRequest struct {
+	Pages    []ImgRequest `json:"pages" binding:"required"`
+	PageSize string       `json:"pageSize" default:"a4"`
+	DPI      float64      `json:"dpi" default:"96"`
+}
+
+func (request PDFRequest) Validate() error {
+	if request.PageSize != "" && request.PageSize != "a4" && request.PageSize != "letter" && request.PageSize != "image" {
+		return errors.New("pageSize must be a4, letter or image")
+	}
+
+	if request.DPI < 0 {
+		return errors.New("dpi must be positive")
+	}
+
+	return nil
+}
+
+// GeneratePDF puts each rendered page on its own PDF page, scaled to fit and
+// centered. Pages keep their jpeg or png encoding; other formats become png.
+func GeneratePDF(request PDFRequest, prepare func(*ImgRequest) error) (*bytes.Buffer, *batchError) {
+	pageSize := request.PageSize
+	if pageSize == "" {
+		pageSize = "a4"
+	}
+
+	dpi := request.DPI
+	if dpi == 0 {
+		dpi = 96
+	}
+
+	pdf := fpdf.New("P", "pt", "A4", "")
+	pdf.SetMargins(0, 0, 0)
+	pdf.SetAutoPageBreak(false, 0)
+
+	rendered := []image.Image{}
+
+	for i, page := range request.Pages {
+		page.BatchResults = rendered
+
+		if err := prepare(&page); err != nil {
+			return nil, &batchError{400, i, err}
+		}
+
+		if page.Format != JPEG {
+			page.Format = PNG
+		}
+
+		img, data, err := tryComposeAndEncode(page)
+		if err != nil {
+			return nil, &batchError{500, i, fmt.Errorf("Failed to generate image: %w", err)}
+		}
+		rendered = append(rendered, img)
+
+		bounds := img.Bounds()
+		imageWidth, imageHeight := float64(bounds.Dx())*72/dpi, float64(bounds.Dy())*72/dpi
+
+		size := fpdf.SizeType{Wd: imageWidth, Ht: imageHeight}
+		switch pageSize {
+		case "a4":
+			size = pdf.GetPageSizeStr("A4")
+		case "letter":
+			size = pdf.GetPageSizeStr("Letter")
+		}
+
+		// Landscape images get landscape pages, which fpdf makes by swapping
+		// the sides of the portrait size
+		orientation := "P"
+		pageWidth, pageHeight := size.Wd, size.Ht
+		if imageWidth > imageHeight && pageSize != "image" {
+			orientation = "L"
+			pageWidth, pageHeight = size.Ht, size.Wd
+		}
+
+		pdf.AddPageFormat(orientation, size)
+
+		scale := min(pageWidth/imageWidth, pageHeight/imageHeight)
+		width, height := imageWidth*scale, imageHeight*scale
+
+		name := "page" + strconv.Itoa(i)
+		options := fpdf.ImageOptions{ImageType: string(page.Format)}
+		pdf.RegisterImageOptionsReader(name, options, bytes.NewReader(data))
+		pdf.ImageOptions(name, (pageWidth-width)/2, (pageHeight-height)/2, width, height, false, options, 0, "")
+
+		if err := pdf.Error(); err != nil {
+			return nil, &batchError{500, i, err}
+		}
+	}
+
+	buff := new(bytes.Buffer)
+	if err := pdf.Output(buff); err != nil {
+		return nil, &batchError{500, len(request.Pages) - 1, err}
+	}
+
+	return buff, nil
+}
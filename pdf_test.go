@@ -0,0 +1,54 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+)
+
+var (
+	pdfPage     = regexp.MustCompile(`/Type /Page\b`)
+	pdfMediaBox = regexp.MustCompile(`/MediaBox \[0 0 ([\d.]+) ([\d.]+)\]`)
+)
+
+func TestGeneratePDF(t *testing.T) {
+	server := newTestServer(t, Config{})
+	pages := []map[string]any{
+		{"widthPx": 192, "heightPx": 96, "bgColor": red},
+		{"widthPx": 96, "heightPx": 192, "bgColor": blue, "format": "jpeg"},
+	}
+
+	tests := []struct {
+		name       string
+		pageSize   string
+		mediaBoxes [][2]string
+	}{
+		{"a4", "a4", [][2]string{{"841.89", "595.28"}, {"595.28", "841.89"}}},
+		{"image", "image", [][2]string{{"144.00", "72.00"}, {"72.00", "144.00"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := postJSON(t, server.URL+"/generate/pdf", map[string]any{"pages": pages, "pageSize": test.pageSize})
+			body := readBody(t, response)
+			if response.StatusCode != 200 {
+				t.Fatalf("status %d: %s", response.StatusCode, body)
+			}
+
+			if got := response.Header.Get("Content-Type"); got != "application/pdf" {
+				t.Errorf("Content-Type is %q, want application/pdf", got)
+			}
+
+			if got := len(pdfPage.FindAll(body, -1)); got != 2 {
+				t.Fatalf("%d pages, want 2", got)
+			}
+
+			// The pages come first, then the default size of the page tree
+			boxes := pdfMediaBox.FindAllSubmatch(body, -1)
+			for i, match := range boxes[:min(len(boxes), 2)] {
+				if got := [2]string{string(match[1]), string(match[2])}; got != test.mediaBoxes[i] {
+					t.Errorf("page %d is %v, want %v", i, got, test.mediaBoxes[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import "fmt"
+
+type canvasPreset struct {
+	widthPx, heightPx int
+}
+
+var presets = map[string]canvasPreset{
+	"instagram-post":     {1080, 1080},
+	"instagram-portrait": {1080, 1350},
+	"story":              {1080, 1920},
+	"og-image":           {1200, 630},
+	"twitter-post":       {1600, 900},
+	"twitter-header":     {1500, 500},
+	"youtube-thumbnail":  {1280, 720},
+	"facebook-cover":     {820, 312},
+	"linkedin-banner":    {1584, 396},
+	"a4-150dpi":          {1240, 1754},
+	"a4-300dpi":          {2480, 3508},
+	"letter-300dpi":      {2550, 3300},
+}
+
+// ApplyPreset fills the dimensions the request leaves out from its preset
+func ApplyPreset(request *ImgRequest) error {
+	if request.Preset == "" {
+		return nil
+	}
+
+	preset, ok := presets[request.Preset]
+	if !ok {
+		return fmt.Errorf("unknown preset %q", request.Preset)
+	}
+
+	if request.WidthPx == 0 {
+		request.WidthPx = preset.widthPx
+	}
+
+	if request.HeightPx == 0 {
+		request.HeightPx = preset.heightPx
+	}
+
+	return nil
+}
+
+// outputProfile bundles encoding choices for a use. Images wider than
+// maxWidthPx are downscaled like a thumbnail request.
+type outputProfile struct {
+	format     ImageFormat
+	quality    int
+	maxWidthPx int
+}
+
+var profiles = map[string]outputProfile{
+	"web":       {JPEG, 82, 1920},
+	"print":     {TIFF, 0, 0},
+	"thumbnail": {JPEG, 70, 400},
+}
+
+// ApplyProfile fills the format, quality and thumbnail width the request
+// leaves out from its profile. It needs the canvas size to be known.
+func ApplyProfile(request *ImgRequest) error {
+	if request.Profile == "" {
+		return nil
+	}
+
+	profile, ok := profiles[request.Profile]
+	if !ok {
+		return fmt.Errorf("unknown profile %q", request.Profile)
+	}
+
+	if request.Format == "" {
+		request.Format = profile.format
+	}
+
+	if request.Quality == 0 {
+		request.Quality = profile.quality
+	}
+
+	if request.ThumbnailWidthPx == 0 && profile.maxWidthPx > 0 && request.WidthPx > profile.maxWidthPx {
+		request.ThumbnailWidthPx = profile.maxWidthPx
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"testing"
+)
+
+func TestPreset(t *testing.T) {
+	server := newTestServer(t, Config{})
+
+	tests := []struct {
+		name   string
+		body   map[string]any
+		status int
+		size   image.Point
+	}{
+		{"og-image", map[string]any{"preset": "og-image"}, 200, image.Pt(1200, 630)},
+		{"story", map[string]any{"preset": "story"}, 200, image.Pt(1080, 1920)},
+		{"explicit width overrides", map[string]any{"preset": "og-image", "widthPx": 600}, 200, image.Pt(600, 630)},
+		{"unknown", map[string]any{"preset": "billboard"}, 400, image.Point{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := postJSON(t, server.URL+"/generate", test.body)
+			body := readBody(t, response)
+			if response.StatusCode != test.status {
+				t.Fatalf("status %d, want %d: %s", response.StatusCode, test.status, body)
+			}
+
+			if test.status != 200 {
+				return
+			}
+
+			if got := decodeImage(t, body).Bounds().Size(); got != test.size {
+				t.Errorf("image is %v, want %v", got, test.size)
+			}
+		})
+	}
+}
+
+// jpegQuantDC is the DC entry of the first quantization table, which image/jpeg
+// scales from 16 by the quality
+func jpegQuantDC(t *testing.T, data []byte) int {
+	t.Helper()
+
+	for offset := 2; offset+5 <= len(data) && data[offset] == 0xff; {
+		if data[offset+1] == 0xdb {
+			return int(data[offset+5])
+		}
+		offset += 2 + int(data[offset+2])<<8 + int(data[offset+3])
+	}
+
+	t.Fatal("no quantization table")
+	return 0
+}
+
+func TestProfile(t *testing.T) {
+	server := newTestServer(t, Config{})
+	gradient := map[string]any{"start": map[string]any{"x": 0, "y": 0}, "end": map[string]any{"x": 1200, "y": 0}, "stops": []map[string]any{{"offset": 0, "color": red}, {"offset": 1, "color": blue}}}
+
+	tests := []struct {
+		name    string
+		query   string
+		body    map[string]any
+		format  string
+		size    image.Point
+		quantDC int // quality 70 makes 10, quality 82 makes 6 and 95 makes 2
+	}{
+		{"thumbnail", "", map[string]any{"profile": "thumbnail"}, "jpeg", image.Pt(400, 200), 10},
+		{"web leaves smaller images", "", map[string]any{"profile": "web"}, "jpeg", image.Pt(1200, 600), 6},
+		{"explicit quality", "", map[string]any{"profile": "thumbnail", "quality": 95}, "jpeg", image.Pt(400, 200), 2},
+		{"explicit width", "?thumbnail=600", map[string]any{"profile": "thumbnail"}, "jpeg", image.Pt(600, 300), 10},
+		{"explicit format", "", map[string]any{"profile": "thumbnail", "format": "png"}, "png", image.Pt(400, 200), 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body := map[string]any{"widthPx": 1200, "heightPx": 600, "bgGradient": gradient}
+			for key, value := range test.body {
+				body[key] = value
+			}
+
+			response := postJSON(t, server.URL+"/generate"+test.query, body)
+			data := readBody(t, response)
+			if response.StatusCode != 200 {
+				t.Fatalf("status %d: %s", response.StatusCode, data)
+			}
+
+			img, format, err := image.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if format != test.format {
+				t.Errorf("format %s, want %s", format, test.format)
+			}
+
+			if got := img.Bounds().Size(); got != test.size {
+				t.Errorf("image is %v, want %v", got, test.size)
+			}
+
+			if test.format == "jpeg" {
+				if got := jpegQuantDC(t, data); got != test.quantDC {
+					t.Errorf("quantization DC %d, want %d", got, test.quantDC)
+				}
+			}
+		})
+	}
+
+	response := postJSON(t, server.URL+"/generate", map[string]any{"widthPx": 20, "heightPx": 20, "profile": "poster"})
+	if body := readBody(t, response); response.StatusCode != 400 {
+		t.Errorf("unknown profile: status %d: %s", response.StatusCode, body)
+	}
+}
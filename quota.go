@@ -0,0 +1,146 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UsageStore counts requests per bucket. Buckets expire after ttl so a
+// shared store such as Redis cleans itself up.
+type UsageStore interface {
+	Increment(bucket string, ttl time.Duration) (int64, error)
+	Get(bucket string) (int64, error)
+}
+
+type usageCount struct {
+	count   int64
+	expires time.Time
+}
+
+// MemoryUsageStore keeps counts in the process. They are lost on restart and
+// not shared between instances. Expired buckets are dropped on writes, at most
+// once per pruneInterval.
+type MemoryUsageStore struct {
+	mutex  sync.Mutex
+	counts map[string]usageCount
+	pruned time.Time
+}
+
+const pruneInterval = time.Minute
+
+func NewMemoryUsageStore() *MemoryUsageStore {
+	return &MemoryUsageStore{counts: map[string]usageCount{}}
+}
+
+func (store *MemoryUsageStore) Increment(bucket string, ttl time.Duration) (int64, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	now := time.Now()
+	if now.Sub(store.pruned) >= pruneInterval {
+		store.prune(now)
+	}
+
+	entry, ok := store.counts[bucket]
+	if !ok || now.After(entry.expires) {
+		entry = usageCount{0, now.Add(ttl)}
+	}
+
+	entry.count++
+	store.counts[bucket] = entry
+
+	return entry.count, nil
+}
+
+// prune deletes the buckets that expired before now
+func (store *MemoryUsageStore) prune(now time.Time) {
+	for bucket, entry := range store.counts {
+		if now.After(entry.expires) {
+			delete(store.counts, bucket)
+		}
+	}
+
+	store.pruned = now
+}
+
+func (store *MemoryUsageStore) Get(bucket string) (int64, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	entry, ok := store.counts[bucket]
+	if !ok || time.Now().After(entry.expires) {
+		return 0, nil
+	}
+
+	return entry.count, nil
+}
+
+type quotaPeriod struct {
+	name   string
+	header string
+	format string // time layout naming the current bucket
+	ttl    time.Duration
+}
+
+var (
+	day   = quotaPeriod{"day", "X-Usage-Day", "2006-01-02", 48 * time.Hour}
+	month = quotaPeriod{"month", "X-Usage-Month", "2006-01", 32 * 24 * time.Hour}
+)
+
+// usageBucket names the counter of an API key for the current period. Keys
+// are hashed so stores never hold them in plain text.
+func usageBucket(apiKey string, period quotaPeriod) string {
+	hash := sha256.Sum256([]byte(apiKey))
+	return "usage:" + hex.EncodeToString(hash[:8]) + ":" + period.name + ":" + time.Now().UTC().Format(period.format)
+}
+
+// Usage reports the requests made with apiKey today and this month
+func Usage(store UsageStore, apiKey string) (gin.H, error) {
+	usage := gin.H{}
+
+	for _, period := range []quotaPeriod{day, month} {
+		count, err := store.Get(usageBucket(apiKey, period))
+		if err != nil {
+			return nil, err
+		}
+
+		usage[period.name] = count
+	}
+
+	return usage, nil
+}
+
+// Quota counts every request against the API key it was made with and
+// answers 429 once the daily or monthly limit is used up. A limit of 0 only
+// counts. The counts are returned in the X-Usage-Day and X-Usage-Month headers.
+func Quota(store UsageStore, dailyLimit, monthlyLimit int) gin.HandlerFunc {
+	limits := map[quotaPeriod]int{day: dailyLimit, month: monthlyLimit}
+
+	return func(c *gin.Context) {
+		apiKey := c.GetHeader("Authorization")
+
+		exceeded := ""
+		for _, period := range []quotaPeriod{day, month} {
+			count, err := store.Increment(usageBucket(apiKey, period), period.ttl)
+			if err != nil {
+				c.AbortWithStatusJSON(500, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.Header(period.header, strconv.FormatInt(count, 10))
+
+			if limit := limits[period]; limit > 0 && count > int64(limit) && exceeded == "" {
+				exceeded = period.name
+			}
+		}
+
+		if exceeded != "" {
+			c.AbortWithStatusJSON(429, gin.H{"error": "Quota exceeded for this " + exceeded})
+		}
+	}
+}
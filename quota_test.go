@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestQuotaExceeded(t *testing.T) {
+	body := map[string]any{"widthPx": 10, "heightPx": 10}
+
+	tests := []struct {
+		name   string
+		config Config
+		header string
+	}{
+		{"daily", Config{DailyQuota: 2}, "X-Usage-Day"},
+		{"monthly", Config{MonthlyQuota: 2}, "X-Usage-Month"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newTestServer(t, test.config)
+
+			for i, want := range []int{200, 200, 429, 429} {
+				response := postJSON(t, server.URL+"/generate", body)
+				readBody(t, response)
+
+				if response.StatusCode != want {
+					t.Errorf("request %d: status %d, want %d", i+1, response.StatusCode, want)
+				}
+
+				if got := response.Header.Get(test.header); got != strconv.Itoa(i+1) {
+					t.Errorf("request %d: %s is %q, want %d", i+1, test.header, got, i+1)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryUsageStorePrunes(t *testing.T) {
+	store := NewMemoryUsageStore()
+
+	if _, err := store.Increment("expired", -time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first write pruned already, so pretend the interval passed
+	store.pruned = time.Time{}
+	if _, err := store.Increment("live", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := store.counts["expired"]; ok {
+		t.Error("expired bucket was kept")
+	}
+
+	if count, _ := store.Get("live"); count != 1 {
+		t.Errorf("live bucket counts %d, want 1", count)
+	}
+}
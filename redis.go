@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore keeps usage counts and cached responses in Redis so every
+// instance behind a load balancer shares them
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore connects to a redis:// URL
+func NewRedisStore(url string) (*RedisStore, error) {
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+
+	client := redis.NewClient(options)
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		return nil, err
+	}
+
+	return &RedisStore{client}, nil
+}
+
+// incrementScript counts and sets the expiry in one step, so a bucket is
+// never left without a TTL. Buckets that somehow lost theirs get it back.
+var incrementScript = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+if redis.call("PTTL", KEYS[1]) < 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return count
+`)
+
+func (store *RedisStore) Increment(bucket string, ttl time.Duration) (int64, error) {
+	return incrementScript.Run(context.Background(), store.client, []string{bucket}, ttl.Milliseconds()).Int64()
+}
+
+func (store *RedisStore) Get(bucket string) (int64, error) {
+	count, err := store.client.Get(context.Background(), bucket).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+
+	return count, err
+}
+
+// RedisResponseCache adapts RedisStore to ResponseCache
+type RedisResponseCache struct {
+	store *RedisStore
+}
+
+func (cache RedisResponseCache) Get(key string) ([]byte, bool, error) {
+	data, err := cache.store.client.Get(context.Background(), key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+
+	return data, err == nil, err
+}
+
+func (cache RedisResponseCache) Set(key string, data []byte, ttl time.Duration) error {
+	return cache.store.client.Set(context.Background(), key, data, ttl).Err()
+}
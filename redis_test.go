@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestRedisSharesResponses(t *testing.T) {
+	redis := miniredis.RunT(t)
+	config := Config{RedisURL: "redis://" + redis.Addr(), ResponseTTL: Duration(time.Minute)}
+	first, second := newTestServer(t, config), newTestServer(t, config)
+
+	body := map[string]any{"widthPx": 20, "heightPx": 20, "bgColor": red}
+
+	tests := []struct {
+		name  string
+		url   string
+		cache string
+	}{
+		{"first instance renders", first.URL, "MISS"},
+		{"second instance reads it", second.URL, "HIT"},
+		{"first instance reads it", first.URL, "HIT"},
+	}
+
+	var image []byte
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := postJSON(t, test.url+"/generate", body)
+			data := readBody(t, response)
+			if response.StatusCode != 200 {
+				t.Fatalf("status %d: %s", response.StatusCode, data)
+			}
+
+			if got := response.Header.Get("X-Cache"); got != test.cache {
+				t.Errorf("X-Cache is %q, want %q", got, test.cache)
+			}
+
+			if image == nil {
+				image = data
+			} else if !bytes.Equal(data, image) {
+				t.Error("cached image differs from the rendered one")
+			}
+		})
+	}
+
+	// Usage is counted in Redis too, across both instances
+	if got := postJSON(t, second.URL+"/generate", body).Header.Get("X-Usage-Day"); got != "4" {
+		t.Errorf("X-Usage-Day is %q, want 4", got)
+	}
+
+	key := usageBucket(testAPIKey, day)
+	if ttl := redis.TTL(key); ttl <= 0 || ttl > day.ttl {
+		t.Errorf("usage bucket expires in %v, want at most %v", ttl, day.ttl)
+	}
+}
+
+func TestResponseCacheKeyFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "background.png")
+	writeTestImage(t, path, solidImage(2, 2, red))
+
+	request := ImgRequest{WidthPx: 20, HeightPx: 20, BgImgPath: path}
+	before, err := ResponseCacheKey(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Same path, new content of another size
+	writeTestImage(t, path, solidImage(4, 4, blue))
+	after, err := ResponseCacheKey(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if before == after {
+		t.Error("replacing the background image kept the cache key")
+	}
+}
+
+func TestRedisIncrementSetsTTL(t *testing.T) {
+	redis := miniredis.RunT(t)
+	store, err := NewRedisStore("redis://" + redis.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A bucket left without an expiry, e.g. by a crash between two calls
+	redis.Set("stale", "5")
+
+	tests := []struct {
+		name   string
+		bucket string
+		want   int64
+	}{
+		{"new bucket", "fresh", 1},
+		{"existing bucket", "fresh", 2},
+		{"bucket without expiry", "stale", 6},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			count, err := store.Increment(test.bucket, time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != test.want {
+				t.Errorf("count is %d, want %d", count, test.want)
+			}
+			if ttl := redis.TTL(test.bucket); ttl <= 0 || ttl > time.Hour {
+				t.Errorf("bucket expires in %v, want at most an hour", ttl)
+			}
+		})
+	}
+}
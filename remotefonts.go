@@ -0,0 +1,263 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/image/font/sfnt"
+)
+
+// RemoteFonts downloads fonts named by URL in a request's font fields, when
+// the URL starts with one of the allowed prefixes, and keeps them in a
+// directory for the life of the process. Fonts are then used by their local
+// path like any other.
+type RemoteFonts struct {
+	prefixes []string
+	maxBytes int64
+	dir      string
+	client   *http.Client
+
+	// Guards paths and fetching only, downloads run unlocked
+	mutex    sync.Mutex
+	paths    map[string]string
+	fetching map[string]*fontFetch
+}
+
+// fontFetch is a download in progress. Requests for a URL that is already
+// being fetched wait on done and share the result.
+type fontFetch struct {
+	done chan struct{}
+	path string
+	err  error
+}
+
+func NewRemoteFonts(prefixes []string, maxBytes int64) (*RemoteFonts, error) {
+	for _, prefix := range prefixes {
+		parsed, err := url.Parse(prefix)
+		if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || !strings.HasSuffix(parsed.Path, "/") {
+			return nil, fmt.Errorf("font URL prefix %q must be an http(s) URL ending in a slash", prefix)
+		}
+	}
+
+	dir, err := os.MkdirTemp("", "thumbgen-fonts")
+	if err != nil {
+		return nil, err
+	}
+
+	fonts := &RemoteFonts{
+		prefixes: prefixes,
+		maxBytes: maxBytes,
+		dir:      dir,
+		paths:    map[string]string{},
+		fetching: map[string]*fontFetch{},
+	}
+
+	// Every hop has to pass the allow list, or an allowed host could redirect
+	// anywhere
+	fonts.client = &http.Client{
+		Timeout: 10 * time.Second,
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+
+			if !fonts.allowed(request.URL.String()) {
+				return fmt.Errorf("redirect to %q is not allowed", request.URL)
+			}
+
+			return nil
+		},
+	}
+
+	return fonts, nil
+}
+
+func isFontURL(font string) bool {
+	return strings.HasPrefix(font, "https://") || strings.HasPrefix(font, "http://")
+}
+
+// Resolve replaces every font URL in request with its downloaded file and
+// returns those files so they can be accepted as font faces
+func (fonts *RemoteFonts) Resolve(request *ImgRequest) ([]string, error) {
+	paths := []string{}
+
+	resolve := func(font *string) error {
+		if !isFontURL(*font) {
+			return nil
+		}
+
+		path, err := fonts.fetch(*font)
+		if err != nil {
+			return err
+		}
+
+		*font = path
+		paths = append(paths, path)
+		return nil
+	}
+
+	for _, font := range requestFontFields(request) {
+		if err := resolve(font); err != nil {
+			return nil, err
+		}
+	}
+
+	for name, family := range request.FontFamilies {
+		for _, font := range []*string{&family.Regular, &family.Bold, &family.Italic, &family.BoldItalic} {
+			if err := resolve(font); err != nil {
+				return nil, err
+			}
+		}
+
+		request.FontFamilies[name] = family
+	}
+
+	return paths, nil
+}
+
+func (fonts *RemoteFonts) allowed(fontURL string) bool {
+	parsed, err := url.Parse(fontURL)
+	if err != nil || parsed.User != nil || strings.Contains(parsed.Path, "..") {
+		return false
+	}
+
+	for _, prefix := range fonts.prefixes {
+		if strings.HasPrefix(fontURL, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (fonts *RemoteFonts) fetch(fontURL string) (string, error) {
+	if !fonts.allowed(fontURL) {
+		return "", fmt.Errorf("font URL %q is not allowed", fontURL)
+	}
+
+	fonts.mutex.Lock()
+	if path, ok := fonts.paths[fontURL]; ok {
+		fonts.mutex.Unlock()
+		return path, nil
+	}
+
+	if fetch, ok := fonts.fetching[fontURL]; ok {
+		fonts.mutex.Unlock()
+		<-fetch.done
+		return fetch.path, fetch.err
+	}
+
+	fetch := &fontFetch{done: make(chan struct{})}
+	fonts.fetching[fontURL] = fetch
+	fonts.mutex.Unlock()
+
+	fetch.path, fetch.err = fonts.download(fontURL)
+
+	// Failures aren't kept, so the next request tries again
+	fonts.mutex.Lock()
+	if fetch.err == nil {
+		fonts.paths[fontURL] = fetch.path
+	}
+	delete(fonts.fetching, fontURL)
+	fonts.mutex.Unlock()
+	close(fetch.done)
+
+	return fetch.path, fetch.err
+}
+
+// download saves the font at fontURL into the directory and returns its path
+func (fonts *RemoteFonts) download(fontURL string) (string, error) {
+	response, err := fonts.client.Get(fontURL)
+	if err != nil {
+		return "", fmt.Errorf("fetching font %q: %w", fontURL, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetching font %q: %s", fontURL, response.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(response.Body, fonts.maxBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("fetching font %q: %w", fontURL, err)
+	}
+
+	if int64(len(data)) > fonts.maxBytes {
+		return "", fmt.Errorf("font %q is larger than %d bytes", fontURL, fonts.maxBytes)
+	}
+
+	// Only keep files that really are TrueType or OpenType fonts
+	if _, err := sfnt.Parse(data); err != nil {
+		return "", fmt.Errorf("font %q: %w", fontURL, err)
+	}
+
+	hash := sha256.Sum256([]byte(fontURL))
+	path := filepath.Join(fonts.dir, hex.EncodeToString(hash[:16])+fontExtension(fontURL))
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// fontExtension keeps .otf files recognizable, everything else is saved as .ttf
+func fontExtension(fontURL string) string {
+	if parsed, err := url.Parse(fontURL); err == nil && strings.EqualFold(filepath.Ext(parsed.Path), ".otf") {
+		return ".otf"
+	}
+
+	return ".ttf"
+}
+
+// requestFontFields points at the font of every element that draws text
+func requestFontFields(request *ImgRequest) []*string {
+	fields := []*string{}
+
+	for i := range request.SingleLineTexts {
+		fields = append(fields, &request.SingleLineTexts[i].Font)
+	}
+
+	for i := range request.MultiLineTexts {
+		fields = append(fields, &request.MultiLineTexts[i].Font)
+	}
+
+	for i := range request.TextStacks {
+		for j := range request.TextStacks[i].Items {
+			fields = append(fields, &request.TextStacks[i].Items[j].Font)
+		}
+	}
+
+	for i := range request.RichTexts {
+		for j := range request.RichTexts[i].Spans {
+			fields = append(fields, &request.RichTexts[i].Spans[j].Font)
+		}
+	}
+
+	for i := range request.Markdowns {
+		fields = append(fields, &request.Markdowns[i].Font)
+	}
+
+	for i := range request.BarCharts {
+		fields = append(fields, &request.BarCharts[i].Font)
+	}
+
+	for i := range request.Callouts {
+		fields = append(fields, &request.Callouts[i].Font)
+	}
+
+	for i := range request.Countdowns {
+		fields = append(fields, &request.Countdowns[i].Font)
+	}
+
+	return fields
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// fontHost serves the Go font under /fonts/ and counts the downloads
+func fontHost(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	downloads := &atomic.Int32{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fonts/Go.ttf", func(w http.ResponseWriter, r *http.Request) {
+		downloads.Add(1)
+		w.Write(goregular.TTF)
+	})
+	// Slow enough for concurrent fetches to overlap
+	mux.HandleFunc("/fonts/slow.ttf", func(w http.ResponseWriter, r *http.Request) {
+		downloads.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		w.Write(goregular.TTF)
+	})
+	mux.HandleFunc("/fonts/notes.ttf", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not a font"))
+	})
+	mux.HandleFunc("/fonts/moved.ttf", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/fonts/Go.ttf", http.StatusFound)
+	})
+	mux.HandleFunc("/fonts/away.ttf", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/private/Go.ttf", http.StatusFound)
+	})
+	mux.HandleFunc("/private/Go.ttf", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(goregular.TTF)
+	})
+
+	host := httptest.NewServer(mux)
+	t.Cleanup(host.Close)
+	return host, downloads
+}
+
+func fontURLBody(font string) map[string]any {
+	return map[string]any{
+		"widthPx":         120,
+		"heightPx":        40,
+		"bgColor":         white,
+		"singleLineTexts": []map[string]any{{"text": "Remote", "font": font, "sizePx": 24, "color": black, "position": map[string]any{"x": 5, "y": 30}}},
+	}
+}
+
+func TestRemoteFonts(t *testing.T) {
+	host, _ := fontHost(t)
+	server := newTestServer(t, Config{FontURLPrefixes: []string{host.URL + "/fonts/"}, MaxFontBytes: 10 << 20})
+
+	tests := []struct {
+		name   string
+		font   string
+		status int
+		error  string
+	}{
+		{"allowed", host.URL + "/fonts/Go.ttf", 200, ""},
+		{"redirect within the prefix", host.URL + "/fonts/moved.ttf", 200, ""},
+		{"redirect out of the prefix", host.URL + "/fonts/away.ttf", 400, "not allowed"},
+		{"outside the prefix", host.URL + "/private/Go.ttf", 400, "not allowed"},
+		{"parent path", host.URL + "/fonts/../private/Go.ttf", 400, "not allowed"},
+		{"not a font", host.URL + "/fonts/notes.ttf", 400, "font"},
+		{"missing", host.URL + "/fonts/missing.ttf", 400, "404"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := postJSON(t, server.URL+"/generate", fontURLBody(test.font))
+			body := readBody(t, response)
+			if response.StatusCode != test.status {
+				t.Fatalf("status %d, want %d: %s", response.StatusCode, test.status, body)
+			}
+
+			if test.status != 200 {
+				if !strings.Contains(string(body), test.error) {
+					t.Errorf("error %s, want it to mention %q", body, test.error)
+				}
+				return
+			}
+
+			if ink := inkIn(decodeImage(t, body), image.Rect(0, 0, 120, 40)); ink.Dx() < 40 {
+				t.Errorf("text ink %v, want the word drawn", ink)
+			}
+		})
+	}
+}
+
+func TestRemoteFontsLimits(t *testing.T) {
+	host, downloads := fontHost(t)
+	font := host.URL + "/fonts/Go.ttf"
+
+	// Larger fonts are refused
+	small := newTestServer(t, Config{FontURLPrefixes: []string{host.URL + "/fonts/"}, MaxFontBytes: len(goregular.TTF) - 1})
+	response := postJSON(t, small.URL+"/generate", fontURLBody(font))
+	if body := readBody(t, response); response.StatusCode != 400 || !strings.Contains(string(body), "larger than") {
+		t.Errorf("status %d: %s, want the font refused as too large", response.StatusCode, body)
+	}
+
+	// Without prefixes font URLs are just unknown fonts
+	response = postJSON(t, newTestServer(t, Config{}).URL+"/generate", fontURLBody(font))
+	if body := readBody(t, response); response.StatusCode != 400 {
+		t.Errorf("status %d: %s, want 400 with fonts by URL off", response.StatusCode, body)
+	}
+
+	// Concurrent requests share one download
+	fonts, err := NewRemoteFonts([]string{host.URL + "/fonts/"}, 10<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	downloads.Store(0)
+	font = host.URL + "/fonts/slow.ttf"
+	paths := make([]string, 8)
+	var wait sync.WaitGroup
+	for i := range paths {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+
+			path, err := fonts.fetch(font)
+			if err != nil {
+				t.Error(err)
+			}
+			paths[i] = path
+		}()
+	}
+	wait.Wait()
+
+	if got := downloads.Load(); got != 1 {
+		t.Errorf("font downloaded %d times, want once", got)
+	}
+
+	for _, path := range paths {
+		if path != paths[0] {
+			t.Errorf("paths %v, want all the same", paths)
+			break
+		}
+	}
+
+	if _, err := NewRemoteFonts([]string{"ftp://fonts.example/"}, 10<<20); err == nil {
+		t.Error("ftp prefix was accepted")
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ResponseCache stores encoded images of earlier requests. Identical requests
+// are answered from it until the entry expires.
+type ResponseCache interface {
+	Get(key string) ([]byte, bool, error)
+	Set(key string, data []byte, ttl time.Duration) error
+}
+
+// ResponseCacheKey hashes the prepared request, so requests that only differ
+// in omitted defaults share an entry. The size and modification time of every
+// image and font it reads are hashed too, so replacing a file on disk misses.
+func ResponseCacheKey(request ImgRequest) (string, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	hash.Write(data)
+	hash.Write([]byte(strconv.Itoa(request.ThumbnailWidthPx)))
+
+	for _, path := range requestFiles(&request) {
+		// Paths that are not files, like earlier batch results, only count by name
+		fmt.Fprintf(hash, "\x00%s", path)
+		if info, err := os.Stat(path); err == nil {
+			fmt.Fprintf(hash, "\x00%d\x00%d", info.Size(), info.ModTime().UnixNano())
+		}
+	}
+
+	return "response:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// requestFiles lists the images and fonts request reads from disk
+func requestFiles(request *ImgRequest) []string {
+	files := []string{}
+	if request.BgImgPath != "" {
+		files = append(files, request.BgImgPath)
+	}
+
+	for _, placed := range request.Images {
+		files = append(files, placed.Path)
+		if placed.MaskPath != "" {
+			files = append(files, placed.MaskPath)
+		}
+	}
+
+	for _, font := range requestFontFields(request) {
+		if *font != "" {
+			files = append(files, *font)
+		}
+	}
+
+	return files
+}
+
+type cachedResponse struct {
+	key     string
+	data    []byte
+	expires time.Time
+}
+
+// MemoryResponseCache is an LRU of responses private to the process
+type MemoryResponseCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+func NewMemoryResponseCache(capacity int) *MemoryResponseCache {
+	return &MemoryResponseCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  map[string]*list.Element{},
+	}
+}
+
+func (cache *MemoryResponseCache) Get(key string) ([]byte, bool, error) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	element, ok := cache.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	entry := element.Value.(*cachedResponse)
+	if time.Now().After(entry.expires) {
+		cache.order.Remove(element)
+		delete(cache.entries, key)
+		return nil, false, nil
+	}
+
+	cache.order.MoveToFront(element)
+	return entry.data, true, nil
+}
+
+func (cache *MemoryResponseCache) Set(key string, data []byte, ttl time.Duration) error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if element, ok := cache.entries[key]; ok {
+		cache.order.Remove(element)
+	}
+
+	cache.entries[key] = cache.order.PushFront(&cachedResponse{key, data, time.Now().Add(ttl)})
+
+	for cache.order.Len() > cache.capacity {
+		oldest := cache.order.Back()
+		cache.order.Remove(oldest)
+		delete(cache.entries, oldest.Value.(*cachedResponse).key)
+	}
+
+	return nil
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/fogleman/gg"
+	"golang.org/x/image/font"
+)
+
+type TextSpan struct {
+	Text   string  `json:"text"`
+	Color  Color   `json:"color"`
+	Font   string  `json:"font"`
+	SizePx float64 `json:"sizePx"`
+}
+
+// RichText flows differently styled spans inside a box. A newline inside a
+// span starts a new paragraph.
+type RichText struct {
+	Position           Position    `json:"position"`
+	WrapWidthPx        float64     `json:"wrapWidthPx" binding:"required"`
+	LineSpacing        float64     `json:"lineSpacing" default:"1.2"`
+	ParagraphSpacingPx float64     `json:"paragraphSpacingPx"`
+	Align              TextAlign   `json:"align"`
+	LineAligns         []TextAlign `json:"lineAligns"` // per wrapped line, empty or missing entries use align
+	Spans              []TextSpan  `json:"spans"`
+	Shortcodes         bool        `json:"shortcodes"` // expand :heart: style emoji shortcodes in every span
+	Visibility
+}
+
+// faceSpan is a span with its face already loaded. A nil color keeps the
+// color currently set on the context.
+type faceSpan struct {
+	text  string
+	face  font.Face
+	color *Color
+}
+
+type textRun struct {
+	text    string
+	face    font.Face
+	color   *Color
+	widthPx float64
+}
+
+type textLine struct {
+	runs []textRun
+	// Paragraph lines get the extra paragraph spacing below them
+	endsParagraph bool
+}
+
+func (line textLine) widthPx() float64 {
+	width := 0.0
+	for _, run := range line.runs {
+		width += run.widthPx
+	}
+
+	return width
+}
+
+func measureRun(face font.Face, text string) float64 {
+	return float64(font.MeasureString(face, text)) / 64
+}
+
+// splitWords breaks text into alternating word and whitespace tokens, with
+// each newline as its own token
+func splitWords(text string) []string {
+	tokens := []string{}
+	current := strings.Builder{}
+	currentIsSpace := false
+
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+
+	for _, r := range text {
+		if r == '\n' {
+			flush()
+			tokens = append(tokens, "\n")
+			continue
+		}
+
+		isSpace := unicode.IsSpace(r)
+		if isSpace != currentIsSpace {
+			flush()
+			currentIsSpace = isSpace
+		}
+
+		current.WriteRune(r)
+	}
+	flush()
+
+	return tokens
+}
+
+func layoutSpans(spans []faceSpan, wrapWidthPx float64) []textLine {
+	lines := []textLine{{}}
+	x := 0.0
+
+	for _, span := range spans {
+		for _, token := range splitWords(span.text) {
+			line := &lines[len(lines)-1]
+
+			if token == "\n" {
+				line.endsParagraph = true
+				lines = append(lines, textLine{})
+				x = 0
+				continue
+			}
+
+			isSpace := strings.TrimSpace(token) == ""
+			if isSpace && len(line.runs) == 0 {
+				continue
+			}
+
+			width := measureRun(span.face, token)
+			if !isSpace && len(line.runs) > 0 && x+width > wrapWidthPx {
+				// Drop the trailing space before wrapping
+				if last := line.runs[len(line.runs)-1]; strings.TrimSpace(last.text) == "" {
+					line.runs = line.runs[:len(line.runs)-1]
+				}
+
+				lines = append(lines, textLine{})
+				line = &lines[len(lines)-1]
+				x = 0
+			}
+
+			line.runs = append(line.runs, textRun{token, span.face, span.color, width})
+			x += width
+		}
+	}
+
+	return lines
+}
+
+// DrawRichText returns the y below the last line in request coordinates
+func DrawRichText(dc *gg.Context, richText RichText) float64 {
+	// Lay out in device pixels for the same reason as DrawText
+	scale := deviceScale(dc)
+	spans := []faceSpan{}
+
+	for _, span := range richText.Spans {
+		face, err := LoadFontFace(span.Font, span.SizePx*scale, "")
+		if err != nil {
+			panic(err)
+		}
+
+		spans = append(spans, faceSpan{span.Text, face, &span.Color})
+	}
+
+	box := richText
+	box.Position = Position{richText.Position.X * scale, richText.Position.Y * scale}
+	box.WrapWidthPx *= scale
+	box.ParagraphSpacingPx *= scale
+
+	dc.Push()
+	defer dc.Pop()
+	dc.Identity()
+
+	return drawLines(dc, layoutSpans(spans, box.WrapWidthPx), box) / scale
+}
+
+// drawLines draws laid out lines top-down from the box position and returns
+// the y below the last line. Only the position, width, spacing and alignment
+// of the box are used.
+func drawLines(dc *gg.Context, lines []textLine, box RichText) float64 {
+	lineSpacing := box.LineSpacing
+	if lineSpacing == 0 {
+		lineSpacing = 1.2
+	}
+
+	y := box.Position.Y
+
+	for i, line := range lines {
+		ascent, height := 0.0, 0.0
+		for _, run := range line.runs {
+			metrics := run.face.Metrics()
+			ascent = max(ascent, float64(metrics.Ascent)/64)
+			height = max(height, float64(metrics.Height)/64)
+		}
+
+		x := box.Position.X
+		switch lineAlign(box.LineAligns, i, box.Align) {
+		case Center:
+			x += (box.WrapWidthPx - line.widthPx()) / 2
+		case Right:
+			x += box.WrapWidthPx - line.widthPx()
+		}
+
+		for _, run := range line.runs {
+			dc.SetFontFace(run.face)
+			if run.color != nil {
+				dc.SetColor(run.color.ToRGBA())
+			}
+
+			dc.DrawString(run.text, x, y+ascent)
+			x += run.widthPx
+		}
+
+		y += height * lineSpacing
+		if line.endsParagraph {
+			y += box.ParagraphSpacingPx
+		}
+	}
+
+	return y
+}
+
+// lineAlign is the alignment override for line i, or align without one
+func lineAlign(lineAligns []TextAlign, i int, align TextAlign) TextAlign {
+	if i < len(lineAligns) && lineAligns[i] != "" {
+		return lineAligns[i]
+	}
+
+	return align
+}
+
+// limitTextLines keeps at most maxLines lines, ending the last one in an
+// ellipsis that fits within widthPx
+func limitTextLines(lines []textLine, widthPx float64, maxLines int) []textLine {
+	if maxLines <= 0 || len(lines) <= maxLines {
+		return lines
+	}
+
+	lines = lines[:maxLines]
+	last := &lines[maxLines-1]
+	last.endsParagraph = false
+
+	if len(last.runs) == 0 {
+		return lines
+	}
+
+	face := last.runs[len(last.runs)-1].face
+	ellipsis := textRun{"…", face, nil, measureRun(face, "…")}
+
+	for len(last.runs) > 0 && last.widthPx()+ellipsis.widthPx > widthPx {
+		run := &last.runs[len(last.runs)-1]
+		runes := []rune(run.text)
+
+		if len(runes) <= 1 {
+			last.runs = last.runs[:len(last.runs)-1]
+			continue
+		}
+
+		run.text = string(runes[:len(runes)-1])
+		run.widthPx = measureRun(run.face, run.text)
+	}
+
+	// Keep the color of the run the ellipsis follows
+	if len(last.runs) > 0 {
+		ellipsis.color = last.runs[len(last.runs)-1].color
+	}
+
+	last.runs = append(last.runs, ellipsis)
+	return lines
+}
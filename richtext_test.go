@@ -0,0 +1,66 @@
+package main
+
+import (
+	"image"
+	"testing"
+)
+
+// colorRows is the vertical extent of the pixels of img exactly matching c
+func colorRows(img image.Image, c Color) (int, int) {
+	top, bottom := -1, -1
+	bounds := img.Bounds()
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			if pixel(img, x, y) == nrgba(c) {
+				if top < 0 {
+					top = y
+				}
+				bottom = y
+			}
+		}
+	}
+
+	return top, bottom
+}
+
+func TestRichTextSpans(t *testing.T) {
+	fonts := newTestFonts(t)
+
+	tests := []struct {
+		name       string
+		second     string
+		paragraphs bool
+	}{
+		{"inline", " and blue", false},
+		{"paragraphs", "\nblue below", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := ImgRequest{
+				WidthPx:  300,
+				HeightPx: 120,
+				BgColor:  white,
+				RichTexts: []RichText{{
+					Position:    Position{10, 10},
+					WrapWidthPx: 280,
+					Spans: []TextSpan{
+						{Text: "Red", Font: fonts.bold, SizePx: 28, Color: red},
+						{Text: test.second, Font: fonts.regular, SizePx: 20, Color: blue},
+					},
+				}},
+			}
+			img := renderRequest(t, request, fonts.faces())
+
+			redTop, redBottom := colorRows(img, red)
+			blueTop, _ := colorRows(img, blue)
+			if redTop < 0 || blueTop < 0 {
+				t.Fatalf("red rows start at %d, blue rows at %d, want both colors", redTop, blueTop)
+			}
+
+			if below := blueTop > redBottom; below != test.paragraphs {
+				t.Errorf("blue starts at row %d after red ends at %d, want a new paragraph %v", blueTop, redBottom, test.paragraphs)
+			}
+		})
+	}
+}
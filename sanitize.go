@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TextSanitization decides what happens to control characters and invalid
+// UTF-8 in request text. The JSON decoder already turns invalid sequences and
+// unpaired surrogates into U+FFFD, so that rune is treated as invalid too.
+type TextSanitization string
+
+const (
+	SanitizeStrip   TextSanitization = "strip"
+	SanitizeReplace TextSanitization = "replace" // with U+FFFD
+	SanitizeReject  TextSanitization = "reject"
+	SanitizeOff     TextSanitization = "off"
+)
+
+func (mode TextSanitization) Validate() error {
+	switch mode {
+	case "", SanitizeStrip, SanitizeReplace, SanitizeReject, SanitizeOff:
+		return nil
+	}
+
+	return fmt.Errorf("text sanitization must be strip, replace, reject or off, not %q", mode)
+}
+
+// Newlines and tabs lay text out, so they stay
+func unsafeTextRune(r rune) bool {
+	return r == utf8.RuneError || (unicode.IsControl(r) && r != '\n' && r != '\t')
+}
+
+// sanitizeString cleans text and maps rune indices of the original to the
+// cleaned string, for highlight ranges
+func sanitizeString(text string, mode TextSanitization) (string, func(int) int, error) {
+	identity := func(i int) int { return i }
+	if mode == SanitizeOff || !strings.ContainsFunc(text, unsafeTextRune) && utf8.ValidString(text) {
+		return text, identity, nil
+	}
+
+	if mode == SanitizeReject {
+		return "", nil, fmt.Errorf("text %q contains control characters or invalid UTF-8", text)
+	}
+
+	if mode == SanitizeReplace {
+		return strings.Map(func(r rune) rune {
+			if unsafeTextRune(r) {
+				return utf8.RuneError
+			}
+			return r
+		}, strings.ToValidUTF8(text, string(utf8.RuneError))), identity, nil
+	}
+
+	// removed[i] counts the runes stripped before rune i
+	runes := []rune(text)
+	removed := make([]int, len(runes)+1)
+	cleaned := strings.Builder{}
+
+	for i, r := range runes {
+		removed[i+1] = removed[i]
+		if unsafeTextRune(r) {
+			removed[i+1]++
+			continue
+		}
+
+		cleaned.WriteRune(r)
+	}
+
+	return cleaned.String(), func(i int) int {
+		if i < 0 || i >= len(removed) {
+			return i
+		}
+
+		return i - removed[i]
+	}, nil
+}
+
+// SanitizeText cleans every text of the request before rendering
+func SanitizeText(request *ImgRequest, mode TextSanitization) error {
+	if mode == "" {
+		mode = SanitizeStrip
+	}
+
+	var err error
+	clean := func(text *string) func(int) int {
+		if err != nil {
+			return nil
+		}
+
+		var index func(int) int
+		*text, index, err = sanitizeString(*text, mode)
+		return index
+	}
+
+	styled := func(text *StyledText) {
+		index := clean(&text.Text)
+		if index == nil {
+			return
+		}
+
+		for i := range text.Highlights {
+			highlight := &text.Highlights[i]
+			highlight.Start, highlight.End = index(highlight.Start), index(highlight.End)
+		}
+	}
+
+	for i := range request.SingleLineTexts {
+		styled(&request.SingleLineTexts[i])
+	}
+
+	for i := range request.MultiLineTexts {
+		styled(&request.MultiLineTexts[i].StyledText)
+	}
+
+	for i := range request.TextStacks {
+		for j := range request.TextStacks[i].Items {
+			styled(&request.TextStacks[i].Items[j].StyledText)
+		}
+	}
+
+	for i := range request.RichTexts {
+		for j := range request.RichTexts[i].Spans {
+			clean(&request.RichTexts[i].Spans[j].Text)
+		}
+	}
+
+	for i := range request.Markdowns {
+		clean(&request.Markdowns[i].Text)
+	}
+
+	for i := range request.BarCharts {
+		for j := range request.BarCharts[i].Bars {
+			clean(&request.BarCharts[i].Bars[j].Label)
+		}
+	}
+
+	for i := range request.Callouts {
+		clean(&request.Callouts[i].Text)
+	}
+
+	for i := range request.Countdowns {
+		clean(&request.Countdowns[i].Separator)
+		for j := range request.Countdowns[i].Labels {
+			clean(&request.Countdowns[i].Labels[j])
+		}
+	}
+
+	return err
+}
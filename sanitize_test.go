@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSanitizeString(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		mode TextSanitization
+		want string
+		ok   bool
+	}{
+		{"clean text", "Hello\tworld\n", SanitizeStrip, "Hello\tworld\n", true},
+		{"strip", "He\x00ll\x07o\x1b", SanitizeStrip, "Hello", true},
+		{"strip invalid utf-8", "a\xffb�c", SanitizeStrip, "abc", true},
+		{"replace", "a\x07b\xffc", SanitizeReplace, "a�b�c", true},
+		{"reject", "a\x07b", SanitizeReject, "", false},
+		{"reject keeps clean text", "ab", SanitizeReject, "ab", true},
+		{"off", "a\x07b", SanitizeOff, "a\x07b", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, _, err := sanitizeString(test.text, test.mode)
+			if (err == nil) != test.ok || got != test.want {
+				t.Errorf("sanitizeString(%q, %s) = %q, %v, want %q", test.text, test.mode, got, err, test.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeTextHighlights(t *testing.T) {
+	request := ImgRequest{SingleLineTexts: []StyledText{{
+		Text:       "\x07find \x00the needle",
+		Highlights: []HighlightSpan{{Start: 11, End: 17}},
+	}}}
+
+	if err := SanitizeText(&request, SanitizeStrip); err != nil {
+		t.Fatal(err)
+	}
+
+	text := request.SingleLineTexts[0]
+	highlight := text.Highlights[0]
+	if got := string([]rune(text.Text)[highlight.Start:highlight.End]); got != "needle" {
+		t.Errorf("highlight covers %q after stripping, want %q", got, "needle")
+	}
+}
+
+func TestControlBytesInRequest(t *testing.T) {
+	fonts := newTestFonts(t)
+
+	body := func(text string) map[string]any {
+		return map[string]any{
+			"widthPx":         120,
+			"heightPx":        40,
+			"format":          "png",
+			"singleLineTexts": []map[string]any{{"text": text, "font": fonts.regular, "position": map[string]any{"x": 5, "y": 30}}},
+			"callouts":        []map[string]any{{"position": map[string]any{"x": 0, "y": 0}, "widthPx": 10, "heightPx": 10, "text": text, "font": fonts.regular}},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		mode   TextSanitization
+		status int
+	}{
+		{"strip by default", "", 200},
+		{"replace", SanitizeReplace, 200},
+		{"reject", SanitizeReject, 400},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newTestServer(t, Config{FontDir: fonts.dir, TextSanitization: test.mode})
+
+			response := postJSON(t, server.URL+"/generate", body("Hi\x00\x07\x1b there\u0085"))
+			dirty := readBody(t, response)
+			if response.StatusCode != test.status {
+				t.Fatalf("status %d, want %d: %s", response.StatusCode, test.status, dirty)
+			}
+
+			if test.mode != "" {
+				return
+			}
+
+			clean := readBody(t, postJSON(t, server.URL+"/generate", body("Hi there")))
+			if !bytes.Equal(dirty, clean) {
+				t.Error("stripped text renders differently from the clean text")
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// LoadScriptFonts parses SCRIPT_FONTS, a comma separated list of Script=font
+// pairs, into the fonts used by StyledText.AutoFont keyed by Unicode script
+// name such as Latin, Cyrillic, Han or Arabic. Every font must be one of
+// fontFaces.
+func LoadScriptFonts(value string, fontFaces []string) map[string]string {
+	fonts := map[string]string{}
+
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		script, path, _ := strings.Cut(pair, "=")
+		script, path = strings.TrimSpace(script), strings.TrimSpace(path)
+
+		if _, ok := unicode.Scripts[script]; !ok {
+			panic(fmt.Sprintf("SCRIPT_FONTS: unknown script %q", script))
+		}
+
+		if !slices.Contains(fontFaces, path) {
+			panic(fmt.Sprintf("SCRIPT_FONTS: font %q not found", path))
+		}
+
+		fonts[script] = path
+	}
+
+	return fonts
+}
+
+// scriptOf returns the mapped script r belongs to, or "" for runes such as
+// spaces, digits and punctuation that are shared between scripts
+func scriptOf(r rune, scriptFonts map[string]string) string {
+	// Scripts don't overlap, so the map order can't change the answer
+	for script := range scriptFonts {
+		if unicode.Is(unicode.Scripts[script], r) {
+			return script
+		}
+	}
+
+	return ""
+}
+
+type scriptRun struct {
+	text string
+	font string
+}
+
+// splitScripts cuts text into runs of one script each and picks the mapped
+// font for every run, falling back to fallback for unmapped scripts. Shared
+// runes stay with the run they follow.
+func splitScripts(text string, fallback string, scriptFonts map[string]string) []scriptRun {
+	runs := []scriptRun{}
+	current := strings.Builder{}
+	currentFont := ""
+
+	for _, r := range text {
+		runeFont := currentFont
+		if script := scriptOf(r, scriptFonts); script != "" {
+			runeFont = scriptFonts[script]
+		} else if !unicode.In(r, unicode.Common, unicode.Inherited) {
+			runeFont = fallback
+		}
+
+		if runeFont == "" {
+			runeFont = fallback
+		}
+
+		if runeFont != currentFont && current.Len() > 0 {
+			runs = append(runs, scriptRun{current.String(), currentFont})
+			current.Reset()
+		}
+
+		currentFont = runeFont
+		current.WriteRune(r)
+	}
+
+	if current.Len() > 0 {
+		runs = append(runs, scriptRun{current.String(), currentFont})
+	}
+
+	return runs
+}
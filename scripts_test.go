@@ -0,0 +1,42 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitScripts(t *testing.T) {
+	fonts := newTestFonts(t)
+
+	// Any registered file will do, only the chosen path is checked
+	scriptFonts := LoadScriptFonts("Latin="+fonts.regular+", Han="+fonts.mono, fonts.faces())
+
+	tests := []struct {
+		text string
+		want []scriptRun
+	}{
+		{"Hello", []scriptRun{{"Hello", fonts.regular}}},
+		{"Hello 世界", []scriptRun{{"Hello ", fonts.regular}, {"世界", fonts.mono}}},
+		{"世界 says hi!", []scriptRun{{"世界 ", fonts.mono}, {"says hi!", fonts.regular}}},
+		// Unmapped scripts use the text's own font
+		{"abc Привет", []scriptRun{{"abc ", fonts.regular}, {"Привет", fonts.bold}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			if got := splitScripts(test.text, fonts.bold, scriptFonts); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("splitScripts(%q) = %v, want %v", test.text, got, test.want)
+			}
+		})
+	}
+}
+
+func TestLoadScriptFontsRejectsUnknownFont(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("unregistered font was accepted")
+		}
+	}()
+
+	LoadScriptFonts("Han=/no/such/font.ttf", nil)
+}
@@ -0,0 +1,695 @@
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// Config is everything a Server reads from its environment. It can come from
+// a JSON file named by CONFIG_FILE, with environment variables taking
+// precedence over the file.
+type Config struct {
+	Port             int              `json:"port" default:"8080"`
+	APIKey           string           `json:"apiKey"`
+	APIKeys          []string         `json:"apiKeys"`     // all accepted, for rotation; APIKey is used when empty
+	MaxWidthPx       int              `json:"maxWidthPx"`  // 0 is unlimited
+	MaxHeightPx      int              `json:"maxHeightPx"` // 0 is unlimited
+	FontDir          string           `json:"fontDir" default:"gfonts"`
+	AssetDir         string           `json:"assetDir" default:"assets"`
+	OutputDir        string           `json:"outputDir"`                        // where file outputs are written, disabled when empty
+	ImageCacheSize   int              `json:"imageCacheSize"`                   // decoded images kept, 0 uses the default and negative disables
+	RenderTimeout    Duration         `json:"renderTimeout"`                    // such as "10s", 0 waits forever
+	DailyQuota       int              `json:"dailyQuota"`                       // generate requests per API key, 0 is unlimited
+	MonthlyQuota     int              `json:"monthlyQuota"`                     // generate requests per API key, 0 is unlimited
+	ResponseTTL      Duration         `json:"responseTtl"`                      // caches generated images, 0 disables
+	RedisURL         string           `json:"redisUrl"`                         // shares usage and cached images, in memory when empty
+	ScriptFonts      string           `json:"scriptFonts"`                      // Script=font pairs, see LoadScriptFonts
+	TextSanitization TextSanitization `json:"textSanitization" default:"strip"` // control characters and invalid UTF-8 in text
+	SignatureKey     string           `json:"signatureKey"`                     // signs image responses with X-Content-Signature, off when empty
+	FontURLPrefixes  []string         `json:"fontUrlPrefixes"`                  // fonts may be given by URLs starting with one of these, off when empty
+	MaxFontBytes     int              `json:"maxFontBytes" default:"10485760"`  // largest font fetched by URL
+	Defaults         Defaults         `json:"-"`
+}
+
+// Duration reads a time.Duration from strings such as "1.5s"
+type Duration time.Duration
+
+func (duration *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	parsed, err := time.ParseDuration(value)
+	*duration = Duration(parsed)
+	return err
+}
+
+// LoadConfig reads CONFIG_FILE when set and then applies environment
+// variable overrides
+func LoadConfig() Config {
+	config := Config{Port: 8080, FontDir: "gfonts", AssetDir: "assets", MaxFontBytes: 10 << 20}
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			panic(err)
+		}
+
+		if err := json.Unmarshal(data, &config); err != nil {
+			panic(fmt.Sprintf("%s: %v", path, err))
+		}
+	}
+
+	envString := func(name string, value *string) {
+		if env := os.Getenv(name); env != "" {
+			*value = env
+		}
+	}
+
+	envInt := func(name string, value *int) {
+		if env := os.Getenv(name); env != "" {
+			parsed, err := strconv.Atoi(env)
+			if err != nil {
+				panic(fmt.Sprintf("%s: %v", name, err))
+			}
+
+			*value = parsed
+		}
+	}
+
+	envInt("PORT", &config.Port)
+	envString("API_KEY", &config.APIKey)
+
+	// Mounted secrets such as Docker or Kubernetes ones win over API_KEY
+	if path := os.Getenv("API_KEY_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			panic(err)
+		}
+
+		config.APIKey = strings.TrimRight(string(data), "\r\n")
+	}
+
+	if keys := os.Getenv("API_KEYS"); keys != "" {
+		config.APIKeys = splitKeys(strings.Split(keys, ","))
+	}
+
+	// One key per line
+	if path := os.Getenv("API_KEYS_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			panic(err)
+		}
+
+		config.APIKeys = splitKeys(strings.Split(string(data), "\n"))
+	}
+
+	envInt("MAX_WIDTH_PX", &config.MaxWidthPx)
+	envInt("MAX_HEIGHT_PX", &config.MaxHeightPx)
+	envString("FONT_DIR", &config.FontDir)
+	envString("ASSET_DIR", &config.AssetDir)
+	envString("OUTPUT_DIR", &config.OutputDir)
+	envInt("IMAGE_CACHE_SIZE", &config.ImageCacheSize)
+	envInt("DAILY_QUOTA", &config.DailyQuota)
+	envInt("MONTHLY_QUOTA", &config.MonthlyQuota)
+	envString("SCRIPT_FONTS", &config.ScriptFonts)
+	envString("REDIS_URL", &config.RedisURL)
+	envString("SIGNATURE_KEY", &config.SignatureKey)
+	envInt("MAX_FONT_BYTES", &config.MaxFontBytes)
+
+	if prefixes := os.Getenv("FONT_URL_PREFIXES"); prefixes != "" {
+		config.FontURLPrefixes = splitKeys(strings.Split(prefixes, ","))
+	}
+
+	if value := os.Getenv("TEXT_SANITIZATION"); value != "" {
+		config.TextSanitization = TextSanitization(value)
+	}
+
+	if err := config.TextSanitization.Validate(); err != nil {
+		panic(err)
+	}
+
+	envDuration := func(name string, value *Duration) {
+		if env := os.Getenv(name); env != "" {
+			parsed, err := time.ParseDuration(env)
+			if err != nil {
+				panic(fmt.Sprintf("%s: %v", name, err))
+			}
+
+			*value = Duration(parsed)
+		}
+	}
+
+	envDuration("RENDER_TIMEOUT", &config.RenderTimeout)
+	envDuration("RESPONSE_TTL", &config.ResponseTTL)
+
+	config.Defaults = LoadDefaults()
+	return config
+}
+
+// Server holds the font and asset registries and serves the HTTP API. The
+// image cache and script fonts reach rendering through the requests it
+// prepares, so several servers can run side by side.
+type Server struct {
+	config      Config
+	fontMutex   sync.RWMutex // guards fontFaces against a concurrent reload
+	fontFaces   []string
+	assets      map[string]string
+	images      *ImageCache
+	scriptFonts map[string]string
+	remote      *RemoteFonts // nil unless fonts by URL are enabled
+	usage       UsageStore
+	responses   ResponseCache
+	router      *gin.Engine
+}
+
+func NewServer(config Config) *Server {
+	server := &Server{
+		config:    config,
+		fontFaces: BuildFontFaceList(config.FontDir),
+		assets:    BuildAssetList(config.AssetDir),
+		usage:     NewMemoryUsageStore(),
+		responses: NewMemoryResponseCache(64),
+	}
+
+	if config.RedisURL != "" {
+		store, err := NewRedisStore(config.RedisURL)
+		if err != nil {
+			panic(err)
+		}
+
+		server.usage, server.responses = store, RedisResponseCache{store}
+	}
+
+	server.scriptFonts = LoadScriptFonts(config.ScriptFonts, server.fontFaces)
+
+	if len(config.FontURLPrefixes) > 0 {
+		remote, err := NewRemoteFonts(config.FontURLPrefixes, int64(config.MaxFontBytes))
+		if err != nil {
+			panic(err)
+		}
+
+		server.remote = remote
+	}
+
+	cacheSize := config.ImageCacheSize
+	if cacheSize == 0 {
+		cacheSize = defaultImageCacheSize
+	}
+
+	server.images = NewImageCache(cacheSize)
+
+	opts := gin.OptionFunc(func(engine *gin.Engine) {
+		engine.Use(gin.Recovery())
+	})
+
+	router := gin.New(opts)
+
+	router.Use(Authenticate(config.apiKeys()))
+	router.Use(Compress())
+
+	router.GET("/font-faces", func(c *gin.Context) {
+		c.JSON(200, gin.H{"fontFaces": server.FontFaces()})
+	})
+
+	router.POST("/font-faces/reload", func(c *gin.Context) {
+		c.JSON(200, gin.H{"fontFaces": server.ReloadFonts()})
+	})
+
+	router.GET("/font-faces/metrics", func(c *gin.Context) {
+		fontPath := c.Query("font")
+		if !slices.Contains(server.FontFaces(), fontPath) {
+			c.JSON(400, gin.H{"error": "Font not found"})
+			return
+		}
+
+		size, err := strconv.ParseFloat(c.Query("size"), 64)
+		if err != nil || size <= 0 {
+			c.JSON(400, gin.H{"error": "Invalid size"})
+			return
+		}
+
+		metrics, err := MeasureFont(fontPath, size, c.Query("text"))
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, metrics)
+	})
+
+	router.GET("/assets", func(c *gin.Context) {
+		c.JSON(200, gin.H{"assets": server.assets})
+	})
+
+	router.GET("/usage", func(c *gin.Context) {
+		usage, err := Usage(server.usage, c.GetHeader("Authorization"))
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, usage)
+	})
+
+	quota := Quota(server.usage, config.DailyQuota, config.MonthlyQuota)
+
+	// generate serves /generate, with bind decoding the request from the body
+	// or the query
+	generate := func(c *gin.Context, bind func(request *ImgRequest) error) {
+		var request ImgRequest
+
+		errorMode := c.Query("errorMode")
+		fail := func(status int, message string) {
+			if errorMode != "image" {
+				c.JSON(status, gin.H{"error": message})
+				return
+			}
+
+			// Clients embedding the endpoint in an <img> tag still get an image
+			buff := new(bytes.Buffer)
+			if err := EncodeImage(buff, RenderErrorImage(request.WidthPx, request.HeightPx, message), JPEG, 90); err != nil {
+				c.JSON(500, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.Data(status, "image/jpeg", buff.Bytes())
+		}
+
+		if err := bind(&request); err != nil {
+			fail(400, err.Error())
+			return
+		}
+
+		if thumbnail := c.Query("thumbnail"); thumbnail != "" {
+			width, err := strconv.Atoi(thumbnail)
+			if err != nil || width <= 0 {
+				fail(400, "Invalid thumbnail width")
+				return
+			}
+
+			request.ThumbnailWidthPx = width
+		}
+
+		if err := server.PrepareRequest(&request); err != nil {
+			fail(400, err.Error())
+			return
+		}
+
+		// Debugging aid that describes the image instead of rendering it
+		if mode := c.Query("layout"); mode != "" {
+			if mode != "json" {
+				c.JSON(400, gin.H{"error": "layout must be json"})
+				return
+			}
+
+			layout, err := TryComputeLayout(request)
+			if err != nil {
+				c.JSON(500, gin.H{"error": "Failed to compute layout: " + err.Error()})
+				return
+			}
+
+			c.JSON(200, layout)
+			return
+		}
+
+		contentType, _ := ContentType(request.Format)
+
+		outputPath := ""
+		if request.Output != nil && request.Output.Type == FileOutput {
+			var err error
+			if outputPath, err = OutputPath(config.OutputDir, request.Output.Path); err != nil {
+				fail(400, err.Error())
+				return
+			}
+		}
+
+		respond := func(data []byte) {
+			if outputPath == "" {
+				server.signResponse(c, data)
+				c.Data(200, contentType, data)
+				return
+			}
+
+			if err := WriteOutputFile(config.OutputDir, outputPath, data); errors.Is(err, errOutputOutsideDir) {
+				fail(400, err.Error())
+				return
+			} else if err != nil {
+				fail(500, err.Error())
+				return
+			}
+
+			c.JSON(200, gin.H{"path": request.Output.Path, "bytes": len(data)})
+		}
+
+		cacheKey := ""
+		if config.ResponseTTL > 0 {
+			var err error
+			if cacheKey, err = ResponseCacheKey(request); err != nil {
+				fail(500, err.Error())
+				return
+			}
+
+			data, ok, err := server.responses.Get(cacheKey)
+			if err != nil {
+				fail(500, err.Error())
+				return
+			}
+
+			if ok {
+				c.Header("X-Cache", "HIT")
+				respond(data)
+				return
+			}
+
+			c.Header("X-Cache", "MISS")
+		}
+
+		var image *bytes.Buffer
+		err := server.withTimeout(func() (err error) {
+			image, err = TryGenerateImage(request)
+			return err
+		})
+		if errors.Is(err, errRenderTimeout) {
+			fail(503, err.Error())
+			return
+		}
+		if err != nil {
+			fail(500, "Failed to generate image: "+err.Error())
+			return
+		}
+
+		if cacheKey != "" {
+			if err := server.responses.Set(cacheKey, image.Bytes(), time.Duration(config.ResponseTTL)); err != nil {
+				fail(500, err.Error())
+				return
+			}
+		}
+
+		// Stream image to client, or write it out
+		respond(image.Bytes())
+	}
+
+	router.POST("/generate", quota, func(c *gin.Context) {
+		generate(c, func(request *ImgRequest) error {
+			return c.ShouldBindJSON(request)
+		})
+	})
+
+	// Embeds such as <img src> or markdown images can't POST, so the same JSON
+	// can be passed base64url encoded in the request query parameter
+	router.GET("/generate", quota, func(c *gin.Context) {
+		generate(c, func(request *ImgRequest) error {
+			data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(c.Query("request"), "="))
+			if err != nil {
+				return fmt.Errorf("request must be base64url encoded JSON: %w", err)
+			}
+
+			return binding.JSON.BindBody(data, request)
+		})
+	})
+
+	router.POST("/generate/batch", quota, func(c *gin.Context) {
+		var batch BatchRequest
+		if err := c.ShouldBindJSON(&batch); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		var results []BatchResult
+		var err *batchError
+		if timeoutErr := server.withTimeout(func() error {
+			results, err = GenerateBatch(batch, server.PrepareRequest)
+			return nil
+		}); timeoutErr != nil {
+			c.JSON(503, gin.H{"error": timeoutErr.Error()})
+			return
+		}
+
+		if err != nil {
+			c.JSON(err.status, gin.H{"error": err.Error(), "index": err.index})
+			return
+		}
+
+		c.JSON(200, gin.H{"images": results})
+	})
+
+	router.POST("/generate/pdf", quota, func(c *gin.Context) {
+		var request PDFRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := request.Validate(); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		var pdf *bytes.Buffer
+		var err *batchError
+		if timeoutErr := server.withTimeout(func() error {
+			pdf, err = GeneratePDF(request, server.PrepareRequest)
+			return nil
+		}); timeoutErr != nil {
+			c.JSON(503, gin.H{"error": timeoutErr.Error()})
+			return
+		}
+
+		if err != nil {
+			c.JSON(err.status, gin.H{"error": err.Error(), "index": err.index})
+			return
+		}
+
+		server.signResponse(c, pdf.Bytes())
+		c.Data(200, "application/pdf", pdf.Bytes())
+	})
+
+	router.POST("/og", quota, func(c *gin.Context) {
+		var card OGRequest
+		if err := c.ShouldBindJSON(&card); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Sanitized before the title is measured for fitting
+		for _, text := range []*string{&card.Title, &card.Subtitle} {
+			var err error
+			if *text, _, err = sanitizeString(*text, config.TextSanitization); err != nil {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		if err := card.Prepare(server.FontFaces()); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		request, err := card.ImgRequest()
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := server.PrepareRequest(&request); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		var image *bytes.Buffer
+		err = server.withTimeout(func() (err error) {
+			image, err = TryGenerateImage(request)
+			return err
+		})
+		if errors.Is(err, errRenderTimeout) {
+			c.JSON(503, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to generate image: " + err.Error()})
+			return
+		}
+
+		contentType, _ := ContentType(request.Format)
+		server.signResponse(c, image.Bytes())
+		c.Data(200, contentType, image.Bytes())
+	})
+
+	router.POST("/swatch", quota, func(c *gin.Context) {
+		var request SwatchRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		for i := range request.Colors {
+			label := &request.Colors[i].Label
+			var err error
+			if *label, _, err = sanitizeString(*label, config.TextSanitization); err != nil {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		if err := request.Prepare(server.FontFaces()); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		width, height := request.Size()
+		if (config.MaxWidthPx > 0 && width > config.MaxWidthPx) || (config.MaxHeightPx > 0 && height > config.MaxHeightPx) {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("swatch grid of %dx%d exceeds the maximum canvas size", width, height)})
+			return
+		}
+
+		var image *bytes.Buffer
+		if err := server.withTimeout(func() error {
+			image = EncodeRequest(RenderSwatches(request), ImgRequest{Format: request.Format, Quality: request.Quality})
+			return nil
+		}); err != nil {
+			c.JSON(503, gin.H{"error": err.Error()})
+			return
+		}
+
+		contentType, _ := ContentType(request.Format)
+		server.signResponse(c, image.Bytes())
+		c.Data(200, contentType, image.Bytes())
+	})
+
+	server.router = router
+	return server
+}
+
+func splitKeys(keys []string) []string {
+	trimmed := []string{}
+	for _, key := range keys {
+		if key = strings.TrimSpace(key); key != "" {
+			trimmed = append(trimmed, key)
+		}
+	}
+
+	return trimmed
+}
+
+// apiKeys lists every accepted key
+func (config Config) apiKeys() []string {
+	if len(config.APIKeys) > 0 {
+		return config.APIKeys
+	}
+
+	return []string{config.APIKey}
+}
+
+// FontFaces returns the current font registry. Reloads replace the slice
+// rather than modifying it, so callers may keep reading the one returned.
+func (server *Server) FontFaces() []string {
+	server.fontMutex.RLock()
+	defer server.fontMutex.RUnlock()
+
+	return server.fontFaces
+}
+
+// ReloadFonts rescans the font directory and swaps in the new registry
+func (server *Server) ReloadFonts() []string {
+	fontFaces := BuildFontFaceList(server.config.FontDir)
+
+	server.fontMutex.Lock()
+	defer server.fontMutex.Unlock()
+
+	server.fontFaces = fontFaces
+	return fontFaces
+}
+
+// PrepareRequest applies the server defaults and validates the request
+// against the registries and limits of the server
+func (server *Server) PrepareRequest(request *ImgRequest) error {
+	request.ImageCache, request.ScriptFonts = server.images, server.scriptFonts
+
+	if err := SanitizeText(request, server.config.TextSanitization); err != nil {
+		return err
+	}
+
+	fontFaces := server.FontFaces()
+	if server.remote != nil {
+		fetched, err := server.remote.Resolve(request)
+		if err != nil {
+			return err
+		}
+
+		fontFaces = slices.Concat(fontFaces, fetched)
+	}
+
+	if err := PrepareRequest(request, fontFaces, server.assets, server.config.Defaults); err != nil {
+		return err
+	}
+
+	if server.config.MaxWidthPx > 0 && request.WidthPx > server.config.MaxWidthPx {
+		return fmt.Errorf("widthPx must be at most %d", server.config.MaxWidthPx)
+	}
+
+	if server.config.MaxHeightPx > 0 && request.HeightPx > server.config.MaxHeightPx {
+		return fmt.Errorf("heightPx must be at most %d", server.config.MaxHeightPx)
+	}
+
+	return nil
+}
+
+var errRenderTimeout = errors.New("Rendering timed out")
+
+// withTimeout runs render, giving up after the configured render timeout. The
+// render itself can't be interrupted and finishes in the background.
+func (server *Server) withTimeout(render func() error) error {
+	if server.config.RenderTimeout <= 0 {
+		return render()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- render()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(time.Duration(server.config.RenderTimeout)):
+		return errRenderTimeout
+	}
+}
+
+func (server *Server) Handler() http.Handler {
+	return server.router
+}
+
+// Authenticate accepts any of apiKeys. Every key is compared in constant time
+// so timing doesn't reveal which one, or how much of it, matched.
+func Authenticate(apiKeys []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := []byte(c.GetHeader("Authorization"))
+
+		matched := 0
+		for _, key := range apiKeys {
+			matched |= subtle.ConstantTimeCompare(token, []byte(key))
+		}
+
+		if matched == 0 {
+			c.JSON(401, gin.H{"error": "Unauthorized"})
+			c.Abort()
+		}
+	}
+}
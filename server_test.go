@@ -0,0 +1,552 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	"image/color"
+	"maps"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestThumbnailWidth(t *testing.T) {
+	server := newTestServer(t, Config{})
+
+	tests := []struct {
+		query         string
+		width, height int
+	}{
+		{"", 400, 200},
+		{"?thumbnail=100", 100, 50},
+		{"?thumbnail=250", 250, 125},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			response := postJSON(t, server.URL+"/generate"+test.query, ImgRequest{WidthPx: 400, HeightPx: 200, BgColor: red, Format: PNG})
+			body := readBody(t, response)
+			if response.StatusCode != 200 {
+				t.Fatalf("status %d: %s", response.StatusCode, body)
+			}
+
+			if size := decodeImage(t, body).Bounds().Size(); size.X != test.width || size.Y != test.height {
+				t.Errorf("size %v, want %dx%d", size, test.width, test.height)
+			}
+		})
+	}
+}
+
+func TestErrorMode(t *testing.T) {
+	server := newTestServer(t, Config{})
+	failing := ImgRequest{
+		WidthPx:         300,
+		HeightPx:        150,
+		SingleLineTexts: []StyledText{{Text: "missing font", Font: "/no/such/font.ttf"}},
+	}
+
+	tests := []struct {
+		query       string
+		contentType string
+	}{
+		{"", "application/json; charset=utf-8"},
+		{"?errorMode=image", "image/jpeg"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			response := postJSON(t, server.URL+"/generate"+test.query, failing)
+			body := readBody(t, response)
+
+			if response.StatusCode != 400 {
+				t.Errorf("status %d, want 400", response.StatusCode)
+			}
+
+			if got := response.Header.Get("Content-Type"); got != test.contentType {
+				t.Fatalf("content type %q, want %q", got, test.contentType)
+			}
+
+			if test.contentType != "image/jpeg" {
+				return
+			}
+
+			img := decodeImage(t, body)
+			if size := img.Bounds().Size(); size.X != 300 || size.Y != 150 {
+				t.Errorf("size %v, want the requested 300x150", size)
+			}
+
+			if got := pixel(img, 2, 2); !closeTo(got, color.NRGBA{200, 30, 30, 255}, 8) {
+				t.Errorf("background %v, want the error red", got)
+			}
+		})
+	}
+}
+
+func TestFontMetricsEndpoint(t *testing.T) {
+	fonts := newTestFonts(t)
+	server := newTestServer(t, Config{FontDir: fonts.dir})
+
+	tests := []struct {
+		name   string
+		query  url.Values
+		status int
+	}{
+		{"metrics", url.Values{"font": {fonts.regular}, "size": {"40"}, "text": {"AV"}}, 200},
+		{"unknown font", url.Values{"font": {"/no/such/font.ttf"}, "size": {"40"}}, 400},
+		{"bad size", url.Values{"font": {fonts.regular}, "size": {"-1"}}, 400},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := getURL(t, server.URL+"/font-faces/metrics?"+test.query.Encode())
+			body := readBody(t, response)
+			if response.StatusCode != test.status {
+				t.Fatalf("status %d, want %d: %s", response.StatusCode, test.status, body)
+			}
+
+			if test.status != 200 {
+				return
+			}
+
+			var metrics FontMetrics
+			if err := json.Unmarshal(body, &metrics); err != nil {
+				t.Fatal(err)
+			}
+
+			// Line gap aside, the line height is the ascent plus the descent
+			if sum := metrics.AscentPx + metrics.DescentPx; math.Abs(sum-metrics.LineHeightPx) > metrics.SizePx*0.1 {
+				t.Errorf("ascent %.2f + descent %.2f = %.2f, want about the line height %.2f", metrics.AscentPx, metrics.DescentPx, sum, metrics.LineHeightPx)
+			}
+
+			if len(metrics.Advances) != 2 {
+				t.Errorf("%d advances, want one per rune of AV", len(metrics.Advances))
+			}
+		})
+	}
+}
+
+func TestAssetLibrary(t *testing.T) {
+	dir, _ := testAssets(t, map[string]image.Image{"logo": solidImage(20, 20, red)})
+	writeTestImage(t, filepath.Join(dir, "icons", "check.png"), solidImage(10, 10, green))
+
+	// Readable, but not part of the library
+	outside := filepath.Join(t.TempDir(), "secret.png")
+	writeTestImage(t, outside, solidImage(20, 20, blue))
+
+	server := newTestServer(t, Config{AssetDir: dir})
+
+	response := getURL(t, server.URL+"/assets")
+	body := readBody(t, response)
+	if response.StatusCode != 200 {
+		t.Fatalf("status %d: %s", response.StatusCode, body)
+	}
+
+	var listing struct {
+		Assets map[string]string `json:"assets"`
+	}
+	if err := json.Unmarshal(body, &listing); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"logo": filepath.Join(dir, "logo.png"), "icons/check": filepath.Join(dir, "icons", "check.png")}
+	if !maps.Equal(listing.Assets, want) {
+		t.Errorf("assets %v, want %v", listing.Assets, want)
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		want   Color
+	}{
+		{"short name", "logo", 200, red},
+		{"nested name", "icons/check", 200, green},
+		{"asset file path", filepath.Join(dir, "logo.png"), 200, red},
+		{"unknown name", "missing", 400, Color{}},
+		{"path outside", outside, 400, Color{}},
+		{"traversal", filepath.Join(dir, "..", "secret.png"), 400, Color{}},
+		{"system file", "/etc/passwd", 400, Color{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := ImgRequest{WidthPx: 40, HeightPx: 40, BgColor: white, Images: []PlacedImage{{Path: test.path}}}
+			response := postJSON(t, server.URL+"/generate", request)
+			body := readBody(t, response)
+			if response.StatusCode != test.status {
+				t.Fatalf("status %d, want %d: %s", response.StatusCode, test.status, body)
+			}
+
+			if test.status != 200 {
+				return
+			}
+
+			if got := pixel(decodeImage(t, body), 5, 5); got != nrgba(test.want) {
+				t.Errorf("asset pixel %v, want %v", got, test.want)
+			}
+		})
+	}
+
+	// Backgrounds go through the library too
+	response = postJSON(t, server.URL+"/generate", ImgRequest{BgImgPath: outside})
+	if body := readBody(t, response); response.StatusCode != 400 {
+		t.Errorf("background outside the library: status %d, want 400: %s", response.StatusCode, body)
+	}
+}
+
+func TestUnsupportedImageUpload(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "notes.png"), []byte("just some text, not an image"))
+	server := newTestServer(t, Config{AssetDir: dir})
+
+	encode := func(format ImageFormat) []byte {
+		buff := new(bytes.Buffer)
+		if err := EncodeImage(buff, solidImage(16, 16, red), format, 0); err != nil {
+			t.Fatal(err)
+		}
+		return buff.Bytes()
+	}
+	pngData := encode(PNG)
+
+	tests := []struct {
+		name    string
+		request ImgRequest
+		message string
+	}{
+		{"text as background", ImgRequest{BgImgBase64: base64.StdEncoding.EncodeToString([]byte("hello"))}, "background image is not a supported image"},
+		{"text file asset", ImgRequest{WidthPx: 20, HeightPx: 20, Images: []PlacedImage{{Path: "notes"}}}, "image 0 is not a supported image"},
+		{"truncated png", ImgRequest{BgImgBase64: base64.StdEncoding.EncodeToString(pngData[:len(pngData)/2])}, "background image is corrupt"},
+		{"bmp", ImgRequest{BgImgBase64: base64.StdEncoding.EncodeToString(encode(BMP))}, "unsupported format bmp"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := postJSON(t, server.URL+"/generate", test.request)
+			body := readBody(t, response)
+			if response.StatusCode != 400 {
+				t.Fatalf("status %d, want 400: %s", response.StatusCode, body)
+			}
+
+			var failure struct {
+				Error string `json:"error"`
+			}
+			if err := json.Unmarshal(body, &failure); err != nil {
+				t.Fatal(err)
+			}
+
+			if !strings.Contains(failure.Error, test.message) {
+				t.Errorf("error %q, want it to mention %q", failure.Error, test.message)
+			}
+		})
+	}
+}
+
+func TestServersSideBySide(t *testing.T) {
+	dir, _ := testAssets(t, map[string]image.Image{"logo": solidImage(10, 10, green)})
+
+	// Each server keeps its own defaults, registries and image cache
+	redDefaults, blueDefaults := testDefaults, testDefaults
+	redDefaults.BgColor, blueDefaults.BgColor = &red, &blue
+
+	first := NewServer(Config{APIKey: testAPIKey, AssetDir: dir, Defaults: redDefaults})
+	second := NewServer(Config{APIKey: testAPIKey, AssetDir: dir, Defaults: blueDefaults, ImageCacheSize: -1})
+
+	tests := []struct {
+		name   string
+		server *Server
+		want   Color
+		cached int
+	}{
+		{"first", first, red, 1},
+		{"second", second, blue, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			httpServer := httptest.NewServer(test.server.Handler())
+			defer httpServer.Close()
+
+			request := ImgRequest{WidthPx: 30, HeightPx: 30, Images: []PlacedImage{{Path: "logo"}}}
+			response := postJSON(t, httpServer.URL+"/generate", request)
+			body := readBody(t, response)
+			if response.StatusCode != 200 {
+				t.Fatalf("status %d: %s", response.StatusCode, body)
+			}
+
+			img := decodeImage(t, body)
+			if got := pixel(img, 20, 20); got != nrgba(test.want) {
+				t.Errorf("background %v, want %v", got, test.want)
+			}
+
+			if got := pixel(img, 5, 5); got != nrgba(green) {
+				t.Errorf("asset %v, want %v", got, green)
+			}
+
+			if got := len(test.server.images.entries); got != test.cached {
+				t.Errorf("%d cached images, want %d", got, test.cached)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	fonts := newTestFonts(t)
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeTestFile(t, path, []byte(`{
+		"port": 9000,
+		"apiKey": "from-file",
+		"maxWidthPx": 500,
+		"maxHeightPx": 400,
+		"fontDir": "`+fonts.dir+`",
+		"assetDir": "/srv/assets",
+		"imageCacheSize": 8,
+		"renderTimeout": "2s"
+	}`))
+
+	for _, name := range []string{"PORT", "API_KEY", "API_KEY_FILE", "API_KEYS", "API_KEYS_FILE", "MAX_WIDTH_PX", "MAX_HEIGHT_PX", "FONT_DIR", "ASSET_DIR", "IMAGE_CACHE_SIZE", "RENDER_TIMEOUT"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("CONFIG_FILE", path)
+
+	// The fields the file sets
+	type loaded struct {
+		port, maxWidth, maxHeight, cacheSize int
+		apiKey, fontDir, assetDir            string
+		timeout                              time.Duration
+	}
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		want loaded
+	}{
+		{"file", nil, loaded{9000, 500, 400, 8, "from-file", fonts.dir, "/srv/assets", 2 * time.Second}},
+		{"env wins", map[string]string{"PORT": "9100", "API_KEY": testAPIKey, "RENDER_TIMEOUT": "5s"}, loaded{9100, 500, 400, 8, testAPIKey, fonts.dir, "/srv/assets", 5 * time.Second}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for name, value := range test.env {
+				t.Setenv(name, value)
+			}
+
+			config := LoadConfig()
+			got := loaded{config.Port, config.MaxWidthPx, config.MaxHeightPx, config.ImageCacheSize, config.APIKey, config.FontDir, config.AssetDir, time.Duration(config.RenderTimeout)}
+			if got != test.want {
+				t.Fatalf("config %+v, want %+v", got, test.want)
+			}
+
+			// And the server runs with them
+			config.APIKey = testAPIKey
+			server := httptest.NewServer(NewServer(config).Handler())
+			defer server.Close()
+
+			response := getURL(t, server.URL+"/font-faces")
+			var listing struct {
+				FontFaces []string `json:"fontFaces"`
+			}
+			if err := json.Unmarshal(readBody(t, response), &listing); err != nil {
+				t.Fatal(err)
+			}
+
+			if !slices.Equal(listing.FontFaces, fonts.faces()) {
+				t.Errorf("fonts %v, want %v", listing.FontFaces, fonts.faces())
+			}
+
+			response = postJSON(t, server.URL+"/generate", ImgRequest{WidthPx: 501, HeightPx: 10, BgColor: red})
+			if body := readBody(t, response); response.StatusCode != 400 {
+				t.Errorf("status %d past maxWidthPx, want 400: %s", response.StatusCode, body)
+			}
+		})
+	}
+}
+
+// authStatus is the status of a request to /font-faces with key
+func authStatus(t *testing.T, server *httptest.Server, key string) int {
+	t.Helper()
+
+	request, err := http.NewRequest(http.MethodGet, server.URL+"/font-faces", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Authorization", key)
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readBody(t, response)
+
+	return response.StatusCode
+}
+
+func TestAPIKeyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api_key")
+	writeTestFile(t, path, []byte("from-secret\r\n"))
+
+	for _, name := range []string{"CONFIG_FILE", "API_KEYS", "API_KEYS_FILE"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("API_KEY", "from-env")
+	t.Setenv("API_KEY_FILE", path)
+
+	config := LoadConfig()
+	if config.APIKey != "from-secret" {
+		t.Fatalf("API key %q, want the trimmed file contents", config.APIKey)
+	}
+
+	server := newTestServer(t, config)
+
+	tests := []struct {
+		key    string
+		status int
+	}{
+		{"from-secret", 200},
+		{"from-env", 401},
+		{"", 401},
+	}
+
+	for _, test := range tests {
+		if got := authStatus(t, server, test.key); got != test.status {
+			t.Errorf("key %q: status %d, want %d", test.key, got, test.status)
+		}
+	}
+}
+
+func TestAPIKeyRotation(t *testing.T) {
+	keysFile := filepath.Join(t.TempDir(), "api_keys")
+	writeTestFile(t, keysFile, []byte("old-key\n\nnew-key\n"))
+
+	tests := []struct {
+		name string
+		env  map[string]string
+	}{
+		{"list", map[string]string{"API_KEYS": "old-key, new-key"}},
+		{"file", map[string]string{"API_KEYS_FILE": keysFile}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, name := range []string{"CONFIG_FILE", "API_KEY_FILE", "API_KEYS", "API_KEYS_FILE"} {
+				t.Setenv(name, "")
+			}
+			// Only used when no list is configured
+			t.Setenv("API_KEY", "single-key")
+			for name, value := range test.env {
+				t.Setenv(name, value)
+			}
+
+			server := newTestServer(t, LoadConfig())
+
+			for key, status := range map[string]int{"old-key": 200, "new-key": 200, "single-key": 401, "third-key": 401} {
+				if got := authStatus(t, server, key); got != status {
+					t.Errorf("key %q: status %d, want %d", key, got, status)
+				}
+			}
+		})
+	}
+}
+
+func TestConcurrentFontReload(t *testing.T) {
+	fonts := newTestFonts(t)
+	server := newTestServer(t, Config{FontDir: fonts.dir})
+
+	requests := []struct {
+		path string
+		body any
+	}{
+		{"/font-faces/reload", map[string]any{}},
+		{"/generate", map[string]any{
+			"widthPx":         60,
+			"heightPx":        30,
+			"singleLineTexts": []map[string]any{{"text": "Hi", "font": fonts.regular, "position": map[string]any{"x": 5, "y": 20}}},
+		}},
+	}
+
+	var wait sync.WaitGroup
+	for _, request := range requests {
+		for range 4 {
+			wait.Add(1)
+			go func() {
+				defer wait.Done()
+
+				for range 5 {
+					response := postJSON(t, server.URL+request.path, request.body)
+					if body := readBody(t, response); response.StatusCode != 200 {
+						t.Errorf("%s: status %d: %s", request.path, response.StatusCode, body)
+					}
+				}
+			}()
+		}
+	}
+
+	wait.Wait()
+}
+
+func TestGenerateFromQuery(t *testing.T) {
+	server := newTestServer(t, Config{MaxWidthPx: 100})
+	body := map[string]any{"widthPx": 30, "heightPx": 20, "bgColor": red}
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	posted := readBody(t, postJSON(t, server.URL+"/generate", body))
+
+	tests := []struct {
+		name    string
+		request string
+		status  int
+	}{
+		{"unpadded", base64.RawURLEncoding.EncodeToString(data), 200},
+		{"padded", base64.URLEncoding.EncodeToString(data), 200},
+		{"too wide", base64.RawURLEncoding.EncodeToString([]byte(`{"widthPx": 200, "heightPx": 20}`)), 400},
+		{"not base64", "not*base64", 400},
+		{"not json", base64.RawURLEncoding.EncodeToString([]byte("widthPx=30")), 400},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := getURL(t, server.URL+"/generate?request="+url.QueryEscape(test.request))
+			got := readBody(t, response)
+			if response.StatusCode != test.status {
+				t.Fatalf("status %d, want %d: %s", response.StatusCode, test.status, got)
+			}
+
+			if test.status != 200 {
+				return
+			}
+
+			if contentType := response.Header.Get("Content-Type"); contentType != "image/png" {
+				t.Errorf("content type %q, want image/png", contentType)
+			}
+
+			// The same image as the POST of the same JSON
+			if !bytes.Equal(got, posted) {
+				t.Error("image differs from the POSTed request")
+			}
+
+			if img := decodeImage(t, got); pixel(img, 15, 10) != nrgba(red) || img.Bounds().Dx() != 30 {
+				t.Errorf("got a %v image of %v, want 30px of red", img.Bounds().Size(), pixel(img, 15, 10))
+			}
+		})
+	}
+
+	// The key is only read from the Authorization header, never the query
+	response, err := http.Get(server.URL + "/generate?key=" + testAPIKey + "&request=" + base64.RawURLEncoding.EncodeToString(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readBody(t, response); response.StatusCode != 401 {
+		t.Errorf("query key: status %d, want 401: %s", response.StatusCode, got)
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+	"github.com/fogleman/gg"
+)
+
+type ProgressBar struct {
+	Anchoring
+	Position       Position `json:"position"`
+	WidthPx        float64  `json:"widthPx"`
+	HeightPx       float64  `json:"heightPx"`
+	Progress       float64  `json:"progress"`
+	TrackColor     Color    `json:"trackColor"`
+	FillColor      Color    `json:"fillColor"`
+	CornerRadiusPx float64  `json:"cornerRadiusPx"`
+	Visibility
+}
+
+func DrawProgressBar(dc *gg.Context, bar ProgressBar) {
+	progress := math.Max(0, math.Min(1, bar.Progress))
+
+	dc.DrawRoundedRectangle(bar.Position.X, bar.Position.Y, bar.WidthPx, bar.HeightPx, bar.CornerRadiusPx)
+	dc.SetColor(bar.TrackColor.ToRGBA())
+	dc.FillPreserve()
+
+	// Clip to the track so the fill keeps the rounded ends
+	dc.Clip()
+	defer dc.ResetClip()
+
+	dc.DrawRectangle(bar.Position.X, bar.Position.Y, bar.WidthPx*progress, bar.HeightPx)
+	dc.SetColor(bar.FillColor.ToRGBA())
+	dc.Fill()
+}
+
+type StarRating struct {
+	Position    Position `json:"position"`
+	Count       int      `json:"count" default:"5"`
+	Rating      float64  `json:"rating"`
+	SizePx      float64  `json:"sizePx"`
+	GapPx       float64  `json:"gapPx"`
+	FilledColor Color    `json:"filledColor"`
+	EmptyColor  Color    `json:"emptyColor"`
+	Visibility
+}
+
+// starPath traces a five-pointed star inscribed in the size x size box at x, y
+func starPath(dc *gg.Context, x, y, size float64) {
+	outer := size / 2
+	inner := outer * 0.382
+	cx, cy := x+outer, y+outer
+
+	dc.NewSubPath()
+	for i := 0; i < 10; i++ {
+		radius := outer
+		if i%2 == 1 {
+			radius = inner
+		}
+
+		// Start at the top point and walk clockwise
+		angle := -math.Pi/2 + float64(i)*math.Pi/5
+		dc.LineTo(cx+radius*math.Cos(angle), cy+radius*math.Sin(angle))
+	}
+	dc.ClosePath()
+}
+
+func DrawStarRating(dc *gg.Context, rating StarRating) {
+	count := rating.Count
+	if count == 0 {
+		count = 5
+	}
+
+	for i := 0; i < count; i++ {
+		x := rating.Position.X + float64(i)*(rating.SizePx+rating.GapPx)
+		y := rating.Position.Y
+		filled := math.Max(0, math.Min(1, rating.Rating-float64(i)))
+
+		starPath(dc, x, y, rating.SizePx)
+		dc.SetColor(rating.EmptyColor.ToRGBA())
+		dc.Fill()
+
+		if filled == 0 {
+			continue
+		}
+
+		// Fractional ratings fill the star from the left
+		starPath(dc, x, y, rating.SizePx)
+		dc.Clip()
+		dc.DrawRectangle(x, y, rating.SizePx*filled, rating.SizePx)
+		dc.SetColor(rating.FilledColor.ToRGBA())
+		dc.Fill()
+		dc.ResetClip()
+	}
+}
+
+// Curve is a cubic bezier, or a quadratic one when Control2 is omitted
+type Curve struct {
+	Start    Position  `json:"start"`
+	Control1 Position  `json:"control1"`
+	Control2 *Position `json:"control2"`
+	End      Position  `json:"end"`
+	Color    Color     `json:"color"`
+	WidthPx  float64   `json:"widthPx" default:"1"`
+	// Replaces Color when set
+	StrokeGradient *Gradient `json:"strokeGradient"`
+	Visibility
+}
+
+func DrawCurve(dc *gg.Context, curve Curve) {
+	width := curve.WidthPx
+	if width == 0 {
+		width = 1
+	}
+
+	dc.NewSubPath()
+	dc.MoveTo(curve.Start.X, curve.Start.Y)
+
+	if curve.Control2 != nil {
+		dc.CubicTo(curve.Control1.X, curve.Control1.Y, curve.Control2.X, curve.Control2.Y, curve.End.X, curve.End.Y)
+	} else {
+		dc.QuadraticTo(curve.Control1.X, curve.Control1.Y, curve.End.X, curve.End.Y)
+	}
+
+	if curve.StrokeGradient != nil {
+		dc.SetStrokeStyle(ScalePattern(curve.StrokeGradient.Pattern(), int(deviceScale(dc))))
+	} else {
+		dc.SetColor(curve.Color.ToRGBA())
+	}
+
+	dc.SetLineWidth(width)
+	dc.Stroke()
+}
+
+type Triangle struct {
+	Vertices      [3]Position `json:"vertices"`
+	FillColor     Color       `json:"fillColor"`
+	StrokeColor   Color       `json:"strokeColor"`
+	StrokeWidthPx float64     `json:"strokeWidthPx"`
+	BlendMode     BlendMode   `json:"blendMode" default:"normal"`
+	Visibility
+}
+
+func polygonPath(dc *gg.Context, points []Position) {
+	dc.NewSubPath()
+	for _, point := range points {
+		dc.LineTo(point.X, point.Y)
+	}
+	dc.ClosePath()
+}
+
+// fillAndStroke fills and then strokes the current path, skipping a zero fill
+// color or stroke width
+func fillAndStroke(dc *gg.Context, fill Color, stroke Color, strokeWidthPx float64) {
+	if fill != (Color{}) {
+		dc.SetColor(fill.ToRGBA())
+		dc.FillPreserve()
+	}
+
+	if strokeWidthPx > 0 {
+		dc.SetColor(stroke.ToRGBA())
+		dc.SetLineWidth(strokeWidthPx)
+		dc.StrokePreserve()
+	}
+
+	dc.ClearPath()
+}
+
+func DrawTriangle(dc *gg.Context, triangle Triangle) {
+	polygonPath(dc, triangle.Vertices[:])
+	fillAndStroke(dc, triangle.FillColor, triangle.StrokeColor, triangle.StrokeWidthPx)
+}
+
+// Border frames the whole canvas. CornerRadiusPx rounds the inner edge.
+type Border struct {
+	Color          Color   `json:"color"`
+	WidthPx        float64 `json:"widthPx"`
+	CornerRadiusPx float64 `json:"cornerRadiusPx"`
+	Visibility
+}
+
+func DrawBorder(dc *gg.Context, border Border, widthPx, heightPx float64) {
+	if border.WidthPx <= 0 {
+		return
+	}
+
+	// Even-odd fill of the canvas minus the inset box leaves just the frame
+	dc.DrawRectangle(0, 0, widthPx, heightPx)
+	dc.DrawRoundedRectangle(
+		border.WidthPx,
+		border.WidthPx,
+		widthPx-2*border.WidthPx,
+		heightPx-2*border.WidthPx,
+		border.CornerRadiusPx,
+	)
+
+	dc.SetFillRuleEvenOdd()
+	defer dc.SetFillRuleWinding()
+
+	dc.SetColor(border.Color.ToRGBA())
+	dc.Fill()
+}
+
+type Circle struct {
+	Anchoring
+	Center        Position  `json:"center"`
+	RadiusPx      float64   `json:"radiusPx"`
+	FillColor     Color     `json:"fillColor"`
+	StrokeColor   Color     `json:"strokeColor"`
+	StrokeWidthPx float64   `json:"strokeWidthPx"`
+	ShadowColor   Color     `json:"shadowColor"`
+	ShadowOffset  Position  `json:"shadowOffset"`
+	ShadowBlurPx  float64   `json:"shadowBlurPx"`
+	BlendMode     BlendMode `json:"blendMode" default:"normal"`
+	Visibility
+}
+
+func DrawCircle(dc *gg.Context, circle Circle) {
+	DrawShadow(dc, circle.ShadowColor, circle.ShadowOffset, circle.ShadowBlurPx, func(dc *gg.Context) {
+		dc.DrawCircle(circle.Center.X, circle.Center.Y, circle.RadiusPx)
+	})
+
+	dc.DrawCircle(circle.Center.X, circle.Center.Y, circle.RadiusPx)
+	fillAndStroke(dc, circle.FillColor, circle.StrokeColor, circle.StrokeWidthPx)
+}
+
+// DrawShadow fills the path traced by path on a scratch layer, shifted by
+// offset and blurred, then composites it under whatever is drawn next. A zero
+// color disables the shadow.
+func DrawShadow(dc *gg.Context, shadowColor Color, offset Position, blurPx float64, path func(dc *gg.Context)) {
+	if shadowColor == (Color{}) {
+		return
+	}
+
+	scale := deviceScale(dc)
+
+	layer := gg.NewContext(dc.Width(), dc.Height())
+	layer.Scale(scale, scale)
+	layer.Translate(offset.X, offset.Y)
+	path(layer)
+	layer.SetColor(shadowColor.ToRGBA())
+	layer.Fill()
+
+	var shadow image.Image = layer.Image()
+	if blurPx > 0 {
+		shadow = imaging.Blur(shadow, blurPx*scale)
+	}
+
+	dc.Push()
+	defer dc.Pop()
+	dc.Identity()
+	dc.DrawImage(shadow, 0, 0)
+}
@@ -0,0 +1,304 @@
+package main
+
+import "testing"
+
+func TestProgressBar(t *testing.T) {
+	tests := []struct {
+		name        string
+		progress    float64
+		left, right Color
+	}{
+		{"half", 0.5, green, red},
+		{"empty", 0, red, red},
+		{"clamped", 1.5, green, green},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := ImgRequest{
+				WidthPx:  220,
+				HeightPx: 40,
+				ProgressBars: []ProgressBar{{
+					Position:       Position{10, 10},
+					WidthPx:        200,
+					HeightPx:       20,
+					Progress:       test.progress,
+					TrackColor:     red,
+					FillColor:      green,
+					CornerRadiusPx: 10,
+				}},
+			}
+			img := renderRequest(t, request, nil)
+
+			for _, x := range []int{30, 60, 100} {
+				if got := pixel(img, x, 20); !closeTo(got, nrgba(test.left), 2) {
+					t.Errorf("left half at x=%d is %v, want %v", x, got, test.left)
+				}
+			}
+
+			for _, x := range []int{120, 160, 190} {
+				if got := pixel(img, x, 20); !closeTo(got, nrgba(test.right), 2) {
+					t.Errorf("right half at x=%d is %v, want %v", x, got, test.right)
+				}
+			}
+		})
+	}
+}
+
+func TestStarRating(t *testing.T) {
+	request := ImgRequest{
+		WidthPx:  260,
+		HeightPx: 60,
+		StarRatings: []StarRating{{
+			Position:    Position{10, 10},
+			Rating:      3.5,
+			SizePx:      40,
+			GapPx:       10,
+			FilledColor: red,
+			EmptyColor:  blue,
+		}},
+	}
+	img := renderRequest(t, request, nil)
+
+	// Star i is centered at 30 + 50i; the half star is sampled either side
+	// of its center
+	tests := []struct {
+		name string
+		x    int
+		want Color
+	}{
+		{"first", 30, red},
+		{"second", 80, red},
+		{"third", 130, red},
+		{"fourth left", 176, red},
+		{"fourth right", 184, blue},
+		{"fifth", 230, blue},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := pixel(img, test.x, 30); !closeTo(got, nrgba(test.want), 2) {
+				t.Errorf("pixel at x=%d is %v, want %v", test.x, got, test.want)
+			}
+		})
+	}
+}
+
+func TestCurveMidpoint(t *testing.T) {
+	control2 := Position{150, 10}
+
+	// (1-t)^3 p0 + 3(1-t)^2 t c1 + 3(1-t) t^2 c2 + t^3 p3 at t = 0.5, and
+	// (1-t)^2 p0 + 2(1-t) t c1 + t^2 p3 for the quadratic
+	tests := []struct {
+		name     string
+		control2 *Position
+		midpoint [2]int
+	}{
+		{"cubic", &control2, [2]int{100, 30}},
+		{"quadratic", nil, [2]int{75, 50}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := ImgRequest{
+				WidthPx:  200,
+				HeightPx: 100,
+				BgColor:  white,
+				Curves: []Curve{{
+					Start:    Position{10, 90},
+					Control1: Position{50, 10},
+					Control2: test.control2,
+					End:      Position{190, 90},
+					Color:    red,
+					WidthPx:  4,
+				}},
+			}
+			img := renderRequest(t, request, nil)
+
+			x, y := test.midpoint[0], test.midpoint[1]
+			if got := pixel(img, x, y); !closeTo(got, nrgba(red), 8) {
+				t.Errorf("midpoint (%d, %d) is %v, want %v", x, y, got, red)
+			}
+
+			if got := pixel(img, x, 95); got != nrgba(white) {
+				t.Errorf("below the curve is %v, want the background", got)
+			}
+		})
+	}
+}
+
+func TestTriangle(t *testing.T) {
+	// Right angle in the bottom-left corner, hypotenuse from (10, 10) to (90, 90)
+	request := ImgRequest{
+		WidthPx:   100,
+		HeightPx:  100,
+		BgColor:   white,
+		Triangles: []Triangle{{Vertices: [3]Position{{10, 10}, {10, 90}, {90, 90}}, FillColor: red}},
+	}
+	img := renderRequest(t, request, nil)
+
+	tests := []struct {
+		name string
+		x, y int
+		want Color
+	}{
+		{"inside", 30, 70, red},
+		{"inside near the hypotenuse", 45, 55, red},
+		{"outside near the hypotenuse", 55, 45, white},
+		{"outside", 70, 30, white},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := pixel(img, test.x, test.y); got != nrgba(test.want) {
+				t.Errorf("pixel (%d, %d) is %v, want %v", test.x, test.y, got, test.want)
+			}
+		})
+	}
+}
+
+func TestBorder(t *testing.T) {
+	request := ImgRequest{
+		WidthPx:  100,
+		HeightPx: 60,
+		BgColor:  white,
+		Border:   &Border{Color: red, WidthPx: 6},
+	}
+	img := renderRequest(t, request, nil)
+
+	tests := []struct {
+		name string
+		x, y int
+		want Color
+	}{
+		{"top", 50, 0, red},
+		{"top inner edge", 50, 5, red},
+		{"bottom", 50, 59, red},
+		{"left", 0, 30, red},
+		{"right inner edge", 94, 30, red},
+		{"corner", 99, 59, red},
+		{"inside top", 50, 6, white},
+		{"inside left", 6, 30, white},
+		{"inside right", 93, 30, white},
+		{"center", 50, 30, white},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := pixel(img, test.x, test.y); got != nrgba(test.want) {
+				t.Errorf("pixel (%d, %d) is %v, want %v", test.x, test.y, got, test.want)
+			}
+		})
+	}
+}
+
+func TestShapeShadow(t *testing.T) {
+	shadow := Color{40, 40, 40, 255}
+	offset := Position{12, 8}
+
+	// Both shapes cover 20..60 by 20..60, so their shadows extend to 72, 68
+	tests := []struct {
+		name    string
+		request ImgRequest
+	}{
+		{"rectangle", ImgRequest{Rectangles: []Rectangle{{Position: Position{20, 20}, WidthPx: 40, HeightPx: 40, Color: red, ShadowColor: shadow, ShadowOffset: offset}}}},
+		{"circle", ImgRequest{Circles: []Circle{{Center: Position{40, 40}, RadiusPx: 20, FillColor: red, ShadowColor: shadow, ShadowOffset: offset}}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.request.WidthPx, test.request.HeightPx, test.request.BgColor = 100, 100, white
+			img := renderRequest(t, test.request, nil)
+
+			// Past the right edge of the shape, inside the offset copy
+			if got := pixel(img, 66, 44); got != nrgba(shadow) {
+				t.Errorf("shadow at (66, 44) is %v, want %v", got, shadow)
+			}
+
+			// The shadow doesn't reach above and left of the shape
+			if got := pixel(img, 25, 14); got != nrgba(white) {
+				t.Errorf("pixel at (25, 14) is %v, want the background", got)
+			}
+
+			if got := pixel(img, 76, 44); got != nrgba(white) {
+				t.Errorf("pixel past the shadow is %v, want the background", got)
+			}
+		})
+	}
+}
+
+// gridBoxes lays count 20x20 boxes out 30px apart, 25 to a row, so their
+// outlines never touch
+func gridBoxes(count int) []RectangleBox {
+	boxes := make([]RectangleBox, count)
+	for i := range boxes {
+		boxes[i] = RectangleBox{Position{float64(10 + i%25*30), float64(10 + i/25*30)}, 20, 20}
+	}
+
+	return boxes
+}
+
+// rectangleRequests draws boxes once as separate rectangles and once as a group
+func rectangleRequests(boxes []RectangleBox, rectColor Color, fill *Gradient) (ImgRequest, ImgRequest) {
+	separate := ImgRequest{WidthPx: 760, HeightPx: 620, BgColor: white}
+	for _, box := range boxes {
+		separate.Rectangles = append(separate.Rectangles, Rectangle{Position: box.Position, WidthPx: box.WidthPx, HeightPx: box.HeightPx, Color: rectColor, FillGradient: fill})
+	}
+
+	grouped := ImgRequest{WidthPx: 760, HeightPx: 620, BgColor: white}
+	grouped.RectangleGroups = []RectangleGroup{{Rectangles: boxes, Color: rectColor, FillGradient: fill}}
+
+	return separate, grouped
+}
+
+func TestRectangleGroupMatchesRectangles(t *testing.T) {
+	fill := &Gradient{Start: Position{0, 0}, End: Position{760, 620}, Stops: []ColorStop{{0, red}, {1, blue}}}
+
+	tests := []struct {
+		name string
+		fill *Gradient
+	}{
+		{"outlines", nil},
+		{"gradient fill", fill},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			separate, grouped := rectangleRequests(gridBoxes(500), green, test.fill)
+			want, got := renderRequest(t, separate, nil), renderRequest(t, grouped, nil)
+
+			for y := 0; y < 620; y++ {
+				for x := 0; x < 760; x++ {
+					if pixel(got, x, y) != pixel(want, x, y) {
+						t.Fatalf("pixel (%d, %d) is %v grouped and %v separate", x, y, pixel(got, x, y), pixel(want, x, y))
+					}
+				}
+			}
+
+			// The last box is drawn too
+			if got := pixel(got, 490, 580); got != nrgba(green) {
+				t.Errorf("outline of the last box is %v, want %v", got, green)
+			}
+		})
+	}
+}
+
+func BenchmarkRectangleGroup(b *testing.B) {
+	separate, grouped := rectangleRequests(gridBoxes(500), green, nil)
+
+	for _, bench := range []struct {
+		name    string
+		request ImgRequest
+	}{{"separate", separate}, {"grouped", grouped}} {
+		b.Run(bench.name, func(b *testing.B) {
+			request := bench.request
+			if err := PrepareRequest(&request, nil, nil, testDefaults); err != nil {
+				b.Fatal(err)
+			}
+
+			for i := 0; i < b.N; i++ {
+				ComposeImage(request)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+var shortcodePattern = regexp.MustCompile(`:([a-z0-9_+-]+):`)
+
+// Common GitHub/Slack style shortcodes
+var shortcodes = map[string]string{
+	"smile": "😄", "grin": "😁", "joy": "😂", "laughing": "😆", "wink": "😉",
+	"blush": "😊", "heart_eyes": "😍", "sunglasses": "😎", "thinking": "🤔", "neutral_face": "😐",
+	"cry": "😢", "sob": "😭", "angry": "😠", "scream": "😱", "sleeping": "😴",
+	"heart": "❤️", "broken_heart": "💔", "sparkling_heart": "💖", "star": "⭐", "sparkles": "✨",
+	"fire": "🔥", "zap": "⚡", "boom": "💥", "100": "💯", "tada": "🎉",
+	"+1": "👍", "thumbsup": "👍", "-1": "👎", "thumbsdown": "👎", "clap": "👏",
+	"wave": "👋", "pray": "🙏", "muscle": "💪", "ok_hand": "👌", "point_right": "👉",
+	"eyes": "👀", "rocket": "🚀", "trophy": "🏆", "gift": "🎁", "bulb": "💡",
+	"memo": "📝", "book": "📖", "calendar": "📅", "pushpin": "📌", "link": "🔗",
+	"lock": "🔒", "key": "🔑", "bell": "🔔", "warning": "⚠️", "x": "❌",
+	"white_check_mark": "✅", "heavy_check_mark": "✔️", "question": "❓", "exclamation": "❗", "coffee": "☕",
+	"pizza": "🍕", "cake": "🍰", "beer": "🍺", "sun": "☀️", "cloud": "☁️",
+	"umbrella": "☔", "snowflake": "❄️", "rainbow": "🌈", "earth_americas": "🌎", "moon": "🌙",
+	"dog": "🐶", "cat": "🐱", "bug": "🐛", "penguin": "🐧", "tree": "🌳",
+	"computer": "💻", "iphone": "📱", "camera": "📷", "email": "📧", "chart_with_upwards_trend": "📈",
+}
+
+// ExpandShortcodes replaces :name: tokens with their emoji. Unknown names are
+// left as written. Glyphs are drawn as plain outlines, never in color, so the
+// emoji presentation selector U+FE0F is dropped rather than drawn as a
+// missing glyph.
+func ExpandShortcodes(text string) string {
+	return shortcodePattern.ReplaceAllStringFunc(text, func(token string) string {
+		if emoji, ok := shortcodes[token[1:len(token)-1]]; ok {
+			return strings.ReplaceAll(emoji, "\uFE0F", "")
+		}
+
+		return token
+	})
+}
+
+// applyShortcodes expands the texts that opt in with shortcodes
+func applyShortcodes(request *ImgRequest) {
+	expand := func(enabled bool, text *string) {
+		if enabled {
+			*text = ExpandShortcodes(*text)
+		}
+	}
+
+	for i := range request.SingleLineTexts {
+		text := &request.SingleLineTexts[i]
+		expand(text.Shortcodes, &text.Text)
+	}
+
+	for i := range request.MultiLineTexts {
+		text := &request.MultiLineTexts[i]
+		expand(text.Shortcodes, &text.Text)
+	}
+
+	for i := range request.TextStacks {
+		for j := range request.TextStacks[i].Items {
+			item := &request.TextStacks[i].Items[j]
+			expand(item.Shortcodes, &item.Text)
+		}
+	}
+
+	for i := range request.RichTexts {
+		for j := range request.RichTexts[i].Spans {
+			expand(request.RichTexts[i].Shortcodes, &request.RichTexts[i].Spans[j].Text)
+		}
+	}
+
+	for i := range request.Markdowns {
+		expand(request.Markdowns[i].Shortcodes, &request.Markdowns[i].Text)
+	}
+
+	for i := range request.Callouts {
+		expand(request.Callouts[i].Shortcodes, &request.Callouts[i].Text)
+	}
+}
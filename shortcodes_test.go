@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+func TestExpandShortcodes(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"I :heart: Go", "I ❤ Go"},
+		{":+1: :tada:", "👍 🎉"},
+		{":not_a_shortcode:", ":not_a_shortcode:"},
+		{"10:30:00", "10:30:00"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			if got := ExpandShortcodes(test.text); got != test.want {
+				t.Errorf("ExpandShortcodes(%q) = %q, want %q", test.text, got, test.want)
+			}
+		})
+	}
+}
+
+func TestShortcodeRenders(t *testing.T) {
+	path := testdataFont(t, t.TempDir(), "DejaVu", "DejaVuSansCondensed.ttf")
+
+	render := func(text string, shortcodes bool) []byte {
+		request := ImgRequest{
+			WidthPx:         200,
+			HeightPx:        60,
+			BgColor:         white,
+			SingleLineTexts: []StyledText{{Text: text, Font: path, SizePx: pixels(40), Color: black, Position: textPosition(Position{10, 45}), Shortcodes: shortcodes}},
+		}
+
+		return generateRequest(t, request, []string{path})
+	}
+
+	heart := render("❤", false)
+	if got := inkBounds(decodeImage(t, heart)); got.Dx() < 10 || got.Dy() < 10 {
+		t.Fatalf("heart ink is %v, want a glyph", got)
+	}
+
+	if !bytes.Equal(render(":heart:", true), heart) {
+		t.Error(":heart: does not render like the heart emoji")
+	}
+
+	if bytes.Equal(render(":heart:", false), heart) {
+		t.Error(":heart: expanded without shortcodes")
+	}
+}
+
+func TestShortcodesEveryText(t *testing.T) {
+	request := ImgRequest{
+		SingleLineTexts: []StyledText{{Text: ":fire:", Shortcodes: true}},
+		MultiLineTexts:  []MultiLineText{{StyledText: StyledText{Text: ":fire:", Shortcodes: true}}},
+		TextStacks:      []TextStack{{Items: []TextStackItem{{StyledText: StyledText{Text: ":fire:", Shortcodes: true}}}}},
+		RichTexts:       []RichText{{Spans: []TextSpan{{Text: ":fire:"}, {Text: "and :fire:"}}, Shortcodes: true}},
+		Markdowns:       []Markdown{{Text: "# :fire:", Shortcodes: true}},
+		Callouts:        []Callout{{Text: ":fire:", Shortcodes: true}, {Text: ":fire:"}},
+	}
+	applyShortcodes(&request)
+
+	got := []string{
+		request.SingleLineTexts[0].Text,
+		request.MultiLineTexts[0].Text,
+		request.TextStacks[0].Items[0].Text,
+		request.RichTexts[0].Spans[0].Text,
+		request.RichTexts[0].Spans[1].Text,
+		request.Markdowns[0].Text,
+		request.Callouts[0].Text,
+		request.Callouts[1].Text,
+	}
+	want := []string{"🔥", "🔥", "🔥", "🔥", "and 🔥", "# 🔥", "🔥", ":fire:"}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("expanded %q, want %q", got, want)
+	}
+}
+
+func TestShortcodesWithHighlights(t *testing.T) {
+	fonts := newTestFonts(t)
+	request := ImgRequest{
+		WidthPx:  100,
+		HeightPx: 40,
+		SingleLineTexts: []StyledText{{
+			Text:       "I :heart: Go",
+			Font:       fonts.regular,
+			Shortcodes: true,
+			Highlights: []HighlightSpan{{Start: 10, End: 12, Color: red}},
+		}},
+	}
+
+	if err := PrepareRequest(&request, fonts.faces(), nil, testDefaults); err == nil {
+		t.Error("shortcodes with highlights were accepted")
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContentSignature is the hex HMAC-SHA256 of data under key. Consumers holding
+// the key recompute it over the body, after undoing any transfer compression,
+// and compare it to the X-Content-Signature header.
+func ContentSignature(key string, data []byte) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(data)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// signResponse sets X-Content-Signature for data when a signature key is
+// configured
+func (server *Server) signResponse(c *gin.Context, data []byte) {
+	if server.config.SignatureKey != "" {
+		c.Header("X-Content-Signature", ContentSignature(server.config.SignatureKey, data))
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func TestContentSignature(t *testing.T) {
+	const key = "signing-secret"
+	body := map[string]any{"widthPx": 20, "heightPx": 20, "bgColor": red}
+
+	// verify recomputes the signature the way a consumer would
+	verify := func(key string, data []byte, signature string) bool {
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write(data)
+
+		decoded, err := hex.DecodeString(signature)
+		return err == nil && hmac.Equal(decoded, mac.Sum(nil))
+	}
+
+	response := postJSON(t, newTestServer(t, Config{SignatureKey: key}).URL+"/generate", body)
+	data := readBody(t, response)
+	if response.StatusCode != 200 {
+		t.Fatalf("status %d: %s", response.StatusCode, data)
+	}
+
+	signature := response.Header.Get("X-Content-Signature")
+	tampered := append([]byte{}, data...)
+	tampered[len(tampered)/2] ^= 0xff
+
+	tests := []struct {
+		name string
+		key  string
+		data []byte
+		ok   bool
+	}{
+		{"bytes and key", key, data, true},
+		{"altered bytes", key, tampered, false},
+		{"other key", "other-secret", data, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := verify(test.key, test.data, signature); got != test.ok {
+				t.Errorf("signature %q verifies %v, want %v", signature, got, test.ok)
+			}
+		})
+	}
+
+	// Unsigned without a key
+	response = postJSON(t, newTestServer(t, Config{}).URL+"/generate", body)
+	readBody(t, response)
+	if got := response.Header.Get("X-Content-Signature"); got != "" {
+		t.Errorf("signature %q without a key", got)
+	}
+}
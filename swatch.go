@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"image"
+	"slices"
+
+	"github.com/fogleman/gg"
+)
+
+type Swatch struct {
+	Color Color  `json:"color"`
+	Label string `json:"label"` // shown above the hex code
+}
+
+// SwatchRequest renders a palette as a grid of squares, one per color. The
+// canvas is sized to fit the grid. Labels are only drawn when Font is set.
+type SwatchRequest struct {
+	Colors       []Swatch    `json:"colors" binding:"required"`
+	Columns      int         `json:"columns" default:"4"`
+	SwatchSizePx float64     `json:"swatchSizePx" default:"120"`
+	GapPx        float64     `json:"gapPx" default:"16"`
+	Font         string      `json:"font"`
+	SizePx       float64     `json:"sizePx" default:"14"`
+	BgColor      Color       `json:"bgColor" default:"white"`
+	Format       ImageFormat `json:"format" default:"png"`
+	Quality      int         `json:"quality"`
+}
+
+func (request *SwatchRequest) applyDefaults() {
+	if request.Columns == 0 {
+		request.Columns = 4
+	}
+
+	if request.SwatchSizePx == 0 {
+		request.SwatchSizePx = 120
+	}
+
+	if request.GapPx == 0 {
+		request.GapPx = 16
+	}
+
+	if request.SizePx == 0 {
+		request.SizePx = 14
+	}
+
+	if request.BgColor == (Color{}) {
+		request.BgColor = Color{255, 255, 255, 255}
+	}
+
+	if request.Format == "" {
+		request.Format = PNG
+	}
+}
+
+// Prepare applies the defaults and validates the request
+func (request *SwatchRequest) Prepare(fontFaces []string) error {
+	request.applyDefaults()
+
+	if len(request.Colors) == 0 || len(request.Colors) > 256 {
+		return errors.New("colors must have between 1 and 256 entries")
+	}
+
+	if request.Columns < 0 || request.SwatchSizePx < 0 || request.GapPx < 0 || request.SizePx < 0 {
+		return errors.New("columns and sizes must be positive")
+	}
+
+	if request.Font != "" && !slices.Contains(fontFaces, request.Font) {
+		return errFontNotFound
+	}
+
+	if _, ok := ContentType(request.Format); !ok {
+		return errors.New("Unsupported format")
+	}
+
+	if request.Format == AVIF && !avifAvailable {
+		return errAVIFUnavailable
+	}
+
+	return nil
+}
+
+// labelHeightPx is the room below each swatch for its label and hex code
+func (request SwatchRequest) labelHeightPx() float64 {
+	if request.Font == "" {
+		return 0
+	}
+
+	return request.SizePx * 3
+}
+
+// Size is the canvas size that fits the grid
+func (request SwatchRequest) Size() (int, int) {
+	columns := min(request.Columns, len(request.Colors))
+	rows := (len(request.Colors) + columns - 1) / columns
+
+	width := request.GapPx + float64(columns)*(request.SwatchSizePx+request.GapPx)
+	height := request.GapPx + float64(rows)*(request.SwatchSizePx+request.labelHeightPx()+request.GapPx)
+
+	return int(width), int(height)
+}
+
+func RenderSwatches(request SwatchRequest) image.Image {
+	width, height := request.Size()
+	dc := gg.NewContext(width, height)
+	dc.SetColor(request.BgColor.ToRGBA())
+	dc.Clear()
+
+	cell := request.SwatchSizePx + request.GapPx
+	rowHeight := cell + request.labelHeightPx()
+	labelColor := Color{60, 60, 60, 255}
+
+	for i, swatch := range request.Colors {
+		x := request.GapPx + float64(i%request.Columns)*cell
+		y := request.GapPx + float64(i/request.Columns)*rowHeight
+
+		dc.DrawRectangle(x, y, request.SwatchSizePx, request.SwatchSizePx)
+		dc.SetColor(swatch.Color.ToRGBA())
+		dc.Fill()
+
+		if request.Font == "" {
+			continue
+		}
+
+		lines := []string{swatch.Color.Hex()}
+		if swatch.Label != "" {
+			lines = []string{swatch.Label, swatch.Color.Hex()}
+		}
+
+		for j, line := range lines {
+			text := StyledText{
+				Text:     line,
+				Font:     request.Font,
+				SizePx:   pixels(request.SizePx),
+				Color:    labelColor,
+				Position: textPosition(Position{x, y + request.SwatchSizePx + request.SizePx*float64(j+1)*1.3}),
+			}
+
+			DrawText(dc, text, func(dc *gg.Context, scale float64) {
+				dc.DrawString(TruncateText(dc, text.Text, request.SwatchSizePx*scale), text.Position.X.Value*scale, text.Position.Y.Value*scale)
+			})
+		}
+	}
+
+	return dc.Image()
+}
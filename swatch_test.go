@@ -0,0 +1,58 @@
+package main
+
+import (
+	"image"
+	"testing"
+)
+
+func TestSwatches(t *testing.T) {
+	fonts := newTestFonts(t)
+	server := newTestServer(t, Config{FontDir: fonts.dir})
+	yellow := Color{255, 220, 0, 255}
+	colors := []map[string]any{{"color": red}, {"color": green}, {"color": blue}, {"color": yellow, "label": "Sun"}}
+
+	tests := []struct {
+		name    string
+		body    map[string]any
+		size    image.Point
+		centers [4]image.Point
+	}{
+		{"one row", map[string]any{"colors": colors, "swatchSizePx": 40, "gapPx": 10}, image.Pt(210, 60), [4]image.Point{{30, 30}, {80, 30}, {130, 30}, {180, 30}}},
+		{"two columns", map[string]any{"colors": colors, "columns": 2, "swatchSizePx": 40, "gapPx": 10}, image.Pt(110, 110), [4]image.Point{{30, 30}, {80, 30}, {30, 80}, {80, 80}}},
+		{"labels", map[string]any{"colors": colors, "swatchSizePx": 40, "gapPx": 10, "font": fonts.regular, "sizePx": 10}, image.Pt(210, 90), [4]image.Point{{30, 30}, {80, 30}, {130, 30}, {180, 30}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := postJSON(t, server.URL+"/swatch", test.body)
+			body := readBody(t, response)
+			if response.StatusCode != 200 {
+				t.Fatalf("status %d: %s", response.StatusCode, body)
+			}
+
+			img := decodeImage(t, body)
+			if got := img.Bounds().Size(); got != test.size {
+				t.Fatalf("canvas is %v, want %v", got, test.size)
+			}
+
+			for i, want := range []Color{red, green, blue, yellow} {
+				center := test.centers[i]
+				if got := pixel(img, center.X, center.Y); got != nrgba(want) {
+					t.Errorf("swatch %d is %v, want %v", i, got, want)
+				}
+
+				// The gap to the left of every swatch is background
+				if got := pixel(img, center.X-25, center.Y); got != nrgba(white) {
+					t.Errorf("gap before swatch %d is %v, want white", i, got)
+				}
+			}
+
+			if _, labeled := test.body["font"]; labeled {
+				label := inkIn(img, image.Rect(10, 50, 50, 90))
+				if label.Empty() {
+					t.Error("no label below the first swatch")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/disintegration/imaging"
+	"github.com/fogleman/gg"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+type FontHinting string
+
+const (
+	HintingNone     FontHinting = "none"
+	HintingVertical FontHinting = "vertical"
+	HintingFull     FontHinting = "full"
+)
+
+var fontHintings = map[FontHinting]font.Hinting{
+	"":              font.HintingNone,
+	HintingNone:     font.HintingNone,
+	HintingVertical: font.HintingVertical,
+	HintingFull:     font.HintingFull,
+}
+
+// LoadFontFace builds the face through opentype instead of gg.LoadFontFace so
+// the hinting mode can be chosen per text element.
+func LoadFontFace(path string, sizePx float64, hinting FontHinting) (font.Face, error) {
+	fontHinting, ok := fontHintings[hinting]
+	if !ok {
+		return nil, fmt.Errorf("unsupported hinting %q", hinting)
+	}
+
+	fontBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := opentype.Parse(fontBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	// 72 DPI makes the point size equal to the pixel size
+	return opentype.NewFace(parsed, &opentype.FaceOptions{Size: sizePx, DPI: 72, Hinting: fontHinting})
+}
+
+// loadFace loads path for text at sizePx with the hinting, axes and kerning of
+// text
+func (text StyledText) loadFace(path string, sizePx float64) (font.Face, error) {
+	face, err := LoadStyledFontFace(path, sizePx, text.Hinting, text.Axes)
+	if err != nil || text.Kerning == nil || *text.Kerning {
+		return face, err
+	}
+
+	return unkernedFace{face}, nil
+}
+
+// unkernedFace drops the kern pairs of a face. Drawing, measuring and
+// wrapping all ask the face, so they stay consistent with each other.
+type unkernedFace struct {
+	font.Face
+}
+
+func (face unkernedFace) Kern(r0, r1 rune) fixed.Int26_6 {
+	return 0
+}
+
+// DrawText loads the face for text and runs draw with the face and color set.
+// Non-antialiased text is drawn on a separate layer and thresholded so only
+// the text color and the untouched background remain.
+//
+// gg scales glyph bitmaps instead of rasterizing them at the transformed
+// size, so on a supersampled canvas the face is loaded at the device size and
+// draw runs with an identity matrix. draw must multiply its coordinates by
+// scale.
+func DrawText(dc *gg.Context, text StyledText, draw func(dc *gg.Context, scale float64)) {
+	scale := deviceScale(dc)
+
+	fontFace, fontFaceErr := text.loadFace(text.Font, text.SizePx.Value*scale)
+	if fontFaceErr != nil {
+		panic(fontFaceErr)
+	}
+
+	dc.Push()
+	defer dc.Pop()
+	dc.Identity()
+
+	textColor := color.RGBA{text.Color.R, text.Color.G, text.Color.B, text.Color.A}
+
+	if text.AutoContrast {
+		textColor = contrastingColor(dc, fontFace, func(dc *gg.Context) { draw(dc, scale) })
+	}
+
+	for _, shadow := range text.Shadows {
+		drawTextShadow(dc, fontFace, shadow, scale, draw)
+	}
+
+	if text.Antialias == nil || *text.Antialias {
+		dc.SetFontFace(fontFace)
+		dc.SetColor(textColor)
+		draw(dc, scale)
+		return
+	}
+
+	layer := gg.NewContext(dc.Width(), dc.Height())
+	layer.SetFontFace(fontFace)
+	layer.SetColor(textColor)
+	draw(layer, scale)
+
+	threshold(layer.Image().(*image.RGBA), textColor)
+	dc.DrawImage(layer.Image(), 0, 0)
+}
+
+type Shadow struct {
+	Color  Color    `json:"color"`
+	Offset Position `json:"offset"`
+	BlurPx float64  `json:"blurPx"`
+}
+
+// drawTextShadow draws the text in the shadow color on a layer shifted by the
+// shadow offset, blurs it and composites it onto dc
+func drawTextShadow(dc *gg.Context, fontFace font.Face, shadow Shadow, scale float64, draw func(dc *gg.Context, scale float64)) {
+	layer := gg.NewContext(dc.Width(), dc.Height())
+	layer.Translate(shadow.Offset.X*scale, shadow.Offset.Y*scale)
+	layer.SetFontFace(fontFace)
+	layer.SetColor(shadow.Color.ToRGBA())
+	draw(layer, scale)
+
+	var img image.Image = layer.Image()
+	if shadow.BlurPx > 0 {
+		img = imaging.Blur(img, shadow.BlurPx*scale)
+	}
+
+	dc.DrawImage(img, 0, 0)
+}
+
+// deviceScale is the horizontal scale of the current transform, which is the
+// supersampling factor while drawing text
+func deviceScale(dc *gg.Context) float64 {
+	x0, _ := dc.TransformPoint(0, 0)
+	x1, _ := dc.TransformPoint(1, 0)
+
+	return x1 - x0
+}
+
+// threshold snaps every pixel of img to either fill or fully transparent
+func threshold(img *image.RGBA, fill color.RGBA) {
+	cutoff := fill.A / 2
+
+	for i := 0; i < len(img.Pix); i += 4 {
+		if img.Pix[i+3] > cutoff {
+			img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = fill.R, fill.G, fill.B, fill.A
+		} else {
+			img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = 0, 0, 0, 0
+		}
+	}
+}
+
+// contrastingColor draws the text on a scratch layer to find the pixels it
+// covers, then picks black or white depending on the average luminance of the
+// canvas underneath. Sampling the actual coverage makes this work for image
+// and gradient backgrounds as well as flat colors.
+func contrastingColor(dc *gg.Context, fontFace font.Face, draw func(dc *gg.Context)) color.RGBA {
+	layer := gg.NewContext(dc.Width(), dc.Height())
+	layer.SetFontFace(fontFace)
+	layer.SetColor(color.Black)
+	draw(layer)
+
+	coverage := layer.Image().(*image.RGBA)
+	canvas := dc.Image()
+
+	var total float64
+	var count int
+
+	for y := 0; y < coverage.Rect.Dy(); y++ {
+		for x := 0; x < coverage.Rect.Dx(); x++ {
+			if coverage.Pix[coverage.PixOffset(x, y)+3] == 0 {
+				continue
+			}
+
+			r, g, b, _ := canvas.At(x, y).RGBA()
+			total += (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 0xffff
+			count++
+		}
+	}
+
+	if count > 0 && total/float64(count) > 0.5 {
+		return color.RGBA{0, 0, 0, 255}
+	}
+
+	return color.RGBA{255, 255, 255, 255}
+}
+
+// TruncateText trims runes from the end of text and appends an ellipsis until
+// it fits within maxWidthPx using the face set on dc
+func TruncateText(dc *gg.Context, text string, maxWidthPx float64) string {
+	if width, _ := dc.MeasureString(text); width <= maxWidthPx {
+		return text
+	}
+
+	runes := []rune(text)
+	return Ellipsize(dc, string(runes[:len(runes)-1]), maxWidthPx)
+}
+
+// Ellipsize appends an ellipsis to text, trimming runes from the end until the
+// result fits within maxWidthPx
+func Ellipsize(dc *gg.Context, text string, maxWidthPx float64) string {
+	runes := []rune(text)
+	for {
+		truncated := strings.TrimRightFunc(string(runes), unicode.IsSpace) + "…"
+		if width, _ := dc.MeasureString(truncated); width <= maxWidthPx || len(runes) == 0 {
+			return truncated
+		}
+
+		runes = runes[:len(runes)-1]
+	}
+}
+
+// LimitLines wraps text the way DrawStringWrapped does and keeps at most
+// maxLines lines, ending the last one in an ellipsis when text was cut
+func LimitLines(dc *gg.Context, text string, widthPx float64, maxLines int) string {
+	lines := dc.WordWrap(text, widthPx)
+	if maxLines <= 0 || len(lines) <= maxLines {
+		return text
+	}
+
+	lines = lines[:maxLines]
+	lines[maxLines-1] = Ellipsize(dc, lines[maxLines-1], widthPx)
+
+	return strings.Join(lines, "\n")
+}
+
+// lineOffsets returns the top of each of count lines relative to the top of a
+// box of boxHeight. Lines without a distribution sit step apart from the top.
+func lineOffsets(count int, lineHeight, step, boxHeight float64, distribution LineDistribution) []float64 {
+	contentHeight := float64(count-1)*step + lineHeight
+	start := 0.0
+
+	switch distribution {
+	case DistributeCenter:
+		start = (boxHeight - contentHeight) / 2
+	case DistributeBottom:
+		start = boxHeight - contentHeight
+	case DistributeSpaceBetween:
+		if count > 1 {
+			step = (boxHeight - lineHeight) / float64(count-1)
+		}
+	case DistributeSpaceEvenly:
+		gap := (boxHeight - float64(count)*lineHeight) / float64(count+1)
+		start, step = gap, lineHeight+gap
+	}
+
+	offsets := make([]float64, count)
+	for i := range offsets {
+		offsets[i] = start + float64(i)*step
+	}
+
+	return offsets
+}
+
+// Wave shifts each glyph vertically along a sine of its distance from the
+// start of the text
+type Wave struct {
+	AmplitudePx  float64 `json:"amplitudePx"`
+	WavelengthPx float64 `json:"wavelengthPx" default:"100"`
+}
+
+// DrawWaveString draws text glyph by glyph from the baseline at x, y with each
+// glyph offset by the wave, which is scaled to device pixels like the text
+func DrawWaveString(dc *gg.Context, text string, x, y float64, wave Wave, scale float64) {
+	amplitude, wavelength := wave.AmplitudePx*scale, wave.WavelengthPx*scale
+	if wavelength == 0 {
+		wavelength = 100 * scale
+	}
+
+	runes := []rune(text)
+	for i, r := range runes {
+		// Measuring the whole prefix keeps the kerning of the plain string
+		offset, _ := dc.MeasureString(string(runes[:i]))
+		shift := amplitude * math.Sin(2*math.Pi*offset/wavelength)
+
+		dc.DrawString(string(r), x+offset, y+shift)
+	}
+}
+
+// DrawTabbedString draws text from the baseline at x, y with every tab
+// moving to the next stop, measured from x. Tabs past the last stop fall back
+// to a single space. Stops are in device pixels.
+func DrawTabbedString(dc *gg.Context, text string, x, y float64, tabStopsPx []float64) {
+	offset := 0.0
+
+	for i, part := range strings.Split(text, "\t") {
+		if i > 0 {
+			space, _ := dc.MeasureString(" ")
+			next := offset + space
+
+			for _, stop := range tabStopsPx {
+				if stop > offset {
+					next = stop
+					break
+				}
+			}
+
+			offset = next
+		}
+
+		dc.DrawString(part, x+offset, y)
+		width, _ := dc.MeasureString(part)
+		offset += width
+	}
+}
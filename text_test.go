@@ -0,0 +1,649 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/fogleman/gg"
+)
+
+// colorsOf counts the distinct colors in img
+func colorsOf(img image.Image) map[color.NRGBA]int {
+	colors := map[color.NRGBA]int{}
+	bounds := img.Bounds()
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			colors[pixel(img, x, y)]++
+		}
+	}
+
+	return colors
+}
+
+func TestTextAntialias(t *testing.T) {
+	fonts := newTestFonts(t)
+	off := false
+
+	tests := []struct {
+		name      string
+		antialias *bool
+		hardEdges bool
+	}{
+		{"default", nil, false},
+		{"off", &off, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := ImgRequest{
+				WidthPx:         200,
+				HeightPx:        60,
+				BgColor:         white,
+				SingleLineTexts: []StyledText{{Text: "Round edges", Font: fonts.regular, SizePx: pixels(32), Color: black, Position: textPosition(Position{10, 40}), Antialias: test.antialias}},
+			}
+			colors := colorsOf(renderRequest(t, request, fonts.faces()))
+
+			if colors[nrgba(black)] == 0 {
+				t.Fatal("no text color pixels")
+			}
+
+			if hardEdges := len(colors) == 2; hardEdges != test.hardEdges {
+				t.Errorf("%d distinct colors, want hard edges %v", len(colors), test.hardEdges)
+			}
+		})
+	}
+}
+
+func TestTextAutoContrast(t *testing.T) {
+	fonts := newTestFonts(t)
+	dark := Color{20, 20, 40, 255}
+	light := Color{230, 230, 210, 255}
+
+	tests := []struct {
+		name     string
+		bg       Color
+		gradient *Gradient
+		want     Color
+	}{
+		{"dark", dark, nil, white},
+		{"light", light, nil, black},
+		{"dark gradient", Color{}, &Gradient{Start: Position{0, 0}, End: Position{200, 0}, Stops: []ColorStop{{0, black}, {1, dark}}}, white},
+		{"light gradient", Color{}, &Gradient{Start: Position{0, 0}, End: Position{200, 0}, Stops: []ColorStop{{0, white}, {1, light}}}, black},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := ImgRequest{
+				WidthPx:         200,
+				HeightPx:        60,
+				BgColor:         test.bg,
+				BgGradient:      test.gradient,
+				SingleLineTexts: []StyledText{{Text: "Readable", Font: fonts.regular, SizePx: pixels(32), Color: red, Position: textPosition(Position{10, 40}), AutoContrast: true}},
+			}
+			colors := colorsOf(renderRequest(t, request, fonts.faces()))
+
+			if colors[nrgba(test.want)] == 0 {
+				t.Errorf("no %v text pixels", test.want)
+			}
+
+			if colors[nrgba(red)] > 0 {
+				t.Error("text kept its own color")
+			}
+		})
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	fonts := newTestFonts(t)
+	face, err := LoadFontFace(fonts.regular, 20, HintingNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer face.Close()
+
+	dc := gg.NewContext(1, 1)
+	dc.SetFontFace(face)
+
+	tests := []struct {
+		name      string
+		text      string
+		widthPx   float64
+		truncated bool
+	}{
+		{"fits", "Short", 200, false},
+		{"long", "A label far too long for its box", 120, true},
+		{"tiny", "Everything goes", 5, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := TruncateText(dc, test.text, test.widthPx)
+
+			if truncated := strings.HasSuffix(got, "…"); truncated != test.truncated {
+				t.Fatalf("TruncateText() = %q, want truncated %v", got, test.truncated)
+			}
+
+			if !test.truncated {
+				if got != test.text {
+					t.Errorf("TruncateText() = %q, want it unchanged", got)
+				}
+				return
+			}
+
+			// Only an ellipsis on its own may overflow
+			if width, _ := dc.MeasureString(got); width > test.widthPx && got != "…" {
+				t.Errorf("%q is %.1fpx wide, want at most %vpx", got, width, test.widthPx)
+			}
+		})
+	}
+}
+
+// lineTops lists the first row of every band of rows with ink in img. Gaps
+// of a few rows, like above the dot of an i, don't start a new band.
+func lineTops(img image.Image) []int {
+	tops := []int{}
+	background := pixel(img, 0, 0)
+	lastInk := -10
+
+	for y := 0; y < img.Bounds().Dy(); y++ {
+		ink := false
+		for x := 0; x < img.Bounds().Dx() && !ink; x++ {
+			ink = !closeTo(pixel(img, x, y), background, 8)
+		}
+
+		if !ink {
+			continue
+		}
+
+		if y-lastInk > 5 {
+			tops = append(tops, y)
+		}
+		lastInk = y
+	}
+
+	return tops
+}
+
+func TestLineSpacingMode(t *testing.T) {
+	fonts := newTestFonts(t)
+
+	face, err := LoadFontFace(fonts.regular, 20, HintingNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dc := gg.NewContext(1, 1)
+	dc.SetFontFace(face)
+	lineHeight := dc.FontHeight()
+	face.Close()
+
+	tests := []struct {
+		name     string
+		mode     LineSpacingMode
+		spacing  float64
+		distance float64
+	}{
+		{"absolute", Absolute, 30, 30},
+		{"absolute wide", Absolute, 45, 45},
+		{"multiple", Multiple, 2, 2 * lineHeight},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := ImgRequest{
+				WidthPx:  200,
+				HeightPx: 200,
+				BgColor:  white,
+				MultiLineTexts: []MultiLineText{{
+					StyledText:      StyledText{Text: "HHH\nHHH\nHHH", Font: fonts.regular, SizePx: pixels(20), Color: black, Position: textPosition(Position{10, 10})},
+					WrapWidthPx:     pixels(180),
+					LineSpacingPx:   test.spacing,
+					LineSpacingMode: test.mode,
+				}},
+			}
+			tops := lineTops(renderRequest(t, request, fonts.faces()))
+
+			if len(tops) != 3 {
+				t.Fatalf("%d lines at rows %v, want 3", len(tops), tops)
+			}
+
+			for i := 1; i < len(tops); i++ {
+				if distance := float64(tops[i] - tops[i-1]); math.Abs(distance-test.distance) > 1 {
+					t.Errorf("line %d is %vpx below the previous one, want %.1fpx", i, distance, test.distance)
+				}
+			}
+		})
+	}
+}
+
+func TestSupersampleSmoothsEdges(t *testing.T) {
+	fonts := newTestFonts(t)
+	off := false
+
+	// Without antialiasing only the downscale can produce in-between shades
+	render := func(supersample int) image.Image {
+		request := ImgRequest{
+			WidthPx:         200,
+			HeightPx:        60,
+			BgColor:         white,
+			Supersample:     supersample,
+			SingleLineTexts: []StyledText{{Text: "Smooth edges", Font: fonts.regular, SizePx: pixels(24), Color: black, Position: textPosition(Position{10, 40}), Antialias: &off}},
+		}
+
+		return renderRequest(t, request, fonts.faces())
+	}
+
+	direct, supersampled := render(1), render(3)
+
+	if direct.Bounds().Size() != supersampled.Bounds().Size() {
+		t.Fatalf("supersampled size %v, want %v", supersampled.Bounds().Size(), direct.Bounds().Size())
+	}
+
+	directShades, supersampledShades := len(colorsOf(direct)), len(colorsOf(supersampled))
+	if directShades != 2 {
+		t.Errorf("direct render has %d colors, want hard edges", directShades)
+	}
+
+	if supersampledShades < 10 {
+		t.Errorf("supersampled render has %d colors, want smooth edges", supersampledShades)
+	}
+}
+
+func TestTextShadows(t *testing.T) {
+	fonts := newTestFonts(t)
+
+	// A solid block of glyphs so the offsets land on full coverage
+	request := ImgRequest{
+		WidthPx:  200,
+		HeightPx: 100,
+		BgColor:  white,
+		SingleLineTexts: []StyledText{{
+			Text:     "█",
+			Font:     fonts.regular,
+			SizePx:   pixels(40),
+			Color:    black,
+			Position: textPosition(Position{20, 50}),
+			Shadows: []Shadow{
+				{Color: red, Offset: Position{60, 0}},
+				{Color: blue, Offset: Position{120, 0}},
+			},
+		}},
+	}
+	img := renderRequest(t, request, fonts.faces())
+
+	text := inkBounds(img)
+	colors := map[Color]int{}
+	for y := text.Min.Y; y < text.Max.Y; y++ {
+		for x := 0; x < 200; x++ {
+			for _, c := range []Color{black, red, blue} {
+				if pixel(img, x, y) == nrgba(c) {
+					colors[c] = max(colors[c], x)
+				}
+			}
+		}
+	}
+
+	for _, c := range []Color{black, red, blue} {
+		if _, ok := colors[c]; !ok {
+			t.Fatalf("no %v pixels", c)
+		}
+	}
+
+	// Each copy ends at its offset past the text
+	if colors[red]-colors[black] != 60 || colors[blue]-colors[black] != 120 {
+		t.Errorf("text ends at x=%d, red at %d, blue at %d, want 60 and 120 further", colors[black], colors[red], colors[blue])
+	}
+}
+
+func TestMaxLines(t *testing.T) {
+	fonts := newTestFonts(t)
+	paragraph := "The quick brown fox jumps over the lazy dog while the cat watches from the fence and the birds sing"
+
+	face, err := LoadFontFace(fonts.regular, 20, HintingNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer face.Close()
+
+	dc := gg.NewContext(1, 1)
+	dc.SetFontFace(face)
+
+	tests := []struct {
+		maxLines int
+		lines    int
+		ellipsis bool
+	}{
+		{2, 2, true},
+		{1, 1, true},
+		{0, len(dc.WordWrap(paragraph, 180)), false},
+		{20, len(dc.WordWrap(paragraph, 180)), false},
+	}
+
+	for _, test := range tests {
+		t.Run(strconv.Itoa(test.maxLines), func(t *testing.T) {
+			// Text that fits comes back unchanged, without the wrapping
+			limited := LimitLines(dc, paragraph, 180, test.maxLines)
+			if !test.ellipsis && limited != paragraph {
+				t.Errorf("LimitLines() = %q, want the paragraph unchanged", limited)
+			}
+
+			if lines := strings.Split(limited, "\n"); test.ellipsis && len(lines) != test.lines {
+				t.Errorf("%d lines, want %d", len(lines), test.lines)
+			}
+
+			if strings.HasSuffix(limited, "…") != test.ellipsis {
+				t.Errorf("LimitLines() = %q, want an ellipsis %v", limited, test.ellipsis)
+			}
+
+			request := ImgRequest{
+				WidthPx:  200,
+				HeightPx: 400,
+				BgColor:  white,
+				MultiLineTexts: []MultiLineText{{
+					StyledText:    StyledText{Text: paragraph, Font: fonts.regular, SizePx: pixels(20), Color: black, Position: textPosition(Position{10, 10})},
+					WrapWidthPx:   pixels(180),
+					LineSpacingPx: 1.5,
+					MaxLines:      test.maxLines,
+				}},
+			}
+
+			if tops := lineTops(renderRequest(t, request, fonts.faces())); len(tops) != test.lines {
+				t.Errorf("%d lines rendered, want %d", len(tops), test.lines)
+			}
+		})
+	}
+}
+
+// columnTop is the first row with ink between columns x0 and x1, -1 when the
+// columns are blank
+func columnTop(img image.Image, x0, x1 int) int {
+	background := pixel(img, 0, 0)
+	for y := 0; y < img.Bounds().Dy(); y++ {
+		for x := x0; x < x1; x++ {
+			if !closeTo(pixel(img, x, y), background, 64) {
+				return y
+			}
+		}
+	}
+
+	return -1
+}
+
+func TestWaveText(t *testing.T) {
+	fonts := newTestFonts(t)
+	text := strings.Repeat("x", 16)
+
+	face, err := LoadFontFace(fonts.regular, 24, HintingNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer face.Close()
+
+	render := func(wave *Wave) image.Image {
+		request := ImgRequest{
+			WidthPx:         240,
+			HeightPx:        80,
+			BgColor:         white,
+			SingleLineTexts: []StyledText{{Text: text, Font: fonts.regular, SizePx: pixels(24), Color: black, Position: textPosition(Position{10, 50}), Wave: wave}},
+		}
+
+		return renderRequest(t, request, fonts.faces())
+	}
+	flat := render(nil)
+
+	tests := []struct {
+		name string
+		wave Wave
+	}{
+		{"100px", Wave{AmplitudePx: 10, WavelengthPx: 100}},
+		{"60px", Wave{AmplitudePx: 6, WavelengthPx: 60}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			waved := render(&test.wave)
+			advance := measureRun(face, "x")
+
+			for i := range text {
+				offset := measureRun(face, text[:i])
+				x0, x1 := 10+int(offset+advance*0.3), 10+int(offset+advance*0.7)
+
+				// Canvas y grows downwards like the shift
+				want := columnTop(flat, x0, x1) + int(math.Round(test.wave.AmplitudePx*math.Sin(2*math.Pi*offset/test.wave.WavelengthPx)))
+				if got := columnTop(waved, x0, x1); got < want-1 || got > want+1 {
+					t.Errorf("glyph %d starts at row %d, want %d", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLineAligns(t *testing.T) {
+	fonts := newTestFonts(t)
+
+	face, err := LoadFontFace(fonts.regular, 20, HintingNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer face.Close()
+	widths := []float64{measureRun(face, "First"), measureRun(face, "Second")}
+
+	// Lines start at these x for a 200px box from x 10
+	starts := map[TextAlign]func(width float64) float64{
+		Left:   func(float64) float64 { return 10 },
+		Center: func(width float64) float64 { return 10 + (200-width)/2 },
+		Right:  func(width float64) float64 { return 210 - width },
+	}
+
+	tests := []struct {
+		name       string
+		align      TextAlign
+		lineAligns []TextAlign
+		want       []TextAlign
+	}{
+		{"centered then left", Left, []TextAlign{Center, Left}, []TextAlign{Center, Left}},
+		{"missing entries use align", Right, []TextAlign{Center}, []TextAlign{Center, Right}},
+		{"empty entries use align", Center, []TextAlign{"", Left}, []TextAlign{Center, Left}},
+		{"block align", Center, nil, []TextAlign{Center, Center}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := ImgRequest{
+				WidthPx:  220,
+				HeightPx: 100,
+				BgColor:  white,
+				MultiLineTexts: []MultiLineText{{
+					StyledText:    StyledText{Text: "First\nSecond", Font: fonts.regular, SizePx: pixels(20), Color: black, Position: textPosition(Position{10, 10})},
+					WrapWidthPx:   pixels(200),
+					LineSpacingPx: 1.5,
+					Align:         test.align,
+					LineAligns:    test.lineAligns,
+				}},
+			}
+			img := renderRequest(t, request, fonts.faces())
+
+			tops := append(lineTops(img), 100)
+			if len(tops) != 3 {
+				t.Fatalf("%d lines of ink, want 2", len(tops)-1)
+			}
+
+			for i, align := range test.want {
+				want := starts[align](widths[i])
+				if got := float64(inkIn(img, image.Rect(0, tops[i], 220, tops[i+1])).Min.X); got < want-3 || got > want+3 {
+					t.Errorf("line %d starts at x %v, want %v for %s", i, got, want, align)
+				}
+			}
+		})
+	}
+}
+
+func TestTabStops(t *testing.T) {
+	fonts := newTestFonts(t)
+
+	face, err := LoadFontFace(fonts.regular, 20, HintingNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer face.Close()
+	space := measureRun(face, " ")
+
+	tests := []struct {
+		name   string
+		text   string
+		stops  []float64
+		starts []float64 // of every tab separated part, from the text x
+	}{
+		{"one stop", "Name:\tAlice", []float64{120}, []float64{0, 120}},
+		{"passed stops are skipped", "Name:\tAlice", []float64{20, 120}, []float64{0, 120}},
+		{"columns", "a\tb\tc", []float64{60, 140}, []float64{0, 60, 140}},
+		{"past the last stop", "a\tb\tc", []float64{60}, []float64{0, 60, 60 + measureRun(face, "b") + space}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := ImgRequest{
+				WidthPx:         300,
+				HeightPx:        40,
+				BgColor:         white,
+				SingleLineTexts: []StyledText{{Text: test.text, Font: fonts.regular, SizePx: pixels(20), Color: black, Position: textPosition(Position{10, 30}), TabStopsPx: test.stops}},
+			}
+			img := renderRequest(t, request, fonts.faces())
+
+			previousEnd := 0.0
+			for i, part := range strings.Split(test.text, "\t") {
+				start := 10 + test.starts[i]
+				end := start + measureRun(face, part)
+
+				// Glyph side bearings keep the ink a little inside the advance
+				ink := inkIn(img, image.Rect(int(start)-1, 0, int(end)+1, 40))
+				if ink.Empty() || float64(ink.Min.X) > start+3 || float64(ink.Max.X) < end-3 {
+					t.Errorf("part %q has ink %v, want it from x %v to %v", part, ink, start, end)
+				}
+
+				if gap := inkIn(img, image.Rect(int(previousEnd)+2, 0, int(start)-1, 40)); i > 0 && !gap.Empty() {
+					t.Errorf("ink %v before part %q, want a gap up to the stop", gap, part)
+				}
+				previousEnd = end
+			}
+		})
+	}
+}
+
+func TestLineOffsets(t *testing.T) {
+	// Three 20px lines 30px apart in a 200px box
+	tests := []struct {
+		distribution LineDistribution
+		want         []float64
+	}{
+		{"", []float64{0, 30, 60}},
+		{DistributeTop, []float64{0, 30, 60}},
+		{DistributeCenter, []float64{60, 90, 120}},
+		{DistributeBottom, []float64{120, 150, 180}},
+		{DistributeSpaceBetween, []float64{0, 90, 180}},
+		{DistributeSpaceEvenly, []float64{35, 90, 145}},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.distribution), func(t *testing.T) {
+			if got := lineOffsets(3, 20, 30, 200, test.distribution); !slices.Equal(got, test.want) {
+				t.Errorf("offsets %v, want %v", got, test.want)
+			}
+		})
+	}
+
+	// A single line can't be spread, so it stays at the top
+	if got := lineOffsets(1, 20, 30, 200, DistributeSpaceBetween); !slices.Equal(got, []float64{0}) {
+		t.Errorf("single line offsets %v, want [0]", got)
+	}
+}
+
+func TestSpaceBetweenLines(t *testing.T) {
+	fonts := newTestFonts(t)
+
+	// render returns the top of each line and the lowest row with ink
+	render := func(distribution LineDistribution) ([]int, int) {
+		text := MultiLineText{
+			StyledText:           StyledText{Text: "HHH\nHHH\nHHH", Font: fonts.regular, SizePx: pixels(24), Color: black, Position: textPosition(Position{10, 20})},
+			WrapWidthPx:          pixels(200),
+			LineSpacingPx:        1.5,
+			BoxHeightPx:          240,
+			VerticalDistribution: distribution,
+		}
+		img := renderRequest(t, ImgRequest{WidthPx: 220, HeightPx: 280, BgColor: white, MultiLineTexts: []MultiLineText{text}}, fonts.faces())
+
+		return lineTops(img), inkBounds(img).Max.Y
+	}
+
+	tops, bottom := render(DistributeSpaceBetween)
+	if len(tops) != 3 {
+		t.Fatalf("line tops %v, want 3 lines", tops)
+	}
+
+	// The first line sits where top puts it and the last where bottom does
+	if topTops, _ := render(DistributeTop); tops[0] != topTops[0] {
+		t.Errorf("first line starts at y=%d, want y=%d at the top of the box", tops[0], topTops[0])
+	}
+
+	if _, bottomBottom := render(DistributeBottom); bottom != bottomBottom {
+		t.Errorf("last line ends at y=%d, want y=%d at the bottom of the box", bottom, bottomBottom)
+	}
+
+	// Spread over most of the box, with the middle line halfway
+	if spread := tops[2] - tops[0]; spread < 180 {
+		t.Errorf("lines span %dpx, want most of the 240px box", spread)
+	}
+
+	if diff := (tops[1] - tops[0]) - (tops[2] - tops[1]); diff < -1 || diff > 1 {
+		t.Errorf("line tops %v are not evenly spaced", tops)
+	}
+}
+
+func TestKerning(t *testing.T) {
+	fonts := newTestFonts(t)
+	roboto := testdataFont(t, fonts.dir, "Roboto", "Roboto-Regular.ttf")
+	off := false
+
+	// widths measures text and the ink it draws with kerning on or off
+	widths := func(font string, kerning *bool) (float64, int) {
+		text := StyledText{Text: "AVAVAVAV", Font: font, SizePx: pixels(48), Color: black, Position: textPosition(Position{10, 60}), Kerning: kerning}
+
+		face, err := text.loadFace(font, text.SizePx.Value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer face.Close()
+
+		img := renderRequest(t, ImgRequest{WidthPx: 400, HeightPx: 80, BgColor: white, SingleLineTexts: []StyledText{text}}, []string{font})
+		return measureRun(face, text.Text), inkBounds(img).Dx()
+	}
+
+	tests := []struct {
+		name   string
+		font   string
+		kerned bool
+	}{
+		{"kern pairs", roboto, true},
+		{"no kern pairs", fonts.regular, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kernedWidth, kernedInk := widths(test.font, nil)
+			plainWidth, plainInk := widths(test.font, &off)
+
+			// Seven pairs of about 1.4px each at this size
+			if test.kerned && (plainWidth-kernedWidth < 5 || plainInk-kernedInk < 5) {
+				t.Errorf("kerned %.1fpx with %dpx of ink, unkerned %.1fpx with %dpx, want the kerned text narrower", kernedWidth, kernedInk, plainWidth, plainInk)
+			}
+
+			if !test.kerned && (plainWidth != kernedWidth || plainInk != kernedInk) {
+				t.Errorf("kerning changed the width from %.1fpx to %.1fpx of a font without kern pairs", plainWidth, kernedWidth)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import "slices"
+
+// TextStack lays single-line texts out top-down from Position, each one
+// starting below the measured line box of the one before it
+type TextStack struct {
+	Position Position        `json:"position"` // top-left of the first item
+	GapPx    float64         `json:"gapPx"`
+	Items    []TextStackItem `json:"items"`
+	Visibility
+}
+
+// TextStackItem is a single-line text whose position comes from the stack
+type TextStackItem struct {
+	StyledText
+	SpacingPx *float64 `json:"spacingPx"` // gap below this item, overrides the stack gapPx
+}
+
+// ExpandTextStacks turns every stack into positioned single-line texts, so
+// they validate and draw like any other text. Sizes must be defaulted first.
+func ExpandTextStacks(request *ImgRequest, fontFaces []string) error {
+	for _, stack := range request.TextStacks {
+		y := stack.Position.Y
+
+		for _, item := range stack.Items {
+			if !slices.Contains(fontFaces, item.Font) {
+				return errFontNotFound
+			}
+
+			face, err := item.loadFace(item.Font, item.SizePx.Value)
+			if err != nil {
+				return err
+			}
+
+			metrics := face.Metrics()
+			ascent := float64(metrics.Ascent) / 64
+			descent := float64(metrics.Descent) / 64
+			face.Close()
+
+			text := item.StyledText
+			text.Position = textPosition(Position{stack.Position.X, y + ascent})
+			text.Anchoring = Anchoring{}
+			text.PositionAnchor = BaselineAnchor
+			request.SingleLineTexts = append(request.SingleLineTexts, text)
+
+			gap := stack.GapPx
+			if item.SpacingPx != nil {
+				gap = *item.SpacingPx
+			}
+
+			y += ascent + descent + gap
+		}
+	}
+
+	request.TextStacks = nil
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import "testing"
+
+func TestTextStack(t *testing.T) {
+	fonts := newTestFonts(t)
+
+	face, err := LoadFontFace(fonts.regular, 24, HintingNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := face.Metrics()
+	face.Close()
+	ascent, lineHeight := float64(metrics.Ascent)/64, float64(metrics.Ascent+metrics.Descent)/64
+
+	twenty := 20.0
+	item := func(spacing *float64) TextStackItem {
+		return TextStackItem{StyledText: StyledText{Text: "Hello", Font: fonts.regular, SizePx: pixels(24), Color: black}, SpacingPx: spacing}
+	}
+
+	tests := []struct {
+		name      string
+		items     []TextStackItem
+		baselines []float64
+	}{
+		{"gap", []TextStackItem{item(nil), item(nil), item(nil)}, []float64{10 + ascent, 10 + ascent + lineHeight + 8, 10 + ascent + 2*(lineHeight+8)}},
+		{"item spacing", []TextStackItem{item(&twenty), item(nil), item(nil)}, []float64{10 + ascent, 10 + ascent + lineHeight + 20, 10 + ascent + 2*lineHeight + 28}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := ImgRequest{WidthPx: 120, HeightPx: 160, BgColor: white, TextStacks: []TextStack{{Position: Position{10, 10}, GapPx: 8, Items: test.items}}}
+			img := renderRequest(t, request, fonts.faces())
+
+			prepared := request
+			if err := PrepareRequest(&prepared, fonts.faces(), nil, testDefaults); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(prepared.SingleLineTexts) != 3 {
+				t.Fatalf("%d texts, want 3", len(prepared.SingleLineTexts))
+			}
+
+			for i, text := range prepared.SingleLineTexts {
+				if got := text.Position.Y.Value; got != test.baselines[i] {
+					t.Errorf("item %d baseline at %v, want %v", i, got, test.baselines[i])
+				}
+			}
+
+			// The same word on every line, so the ink moves by the spacing
+			tops := lineTops(img)
+			if len(tops) != 3 {
+				t.Fatalf("%d lines of ink at %v, want 3", len(tops), tops)
+			}
+
+			for i := 1; i < 3; i++ {
+				want := test.baselines[i] - test.baselines[i-1]
+				if got := float64(tops[i] - tops[i-1]); got < want-1 || got > want+1 {
+					t.Errorf("line %d is %vpx below the one before, want %v", i, got, want)
+				}
+			}
+		})
+	}
+}
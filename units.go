@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Unit string
+
+const (
+	Px      Unit = "px"
+	Pt      Unit = "pt"
+	Percent Unit = "%"
+	// Percentages of a fixed canvas side, whatever the field, so that "5%h"
+	// font sizes and positions scale with the canvas height
+	PercentWidth  Unit = "%w"
+	PercentHeight Unit = "%h"
+)
+
+// Dimension is a length given as a number of pixels or as a string such as
+// "24px", "18pt", "10%" or "5%h". ResolveDimensions turns it into pixels.
+type Dimension struct {
+	Value float64
+	Unit  Unit
+}
+
+func ParseDimension(value string) (Dimension, error) {
+	value = strings.TrimSpace(value)
+
+	// %w and %h go before % so the longer suffix wins
+	for _, unit := range []Unit{Px, Pt, PercentWidth, PercentHeight, Percent} {
+		if number, ok := strings.CutSuffix(value, string(unit)); ok {
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+			if err != nil {
+				break
+			}
+
+			return Dimension{parsed, unit}, nil
+		}
+	}
+
+	return Dimension{}, fmt.Errorf("invalid dimension %q, use px, pt, %%, %%w or %%h", value)
+}
+
+// Pixels converts the dimension. Percentages are of referencePx, points are
+// 1/72 inch at dpi.
+func (dimension Dimension) Pixels(referencePx float64, dpi float64) float64 {
+	switch dimension.Unit {
+	case Pt:
+		return dimension.Value * dpi / 72
+	case Percent, PercentWidth, PercentHeight:
+		return dimension.Value * referencePx / 100
+	}
+
+	return dimension.Value
+}
+
+func pixels(value float64) Dimension {
+	return Dimension{value, Px}
+}
+
+// UnmarshalJSON accepts a number of pixels or a string for ParseDimension
+func (dimension *Dimension) UnmarshalJSON(data []byte) error {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	switch value := value.(type) {
+	case nil:
+		return nil
+	case float64:
+		*dimension = pixels(value)
+		return nil
+	case string:
+		parsed, err := ParseDimension(value)
+		if err != nil {
+			return err
+		}
+
+		*dimension = parsed
+		return nil
+	}
+
+	return fmt.Errorf("invalid dimension %s, use a number or a string such as \"24px\"", data)
+}
+
+// MarshalJSON writes pixels as a plain number
+func (dimension Dimension) MarshalJSON() ([]byte, error) {
+	if dimension.Unit == "" || dimension.Unit == Px {
+		return json.Marshal(dimension.Value)
+	}
+
+	return json.Marshal(strconv.FormatFloat(dimension.Value, 'g', -1, 64) + string(dimension.Unit))
+}
+
+// TextPosition is a Position whose coordinates are Dimensions
+type TextPosition struct {
+	X Dimension `json:"x"`
+	Y Dimension `json:"y"`
+}
+
+func textPosition(position Position) TextPosition {
+	return TextPosition{pixels(position.X), pixels(position.Y)}
+}
+
+// Pixels is the position once its dimensions are resolved
+func (position TextPosition) Pixels() Position {
+	return Position{position.X.Value, position.Y.Value}
+}
+
+type axis int
+
+const (
+	horizontal axis = iota
+	vertical
+)
+
+// canvasDimensions resolves dimensions against a canvas size and DPI
+type canvasDimensions struct {
+	widthPx, heightPx, dpi float64
+}
+
+// resolve converts dimension to pixels. A plain % is of the canvas side
+// given, %w and %h name theirs.
+func (canvas canvasDimensions) resolve(dimension *Dimension, side axis) {
+	reference := canvas.widthPx
+	if dimension.Unit == PercentHeight || (dimension.Unit == Percent && side == vertical) {
+		reference = canvas.heightPx
+	}
+
+	*dimension = Dimension{dimension.Pixels(reference, canvas.dpi), Px}
+}
+
+func (canvas canvasDimensions) text(text *StyledText) {
+	canvas.resolve(&text.SizePx, vertical)
+	canvas.resolve(&text.Position.X, horizontal)
+	canvas.resolve(&text.Position.Y, vertical)
+}
+
+// ResolveDimensions converts the sizes, positions and wrap widths of texts to
+// pixels once the canvas size is known. Percentages of x and wrap widths are
+// of the canvas width, those of y and sizes of its height.
+func ResolveDimensions(request *ImgRequest) {
+	dpi := request.DPI
+	if dpi == 0 {
+		dpi = 96
+	}
+
+	canvas := canvasDimensions{float64(request.WidthPx), float64(request.HeightPx), dpi}
+
+	for i := range request.SingleLineTexts {
+		canvas.text(&request.SingleLineTexts[i])
+	}
+
+	for i := range request.MultiLineTexts {
+		canvas.text(&request.MultiLineTexts[i].StyledText)
+		canvas.resolve(&request.MultiLineTexts[i].WrapWidthPx, horizontal)
+	}
+
+	for i := range request.TextStacks {
+		for j := range request.TextStacks[i].Items {
+			canvas.text(&request.TextStacks[i].Items[j].StyledText)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseDimension(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Dimension
+		ok    bool
+	}{
+		{"24px", Dimension{24, Px}, true},
+		{"18pt", Dimension{18, Pt}, true},
+		{"10%", Dimension{10, Percent}, true},
+		{"5%h", Dimension{5, PercentHeight}, true},
+		{"5%w", Dimension{5, PercentWidth}, true},
+		{" 2.5 px ", Dimension{2.5, Px}, true},
+		{"24", Dimension{}, false},
+		{"px", Dimension{}, false},
+		{"12em", Dimension{}, false},
+		{"5%x", Dimension{}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, err := ParseDimension(test.value)
+			if (err == nil) != test.ok {
+				t.Fatalf("ParseDimension(%q) error %v, want ok %v", test.value, err, test.ok)
+			}
+
+			if got != test.want {
+				t.Errorf("ParseDimension(%q) = %v, want %v", test.value, got, test.want)
+			}
+		})
+	}
+}
+
+func TestDimensionFields(t *testing.T) {
+	// 400x200 at 144 DPI, so 1pt is 2px
+	data := `{
+		"widthPx": 400,
+		"heightPx": 200,
+		"dpi": 144,
+		"singleLineTexts": [{"text": "a", "sizePx": "18pt", "position": {"x": "10%", "y": "50%"}}],
+		"multiLineTexts": [{"styledText": {"text": "b", "sizePx": "5%", "position": {"x": "12px", "y": 30}}, "wrapWidthPx": "50%"}]
+	}`
+
+	var request ImgRequest
+	if err := json.Unmarshal([]byte(data), &request); err != nil {
+		t.Fatal(err)
+	}
+
+	ResolveDimensions(&request)
+	single, multi := request.SingleLineTexts[0], request.MultiLineTexts[0]
+
+	tests := []struct {
+		name      string
+		got, want Dimension
+	}{
+		{"pt size", single.SizePx, pixels(36)},
+		{"% of width", single.Position.X, pixels(40)},
+		{"% of height", single.Position.Y, pixels(100)},
+		{"% size of height", multi.SizePx, pixels(10)},
+		{"px", multi.Position.X, pixels(12)},
+		{"number", multi.Position.Y, pixels(30)},
+		{"% wrap width", multi.WrapWidthPx, pixels(200)},
+	}
+
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("%s: %v, want %v", test.name, test.got, test.want)
+		}
+	}
+
+	// Wrap widths are still required
+	fonts := newTestFonts(t)
+	server := newTestServer(t, Config{FontDir: fonts.dir})
+	body := map[string]any{"widthPx": 100, "heightPx": 100, "multiLineTexts": []map[string]any{{"styledText": map[string]any{"text": "a", "font": fonts.regular}}}}
+	if response := postJSON(t, server.URL+"/generate", body); response.StatusCode != 400 {
+		t.Errorf("no wrapWidthPx: status %d, want 400", response.StatusCode)
+	}
+}
+
+func TestFieldsWithoutDimensions(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"unknown unit", `{"singleLineTexts": [{"sizePx": "12em"}]}`},
+		{"not a length", `{"singleLineTexts": [{"sizePx": true}]}`},
+		// Focus points are fractions of the image, not lengths
+		{"focus", `{"bgImgFocus": {"x": "50%", "y": 0.5}}`},
+		{"image focus", `{"images": [{"focus": {"x": 0.5, "y": "50%"}}]}`},
+		{"shape position", `{"circles": [{"radiusPx": 1, "center": {"x": "10%", "y": 0}}]}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var request ImgRequest
+			if err := json.Unmarshal([]byte(test.data), &request); err == nil {
+				t.Errorf("%s was accepted", test.data)
+			}
+		})
+	}
+
+	// Numbers outside the dimension fields are left as they are
+	var request ImgRequest
+	if err := json.Unmarshal([]byte(`{"widthPx": 100, "heightPx": 100, "bgImgFocus": {"x": 0.25, "y": 1}}`), &request); err != nil {
+		t.Fatal(err)
+	}
+
+	ResolveDimensions(&request)
+	if *request.BgImgFocus != (Position{0.25, 1}) {
+		t.Errorf("bgImgFocus is %v, want {0.25 1}", *request.BgImgFocus)
+	}
+}
+
+func TestRelativeFontSize(t *testing.T) {
+	fonts := newTestFonts(t)
+	server := newTestServer(t, Config{FontDir: fonts.dir})
+
+	// capHeight is how tall H is drawn at size on a 400px wide canvas
+	capHeight := func(height int, size string) int {
+		body := map[string]any{
+			"widthPx":         400,
+			"heightPx":        height,
+			"singleLineTexts": []map[string]any{{"text": "H", "font": fonts.regular, "sizePx": size, "position": map[string]any{"x": 10, "y": "80%"}}},
+		}
+
+		response := postJSON(t, server.URL+"/generate", body)
+		data := readBody(t, response)
+		if response.StatusCode != 200 {
+			t.Fatalf("status %d: %s", response.StatusCode, data)
+		}
+
+		return inkBounds(decodeImage(t, data)).Dy()
+	}
+
+	tests := []struct {
+		name          string
+		size          string
+		heights       [2]int
+		ratioTimes100 int
+	}{
+		{"%h doubles with the height", "20%h", [2]int{100, 200}, 200},
+		{"%h on other heights", "20%h", [2]int{150, 300}, 200},
+		{"%w ignores the height", "5%w", [2]int{100, 200}, 100},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			small, large := capHeight(test.heights[0], test.size), capHeight(test.heights[1], test.size)
+			if small == 0 {
+				t.Fatal("nothing was drawn")
+			}
+
+			// Glyph outlines round to whole pixels, so allow one either way
+			want := small * test.ratioTimes100 / 100
+			if large < want-1 || large > want+1 {
+				t.Errorf("H is %dpx and %dpx tall, want about %dpx on the taller canvas", small, large, want)
+			}
+		})
+	}
+}
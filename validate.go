@@ -0,0 +1,374 @@
+package main
+
+import (
+	"errors"
+	"maps"
+	"slices"
+)
+
+var errFontNotFound = errors.New("Font not found")
+
+// PrepareRequest applies defaults that depend on the server and then validates
+// the request
+func PrepareRequest(request *ImgRequest, fontFaces []string, assets map[string]string, defaults Defaults) error {
+	RemoveHidden(request)
+	ResolveFontFamilies(request, fontFaces)
+	applyShortcodes(request)
+
+	// Asset names must resolve before the background size is read
+	if err := ResolveAssets(request, assets); err != nil {
+		return err
+	}
+
+	if err := ApplyPreset(request); err != nil {
+		return err
+	}
+
+	if err := PreflightImages(*request); err != nil {
+		return err
+	}
+
+	if err := ResolveCanvasSize(request); err != nil {
+		return err
+	}
+
+	if err := ApplyProfile(request); err != nil {
+		return err
+	}
+
+	// Before the defaults, which are in pixels already
+	ResolveDimensions(request)
+
+	if request.Format == "" {
+		request.Format = defaults.Format
+	}
+
+	if request.BgColorHex != "" {
+		bgColor, err := ParseHexColor(request.BgColorHex)
+		if err != nil {
+			return err
+		}
+
+		request.BgColor = bgColor
+	}
+
+	// An explicit "none" keeps the default background from being filled in
+	if request.Background == "none" {
+		request.Transparent = true
+	}
+
+	defaults.Apply(request)
+
+	if err := ExpandTextStacks(request, fontFaces); err != nil {
+		return err
+	}
+
+	return ValidateRequest(*request, fontFaces)
+}
+
+// ValidateRequest checks a request after defaults have been applied
+func ValidateRequest(request ImgRequest, fontFaces []string) error {
+	if request.WidthPx <= 0 || request.HeightPx <= 0 {
+		return errors.New("widthPx and heightPx are required without a background image")
+	}
+
+	if request.DPI < 0 {
+		return errors.New("dpi can't be negative")
+	}
+
+	if request.Supersample < 0 || request.Supersample > 3 {
+		return errors.New("supersample must be between 1 and 3")
+	}
+
+	if _, ok := ContentType(request.Format); !ok {
+		return errors.New("Unsupported format")
+	}
+
+	if request.Format == AVIF && !avifAvailable {
+		return errAVIFUnavailable
+	}
+
+	if request.Format == ICO && request.WidthPx != request.HeightPx {
+		return errors.New("ico output needs a square canvas")
+	}
+
+	if request.Metadata != nil && request.Format != JPEG && request.Format != PNG {
+		return errors.New("metadata is only supported for jpeg and png")
+	}
+
+	if request.Trim != nil {
+		if err := request.Trim.Validate(); err != nil {
+			return err
+		}
+
+		if request.Format == ICO {
+			return errors.New("trim can't be used with ico output, which needs a square canvas")
+		}
+	}
+
+	if request.Output != nil {
+		if err := request.Output.Validate(); err != nil {
+			return err
+		}
+	}
+
+	for _, filter := range request.Filters {
+		if err := filter.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if err := request.ColorProfile.Validate(request.Format); err != nil {
+		return err
+	}
+
+	if request.Background != "" && request.Background != "none" {
+		return errors.New(`background must be "none" when set`)
+	}
+
+	if request.Transparent && !SupportsAlpha(request.Format) {
+		return errors.New("transparent backgrounds need an alpha format such as png")
+	}
+
+	if request.CanvasCornerRadiusPx < 0 {
+		return errors.New("canvasCornerRadiusPx can't be negative")
+	}
+
+	if request.CanvasCornerRadiusPx > 0 && !SupportsAlpha(request.Format) {
+		return errors.New("canvasCornerRadiusPx needs an alpha format such as png")
+	}
+
+	if !request.Transparent && request.BgImgPath == "" && request.BgImgBase64 == "" && request.BgGradient == nil && request.BgCheckerboard == nil && request.BgColor == (Color{}) {
+		return errors.New("No background image or color provided")
+	}
+
+	if request.BgImgFit != "" && request.BgImgFit != Stretch && request.BgImgFit != Cover && request.BgImgFit != Contain {
+		return errors.New("bgImgFit must be stretch, cover or contain")
+	}
+
+	if err := validateFocus(request.BgImgFocus, request.BgImgFit); err != nil {
+		return err
+	}
+
+	if request.BgGradient != nil {
+		if err := request.BgGradient.Validate(); err != nil {
+			return err
+		}
+	}
+
+	gradients := []*Gradient{}
+	for _, rectangle := range request.Rectangles {
+		gradients = append(gradients, rectangle.FillGradient, rectangle.StrokeGradient)
+	}
+	for _, group := range request.RectangleGroups {
+		gradients = append(gradients, group.FillGradient, group.StrokeGradient)
+	}
+	for _, curve := range request.Curves {
+		gradients = append(gradients, curve.StrokeGradient)
+	}
+
+	for _, gradient := range gradients {
+		if gradient != nil {
+			if err := gradient.Validate(); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Sorted so a request with several bad families always reports the same one
+	for _, name := range slices.Sorted(maps.Keys(request.FontFamilies)) {
+		if err := request.FontFamilies[name].Validate(fontFaces); err != nil {
+			return err
+		}
+	}
+
+	for _, text := range request.SingleLineTexts {
+		if !slices.Contains(fontFaces, text.Font) {
+			return errFontNotFound
+		}
+	}
+
+	for _, text := range request.SingleLineTexts {
+		if text.PositionAnchor != "" && text.PositionAnchor != BaselineAnchor && text.PositionAnchor != TopAnchor && text.PositionAnchor != CenterAnchor {
+			return errors.New("positionAnchor must be baseline, top or center")
+		}
+
+		if text.Wave != nil && (text.Markup || text.AutoFont) {
+			return errors.New("wave can't be combined with markup or autoFont")
+		}
+
+		if len(text.TabStopsPx) > 0 && (text.Markup || text.AutoFont || text.Wave != nil) {
+			return errors.New("tabStopsPx can't be combined with markup, autoFont or wave")
+		}
+
+		if !slices.IsSorted(text.TabStopsPx) {
+			return errors.New("tabStopsPx must be ascending")
+		}
+	}
+
+	for _, text := range request.SingleLineTexts {
+		if err := validateHighlights(text); err != nil {
+			return err
+		}
+
+		if len(text.Highlights) > 0 && text.RotationDeg != 0 {
+			return errors.New("rotationDeg can't be combined with highlights")
+		}
+	}
+
+	anchorings := []Anchoring{}
+	for _, text := range request.SingleLineTexts {
+		anchorings = append(anchorings, text.Anchoring)
+	}
+	for _, rectangle := range request.Rectangles {
+		anchorings = append(anchorings, rectangle.Anchoring)
+	}
+	for _, circle := range request.Circles {
+		anchorings = append(anchorings, circle.Anchoring)
+	}
+	for _, placed := range request.Images {
+		anchorings = append(anchorings, placed.Anchoring)
+	}
+	for _, bar := range request.ProgressBars {
+		anchorings = append(anchorings, bar.Anchoring)
+	}
+
+	for _, placed := range request.Images {
+		if placed.BatchIndex != nil && (*placed.BatchIndex < 0 || *placed.BatchIndex >= len(request.BatchResults)) {
+			return errors.New("batchIndex must reference an earlier request in the same batch")
+		}
+
+		if placed.Fit != "" && placed.Fit != Stretch && placed.Fit != Cover && placed.Fit != Contain {
+			return errors.New("image fit must be stretch, cover or contain")
+		}
+
+		if (placed.Fit == Cover || placed.Fit == Contain) && (placed.WidthPx <= 0 || placed.HeightPx <= 0) {
+			return errors.New("cover and contain fits need both widthPx and heightPx")
+		}
+
+		if err := validateFocus(placed.Focus, placed.Fit); err != nil {
+			return err
+		}
+
+		if len(placed.GradientMap) > 0 {
+			if err := (Gradient{Stops: placed.GradientMap}).Validate(); err != nil {
+				return err
+			}
+		}
+	}
+
+	blendModes := []BlendMode{}
+	for _, rectangle := range request.Rectangles {
+		blendModes = append(blendModes, rectangle.BlendMode)
+	}
+	for _, group := range request.RectangleGroups {
+		blendModes = append(blendModes, group.BlendMode)
+	}
+	for _, circle := range request.Circles {
+		blendModes = append(blendModes, circle.BlendMode)
+	}
+	for _, triangle := range request.Triangles {
+		blendModes = append(blendModes, triangle.BlendMode)
+	}
+	for _, path := range request.Paths {
+		blendModes = append(blendModes, path.BlendMode)
+	}
+	for _, placed := range request.Images {
+		blendModes = append(blendModes, placed.BlendMode)
+	}
+
+	for _, mode := range blendModes {
+		if err := mode.Validate(); err != nil {
+			return err
+		}
+	}
+
+	for _, icon := range request.Icons {
+		if err := icon.Validate(); err != nil {
+			return err
+		}
+	}
+
+	for _, anchoring := range anchorings {
+		if err := anchoring.Validate(); err != nil {
+			return err
+		}
+	}
+
+	for _, text := range request.MultiLineTexts {
+		if !slices.Contains(fontFaces, text.Font) {
+			return errFontNotFound
+		}
+
+		// Checked here rather than with binding, which skips struct fields
+		if text.WrapWidthPx.Value <= 0 {
+			return errors.New("wrapWidthPx is required")
+		}
+
+		if text.LineSpacingMode != "" && text.LineSpacingMode != Multiple && text.LineSpacingMode != Absolute {
+			return errors.New("lineSpacingMode must be multiple or absolute")
+		}
+
+		switch text.VerticalDistribution {
+		case "":
+		case DistributeTop, DistributeCenter, DistributeBottom, DistributeSpaceBetween, DistributeSpaceEvenly:
+			if text.BoxHeightPx <= 0 {
+				return errors.New("verticalDistribution needs boxHeightPx")
+			}
+
+			if text.Markup || text.AutoFont {
+				return errors.New("verticalDistribution can't be combined with markup or autoFont")
+			}
+		default:
+			return errors.New("verticalDistribution must be top, center, bottom, space-between or space-evenly")
+		}
+	}
+
+	for _, richText := range request.RichTexts {
+		for _, span := range richText.Spans {
+			if !slices.Contains(fontFaces, span.Font) {
+				return errFontNotFound
+			}
+		}
+	}
+
+	for _, markdown := range request.Markdowns {
+		if !slices.Contains(fontFaces, markdown.Font) {
+			return errFontNotFound
+		}
+	}
+
+	for _, chart := range request.BarCharts {
+		if (chart.ShowValues || chart.hasLabels()) && !slices.Contains(fontFaces, chart.Font) {
+			return errFontNotFound
+		}
+	}
+
+	for _, path := range request.Paths {
+		if err := path.Validate(); err != nil {
+			return err
+		}
+	}
+
+	for _, callout := range request.Callouts {
+		if err := callout.Validate(); err != nil {
+			return err
+		}
+
+		if callout.Text != "" && !slices.Contains(fontFaces, callout.Font) {
+			return errFontNotFound
+		}
+	}
+
+	for _, countdown := range request.Countdowns {
+		if err := countdown.Validate(); err != nil {
+			return err
+		}
+
+		if !slices.Contains(fontFaces, countdown.Font) {
+			return errFontNotFound
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"maps"
+	"math"
+	"os"
+	"slices"
+
+	gotext "github.com/go-text/typesetting/font"
+	ot "github.com/go-text/typesetting/font/opentype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// variableFace rasterizes glyph outlines of a variable font at fixed axis
+// values, since x/image/font/opentype ignores font variations
+type variableFace struct {
+	face  *gotext.Face
+	scale float64 // pixels per font unit
+}
+
+// LoadVariableFontFace loads a font with axes such as {"wght": 700} applied.
+// Axes missing from the font are ignored by the font itself.
+func LoadVariableFontFace(path string, sizePx float64, axes map[string]float64) (font.Face, error) {
+	fontBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	face, err := gotext.ParseTTF(bytes.NewReader(fontBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	// Sorted so the same axes always give the same variations and errors
+	variations := []gotext.Variation{}
+	for _, tag := range slices.Sorted(maps.Keys(axes)) {
+		if len(tag) != 4 {
+			return nil, fmt.Errorf("axis tag %q must be four characters", tag)
+		}
+
+		variations = append(variations, gotext.Variation{Tag: ot.MustNewTag(tag), Value: float32(axes[tag])})
+	}
+	face.SetVariations(variations)
+
+	return &variableFace{face, sizePx / float64(face.Upem())}, nil
+}
+
+// LoadStyledFontFace loads a variable face when axes are given and a regular
+// one otherwise
+func LoadStyledFontFace(path string, sizePx float64, hinting FontHinting, axes map[string]float64) (font.Face, error) {
+	if len(axes) > 0 {
+		return LoadVariableFontFace(path, sizePx, axes)
+	}
+
+	return LoadFontFace(path, sizePx, hinting)
+}
+
+func (face *variableFace) Close() error {
+	return nil
+}
+
+func (face *variableFace) toFixed(value float32) fixed.Int26_6 {
+	return fixed.Int26_6(math.Round(float64(value) * face.scale * 64))
+}
+
+func (face *variableFace) outline(r rune) (gotext.GID, gotext.GlyphOutline, bool) {
+	gid, ok := face.face.NominalGlyph(r)
+	if !ok {
+		return 0, gotext.GlyphOutline{}, false
+	}
+
+	outline, _ := face.face.GlyphData(gid).(gotext.GlyphOutline)
+	return gid, outline, true
+}
+
+// bounds is the outline extent in pixels relative to the dot, y down
+func (face *variableFace) bounds(outline gotext.GlyphOutline) fixed.Rectangle26_6 {
+	bounds := fixed.Rectangle26_6{
+		Min: fixed.Point26_6{X: math.MaxInt32, Y: math.MaxInt32},
+		Max: fixed.Point26_6{X: math.MinInt32, Y: math.MinInt32},
+	}
+
+	for _, segment := range outline.Segments {
+		for _, point := range segment.ArgsSlice() {
+			x, y := face.toFixed(point.X), -face.toFixed(point.Y)
+			bounds.Min.X, bounds.Max.X = min(bounds.Min.X, x), max(bounds.Max.X, x)
+			bounds.Min.Y, bounds.Max.Y = min(bounds.Min.Y, y), max(bounds.Max.Y, y)
+		}
+	}
+
+	if len(outline.Segments) == 0 {
+		return fixed.Rectangle26_6{}
+	}
+
+	return bounds
+}
+
+func (face *variableFace) Glyph(dot fixed.Point26_6, r rune) (image.Rectangle, image.Image, image.Point, fixed.Int26_6, bool) {
+	gid, outline, ok := face.outline(r)
+	if !ok {
+		return image.Rectangle{}, nil, image.Point{}, 0, false
+	}
+
+	advance := face.toFixed(face.face.HorizontalAdvance(gid))
+	glyphBounds := face.bounds(outline)
+
+	rect := image.Rect(
+		(dot.X + glyphBounds.Min.X).Floor(), (dot.Y + glyphBounds.Min.Y).Floor(),
+		(dot.X + glyphBounds.Max.X).Ceil(), (dot.Y + glyphBounds.Max.Y).Ceil(),
+	)
+
+	// Outline coordinates relative to the top-left of rect
+	originX := float32(float64(dot.X)/64 - float64(rect.Min.X))
+	originY := float32(float64(dot.Y)/64 - float64(rect.Min.Y))
+	scale := float32(face.scale)
+	point := func(segmentPoint ot.SegmentPoint) (float32, float32) {
+		return originX + segmentPoint.X*scale, originY - segmentPoint.Y*scale
+	}
+
+	rasterizer := vector.NewRasterizer(rect.Dx(), rect.Dy())
+	for _, segment := range outline.Segments {
+		switch segment.Op {
+		case ot.SegmentOpMoveTo:
+			// Contours are closed implicitly in fonts
+			rasterizer.ClosePath()
+			rasterizer.MoveTo(point(segment.Args[0]))
+		case ot.SegmentOpLineTo:
+			rasterizer.LineTo(point(segment.Args[0]))
+		case ot.SegmentOpQuadTo:
+			x1, y1 := point(segment.Args[0])
+			x2, y2 := point(segment.Args[1])
+			rasterizer.QuadTo(x1, y1, x2, y2)
+		case ot.SegmentOpCubeTo:
+			x1, y1 := point(segment.Args[0])
+			x2, y2 := point(segment.Args[1])
+			x3, y3 := point(segment.Args[2])
+			rasterizer.CubeTo(x1, y1, x2, y2, x3, y3)
+		}
+	}
+	rasterizer.ClosePath()
+
+	mask := image.NewAlpha(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	rasterizer.Draw(mask, mask.Bounds(), image.Opaque, image.Point{})
+
+	return rect, mask, image.Point{}, advance, true
+}
+
+func (face *variableFace) GlyphBounds(r rune) (fixed.Rectangle26_6, fixed.Int26_6, bool) {
+	gid, outline, ok := face.outline(r)
+	if !ok {
+		return fixed.Rectangle26_6{}, 0, false
+	}
+
+	return face.bounds(outline), face.toFixed(face.face.HorizontalAdvance(gid)), true
+}
+
+func (face *variableFace) GlyphAdvance(r rune) (fixed.Int26_6, bool) {
+	gid, ok := face.face.NominalGlyph(r)
+	if !ok {
+		return 0, false
+	}
+
+	return face.toFixed(face.face.HorizontalAdvance(gid)), true
+}
+
+func (face *variableFace) Kern(r0, r1 rune) fixed.Int26_6 {
+	return 0
+}
+
+func (face *variableFace) Metrics() font.Metrics {
+	extents, _ := face.face.FontHExtents()
+
+	return font.Metrics{
+		Height:  face.toFixed(extents.Ascender - extents.Descender + extents.LineGap),
+		Ascent:  face.toFixed(extents.Ascender),
+		Descent: face.toFixed(-extents.Descender),
+	}
+}
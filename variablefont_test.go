@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestVariableFontWeight(t *testing.T) {
+	path := testdataFont(t, t.TempDir(), "Commissioner", "Commissioner-VF.ttf")
+
+	render := func(weight float64) int {
+		request := ImgRequest{
+			WidthPx:         300,
+			HeightPx:        80,
+			BgColor:         white,
+			SingleLineTexts: []StyledText{{Text: "Heavy", Font: path, SizePx: pixels(48), Color: black, Position: textPosition(Position{10, 60}), Axes: map[string]float64{"wght": weight}}},
+		}
+
+		return darkness(renderRequest(t, request, []string{path}), 0, 300)
+	}
+
+	tests := []struct {
+		name            string
+		light, heavy    float64
+		minRatioPerCent int
+	}{
+		{"400 vs 900", 400, 900, 130},
+		{"300 vs 700", 300, 700, 120},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			light, heavy := render(test.light), render(test.heavy)
+			if light == 0 {
+				t.Fatal("nothing was drawn")
+			}
+
+			if heavy*100 < light*test.minRatioPerCent {
+				t.Errorf("weight %v has %d ink, weight %v %d, want at least %d%% more", test.heavy, heavy, test.light, light, test.minRatioPerCent-100)
+			}
+		})
+	}
+}
+
+func TestDeterministicOutput(t *testing.T) {
+	fonts := newTestFonts(t)
+	variable := testdataFont(t, fonts.dir, "Commissioner", "Commissioner-VF.ttf")
+	server := newTestServer(t, Config{FontDir: fonts.dir})
+
+	// Map fields are filled with several keys, so a different walk order on
+	// the second request would change the output or the error
+	body := func(format string) map[string]any {
+		return map[string]any{
+			"widthPx":  240,
+			"heightPx": 120,
+			"format":   format,
+			"fontFamilies": map[string]any{
+				"Brand": map[string]any{"regular": fonts.regular, "bold": fonts.bold},
+				"Code":  map[string]any{"regular": fonts.mono},
+				"Serif": map[string]any{"regular": fonts.italic},
+			},
+			"singleLineTexts": []map[string]any{
+				{"text": "Axes", "font": variable, "sizePx": 40, "position": map[string]any{"x": "5%", "y": "40%"}, "axes": map[string]any{"wght": 800, "slnt": -8, "FLAR": 50, "VOLM": 40}},
+				{"text": "Brand", "font": "Brand", "sizePx": 20, "position": map[string]any{"x": 10, "y": 80}},
+				{"text": "Code", "font": "Code", "sizePx": 20, "position": map[string]any{"x": "50%", "y": 80}},
+			},
+			"richTexts": []map[string]any{{
+				"position":    map[string]any{"x": 10, "y": 96},
+				"wrapWidthPx": 220,
+				"spans":       []map[string]any{{"text": "Serif ", "font": "Serif", "sizePx": 16}, {"text": "and Brand", "font": "Brand", "sizePx": 16}},
+			}},
+			"circles": []map[string]any{
+				{"center": map[string]any{"x": 200, "y": 60}, "radiusPx": 30, "fillColor": map[string]any{"r": 255, "a": 128}},
+				{"center": map[string]any{"x": 210, "y": 70}, "radiusPx": 30, "fillColor": map[string]any{"b": 255, "a": 128}},
+			},
+		}
+	}
+
+	for _, format := range []string{"png", "jpeg", "tiff"} {
+		t.Run(format, func(t *testing.T) {
+			responses := [2][]byte{}
+			for i := range responses {
+				response := postJSON(t, server.URL+"/generate", body(format))
+				responses[i] = readBody(t, response)
+				if response.StatusCode != 200 {
+					t.Fatalf("status %d: %s", response.StatusCode, responses[i])
+				}
+			}
+
+			if !bytes.Equal(responses[0], responses[1]) {
+				t.Errorf("the same request gave %d and %d different bytes", len(responses[0]), len(responses[1]))
+			}
+		})
+	}
+}
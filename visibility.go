@@ -0,0 +1,49 @@
+package main
+
+import "slices"
+
+// Visibility lets a template keep an element in the payload but switch it off
+type Visibility struct {
+	Visible *bool `json:"visible" default:"true"`
+}
+
+func (visibility Visibility) IsVisible() bool {
+	return visibility.Visible == nil || *visibility.Visible
+}
+
+func hidden[T interface{ IsVisible() bool }](element T) bool {
+	return !element.IsVisible()
+}
+
+// RemoveHidden drops every element with visible set to false so rendering
+// and validation never see them
+func RemoveHidden(request *ImgRequest) {
+	request.SingleLineTexts = slices.DeleteFunc(request.SingleLineTexts, hidden)
+	request.MultiLineTexts = slices.DeleteFunc(request.MultiLineTexts, hidden)
+	request.TextStacks = slices.DeleteFunc(request.TextStacks, hidden)
+	for i := range request.TextStacks {
+		request.TextStacks[i].Items = slices.DeleteFunc(request.TextStacks[i].Items, hidden)
+	}
+	request.Rectangles = slices.DeleteFunc(request.Rectangles, hidden)
+	request.RectangleGroups = slices.DeleteFunc(request.RectangleGroups, hidden)
+	request.Circles = slices.DeleteFunc(request.Circles, hidden)
+	request.Images = slices.DeleteFunc(request.Images, hidden)
+	request.ProgressBars = slices.DeleteFunc(request.ProgressBars, hidden)
+	request.StarRatings = slices.DeleteFunc(request.StarRatings, hidden)
+	request.Icons = slices.DeleteFunc(request.Icons, hidden)
+	request.BarCharts = slices.DeleteFunc(request.BarCharts, hidden)
+	request.Sparklines = slices.DeleteFunc(request.Sparklines, hidden)
+	request.Countdowns = slices.DeleteFunc(request.Countdowns, hidden)
+	request.RichTexts = slices.DeleteFunc(request.RichTexts, hidden)
+	request.Markdowns = slices.DeleteFunc(request.Markdowns, hidden)
+	request.Curves = slices.DeleteFunc(request.Curves, hidden)
+	request.Triangles = slices.DeleteFunc(request.Triangles, hidden)
+	request.Paths = slices.DeleteFunc(request.Paths, hidden)
+	request.Callouts = slices.DeleteFunc(request.Callouts, hidden)
+	request.BlurRegions = slices.DeleteFunc(request.BlurRegions, hidden)
+	request.PixelateRegions = slices.DeleteFunc(request.PixelateRegions, hidden)
+
+	if request.Border != nil && !request.Border.IsVisible() {
+		request.Border = nil
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"image"
+	"testing"
+)
+
+func TestVisibleFalse(t *testing.T) {
+	fonts := newTestFonts(t)
+	off, on := false, true
+
+	tests := []struct {
+		name    string
+		request func(visible *bool) ImgRequest
+	}{
+		{"rectangle", func(visible *bool) ImgRequest {
+			return ImgRequest{Rectangles: []Rectangle{{Position: Position{10, 10}, WidthPx: 40, HeightPx: 20, Color: red, Visibility: Visibility{visible}}}}
+		}},
+		{"circle", func(visible *bool) ImgRequest {
+			return ImgRequest{Circles: []Circle{{Center: Position{40, 30}, RadiusPx: 15, FillColor: red, Visibility: Visibility{visible}}}}
+		}},
+		{"text", func(visible *bool) ImgRequest {
+			return ImgRequest{SingleLineTexts: []StyledText{{Text: "Hidden", Font: fonts.regular, SizePx: pixels(20), Color: red, Position: textPosition(Position{10, 40}), Visibility: Visibility{visible}}}}
+		}},
+		{"border", func(visible *bool) ImgRequest {
+			return ImgRequest{Border: &Border{Color: red, WidthPx: 4, Visibility: Visibility{visible}}}
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			render := func(visible *bool) image.Image {
+				request := test.request(visible)
+				request.WidthPx, request.HeightPx, request.BgColor = 100, 60, white
+				return renderRequest(t, request, fonts.faces())
+			}
+
+			if ink := inkBounds(render(&on)); ink.Empty() {
+				t.Fatal("visible element left no pixels")
+			}
+
+			if ink := inkBounds(render(&off)); !ink.Empty() {
+				t.Errorf("hidden element drew at %v", ink)
+			}
+		})
+	}
+}